@@ -4,17 +4,23 @@ import (
 	"bufio"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	neturl "net/url"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/vibium/clicker/internal/auth"
 	"github.com/vibium/clicker/internal/bidi"
 	"github.com/vibium/clicker/internal/browser"
 	"github.com/vibium/clicker/internal/features"
 	"github.com/vibium/clicker/internal/log"
 	"github.com/vibium/clicker/internal/mcp"
 	"github.com/vibium/clicker/internal/paths"
+	"github.com/vibium/clicker/internal/policy"
 	"github.com/vibium/clicker/internal/process"
 	"github.com/vibium/clicker/internal/proxy"
 )
@@ -23,12 +29,23 @@ var version = "dev"
 
 // Global flags
 var (
-	headless  bool
-	waitOpen  int
-	waitClose int
-	verbose   bool
+	headless    bool
+	waitOpen    int
+	waitClose   int
+	verbose     bool
+	initScripts []string
+	emulation   emulationFlags
 )
 
+// emulationFlags are the global emulation flags, read by readEmulation.
+type emulationFlags struct {
+	geolocation   string
+	timezone      string
+	locale        string
+	colorScheme   string
+	reducedMotion string
+}
+
 // doWaitOpen waits for page to load if --wait-open is set.
 func doWaitOpen() {
 	if waitOpen > 0 {
@@ -46,6 +63,104 @@ func waitAndClose(launchResult *browser.LaunchResult) {
 	launchResult.Close()
 }
 
+// readInitScripts reads the --init-script files.
+func readInitScripts() []string {
+	sources := make([]string, 0, len(initScripts))
+	for _, path := range initScripts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading init script: %v\n", err)
+			os.Exit(1)
+		}
+		sources = append(sources, string(data))
+	}
+	return sources
+}
+
+// readEmulation builds the emulation options from the global emulation
+// flags. Returns nil if none are set.
+func readEmulation() *bidi.EmulationOptions {
+	opts := &bidi.EmulationOptions{
+		Timezone:      emulation.timezone,
+		Locale:        emulation.locale,
+		ColorScheme:   emulation.colorScheme,
+		ReducedMotion: emulation.reducedMotion,
+	}
+	if emulation.geolocation != "" {
+		var g bidi.Geolocation
+		n, _ := fmt.Sscanf(strings.ReplaceAll(emulation.geolocation, ",", " "), "%g %g %g", &g.Latitude, &g.Longitude, &g.Accuracy)
+		if n < 2 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --geolocation %q (expected latitude,longitude[,accuracy])\n", emulation.geolocation)
+			os.Exit(1)
+		}
+		opts.Geolocation = &g
+	}
+	if opts.IsEmpty() {
+		return nil
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return opts
+}
+
+// launchOptions returns the options for launching the browser of a command.
+func launchOptions() browser.LaunchOptions {
+	return browser.LaunchOptions{Headless: headless, Emulation: readEmulation()}
+}
+
+// prepareClient applies the launch-time emulation and registers the
+// --init-script files, before the command navigates.
+func prepareClient(client *bidi.Client, launchResult *browser.LaunchResult) {
+	if err := client.ApplyEmulation(launchResult.Emulation); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying emulation: %v\n", err)
+		os.Exit(1)
+	}
+	addInitScripts(client)
+}
+
+// addInitScripts registers the --init-script files to run before page scripts.
+func addInitScripts(client *bidi.Client) {
+	for _, source := range readInitScripts() {
+		if _, err := client.AddInitScript(source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding init script: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// addPolicyFlags adds the URL policy flags read by readPolicy.
+func addPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("allow-origin", nil, "Only allow navigation to this origin pattern, e.g. *.example.com (repeatable)")
+	cmd.Flags().StringArray("block-origin", nil, "Block navigation to this origin pattern (repeatable)")
+	cmd.Flags().String("policy", "", "JSON file with \"allow\" and \"block\" origin pattern lists")
+}
+
+// readPolicy builds the URL policy from the --policy file and the
+// --allow-origin and --block-origin flags. Returns nil if none are set.
+func readPolicy(cmd *cobra.Command) *policy.Policy {
+	allow, _ := cmd.Flags().GetStringArray("allow-origin")
+	block, _ := cmd.Flags().GetStringArray("block-origin")
+
+	if path, _ := cmd.Flags().GetString("policy"); path != "" {
+		file, err := policy.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		allow = append(file.Allow, allow...)
+		block = append(file.Block, block...)
+	}
+
+	p, err := policy.New(allow, block)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return p
+}
+
 // printCheck prints an actionability check result with a checkmark or X.
 func printCheck(name string, passed bool) {
 	if passed {
@@ -55,6 +170,85 @@ func printCheck(name string, passed bool) {
 	}
 }
 
+// openPage launches a browser, connects to it and navigates to url. before,
+// if not nil, runs right before the navigation. Call the returned function
+// to close the browser.
+func openPage(url string, before func(client *bidi.Client)) (*bidi.Client, func()) {
+	fmt.Println("Launching browser...")
+	launchResult, err := browser.Launch(launchOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Connecting to BiDi...")
+	conn, err := bidi.Connect(launchResult.WebSocketURL)
+	if err != nil {
+		launchResult.Close()
+		fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+		os.Exit(1)
+	}
+	closePage := func() {
+		conn.Close()
+		waitAndClose(launchResult)
+	}
+
+	client := bidi.NewClient(conn)
+	prepareClient(client, launchResult)
+	if before != nil {
+		before(client)
+	}
+
+	fmt.Printf("Navigating to %s...\n", url)
+	if _, err := client.Navigate("", url); err != nil {
+		conn.Close()
+		launchResult.Close()
+		fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+		os.Exit(1)
+	}
+
+	doWaitOpen()
+	return client, closePage
+}
+
+// printCookies prints one line per cookie.
+func printCookies(cookies []bidi.Cookie) {
+	if len(cookies) == 0 {
+		fmt.Println("No cookies")
+		return
+	}
+	fmt.Printf("Cookies (%d):\n", len(cookies))
+	for _, c := range cookies {
+		fmt.Printf("  %s=%s (domain: %s, path: %s)\n", c.Name, c.Value, c.Domain, c.Path)
+	}
+}
+
+// printWebStorage prints the localStorage and sessionStorage items of the
+// current page, one line per item.
+func printWebStorage(client *bidi.Client) {
+	for _, area := range []bidi.WebStorage{bidi.LocalStorage, bidi.SessionStorage} {
+		items, err := client.GetWebStorage("", area)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting %s: %v\n", area, err)
+			os.Exit(1)
+		}
+		if len(items) == 0 {
+			fmt.Printf("%s: No items\n", area)
+			continue
+		}
+
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Printf("%s (%d):\n", area, len(items))
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, items[key])
+		}
+	}
+}
+
 func main() {
 	// Setup signal handler to cleanup on Ctrl+C
 	process.SetupSignalHandler()
@@ -78,6 +272,12 @@ func main() {
 	rootCmd.PersistentFlags().IntVar(&waitOpen, "wait-open", 0, "Seconds to wait after navigation for page to load")
 	rootCmd.PersistentFlags().IntVar(&waitClose, "wait-close", 0, "Seconds to keep browser open before closing")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringArrayVar(&initScripts, "init-script", nil, "JavaScript file to run before page scripts in every document (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&emulation.geolocation, "geolocation", "", "Emulate this position, as latitude,longitude[,accuracy in meters]")
+	rootCmd.PersistentFlags().StringVar(&emulation.timezone, "timezone", "", "Emulate this IANA timezone, e.g. Europe/Berlin")
+	rootCmd.PersistentFlags().StringVar(&emulation.locale, "locale", "", "Emulate this locale and Accept-Language, e.g. de-DE")
+	rootCmd.PersistentFlags().StringVar(&emulation.colorScheme, "color-scheme", "", "Emulate prefers-color-scheme: light or dark")
+	rootCmd.PersistentFlags().StringVar(&emulation.reducedMotion, "reduced-motion", "", "Emulate prefers-reduced-motion: reduce or no-preference")
 
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
@@ -135,7 +335,7 @@ func main() {
 		Use:   "launch-test",
 		Short: "Launch browser via chromedriver and print BiDi WebSocket URL",
 		Run: func(cmd *cobra.Command, args []string) {
-			result, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+			result, err := browser.Launch(launchOptions())
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
@@ -245,7 +445,7 @@ func main() {
 				url := args[0]
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -261,6 +461,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				result, err := client.Navigate("", url)
@@ -288,7 +489,7 @@ func main() {
 				output, _ := cmd.Flags().GetString("output")
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -304,6 +505,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -353,7 +555,7 @@ func main() {
 				expression := args[1]
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -369,6 +571,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -403,7 +606,7 @@ func main() {
 				selector := args[1]
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -419,6 +622,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -442,6 +646,140 @@ func main() {
 		},
 	})
 
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot [url]",
+		Short: "Navigate to a URL and print an accessibility snapshot of the page",
+		Example: `  clicker snapshot https://example.com
+  # Prints:
+  # - heading "Example Domain" [level=1] [ref=e1]
+  # - paragraph [ref=e2]
+  # ...
+
+  clicker snapshot https://example.com --json`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				asJSON, _ := cmd.Flags().GetBool("json")
+
+				fmt.Fprintln(os.Stderr, "Launching browser...")
+				launchResult, err := browser.Launch(launchOptions())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Fprintln(os.Stderr, "Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
+
+				fmt.Fprintf(os.Stderr, "Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				snapshot, err := features.TakeSnapshot(client, "")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error taking snapshot: %v\n", err)
+					os.Exit(1)
+				}
+
+				if asJSON {
+					data, _ := json.MarshalIndent(snapshot, "", "  ")
+					fmt.Println(string(data))
+					return
+				}
+				fmt.Println(snapshot.String())
+			})
+		},
+	}
+	snapshotCmd.Flags().Bool("json", false, "Print the snapshot as JSON")
+	rootCmd.AddCommand(snapshotCmd)
+
+	contentCmd := &cobra.Command{
+		Use:   "content [url]",
+		Short: "Navigate to a URL and print the page content as Markdown, text or HTML",
+		Example: `  clicker content https://example.com
+  # Prints: # Example Domain ...
+
+  clicker content https://example.com --format text
+  clicker content https://example.com --selector "main" --max-length 5000`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				url := args[0]
+				formatName, _ := cmd.Flags().GetString("format")
+				selector, _ := cmd.Flags().GetString("selector")
+				maxLength, _ := cmd.Flags().GetInt("max-length")
+
+				format, err := features.ParseContentFormat(formatName)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Fprintln(os.Stderr, "Launching browser...")
+				launchResult, err := browser.Launch(launchOptions())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
+					os.Exit(1)
+				}
+				defer waitAndClose(launchResult)
+
+				fmt.Fprintln(os.Stderr, "Connecting to BiDi...")
+				conn, err := bidi.Connect(launchResult.WebSocketURL)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
+					os.Exit(1)
+				}
+				defer conn.Close()
+
+				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
+
+				fmt.Fprintf(os.Stderr, "Navigating to %s...\n", url)
+				_, err = client.Navigate("", url)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error navigating: %v\n", err)
+					os.Exit(1)
+				}
+
+				doWaitOpen()
+
+				content, err := features.ExtractContent(client, "", features.ContentOptions{
+					Format:    format,
+					Selector:  selector,
+					MaxLength: maxLength,
+				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error extracting content: %v\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Println(content.Content)
+				if content.Truncated {
+					fmt.Fprintf(os.Stderr, "(truncated at %d characters)\n", maxLength)
+				}
+			})
+		},
+	}
+	contentCmd.Flags().StringP("format", "f", "md", "Output format: md, text or html")
+	contentCmd.Flags().StringP("selector", "s", "", "CSS selector of the element to extract (default: main content)")
+	contentCmd.Flags().Int("max-length", 0, "Maximum length in characters (0 = no limit)")
+	rootCmd.AddCommand(contentCmd)
+
 	clickCmd := &cobra.Command{
 		Use:   "click [url] [selector]",
 		Short: "Navigate to a URL and click an element (with actionability checks)",
@@ -459,7 +797,7 @@ func main() {
 				timeout, _ := cmd.Flags().GetDuration("timeout")
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -475,6 +813,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -536,7 +875,7 @@ func main() {
 				timeout, _ := cmd.Flags().GetDuration("timeout")
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -552,6 +891,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -609,7 +949,7 @@ func main() {
 				selector := args[1]
 
 				fmt.Println("Launching browser...")
-				launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+				launchResult, err := browser.Launch(launchOptions())
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error launching browser: %v\n", err)
 					os.Exit(1)
@@ -625,6 +965,7 @@ func main() {
 				defer conn.Close()
 
 				client := bidi.NewClient(conn)
+				prepareClient(client, launchResult)
 
 				fmt.Printf("Navigating to %s...\n", url)
 				_, err = client.Navigate("", url)
@@ -653,9 +994,197 @@ func main() {
 		},
 	})
 
+	cookiesCmd := &cobra.Command{
+		Use:   "cookies",
+		Short: "Get, set, or clear cookies for a URL",
+	}
+
+	cookiesCmd.AddCommand(&cobra.Command{
+		Use:   "get [url]",
+		Short: "Navigate to a URL and print its cookies",
+		Example: `  clicker cookies get https://example.com
+  # Prints: name=value (domain, path) for each cookie`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				client, closePage := openPage(args[0], nil)
+				defer closePage()
+
+				cookies, err := client.GetCookies(bidi.CookieFilter{})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error getting cookies: %v\n", err)
+					os.Exit(1)
+				}
+
+				printCookies(cookies)
+			})
+		},
+	})
+
+	cookiesCmd.AddCommand(&cobra.Command{
+		Use:   "set [url] [name] [value]",
+		Short: "Set a cookie for a URL's domain, then navigate to it",
+		Example: `  clicker cookies set https://example.com session abc123
+  # Sets session=abc123 for example.com, loads the page, and prints its cookies`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				rawURL := args[0]
+				name := args[1]
+				value := args[2]
+
+				u, err := neturl.Parse(rawURL)
+				if err != nil || u.Hostname() == "" {
+					fmt.Fprintf(os.Stderr, "Error: invalid URL: %s\n", rawURL)
+					os.Exit(1)
+				}
+
+				client, closePage := openPage(rawURL, func(client *bidi.Client) {
+					fmt.Printf("Setting cookie: %s\n", name)
+					if err := client.SetCookie(bidi.Cookie{Name: name, Value: value, Domain: u.Hostname(), Path: "/"}); err != nil {
+						fmt.Fprintf(os.Stderr, "Error setting cookie: %v\n", err)
+						os.Exit(1)
+					}
+				})
+				defer closePage()
+
+				cookies, err := client.GetCookies(bidi.CookieFilter{})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error getting cookies: %v\n", err)
+					os.Exit(1)
+				}
+
+				printCookies(cookies)
+			})
+		},
+	})
+
+	cookiesCmd.AddCommand(&cobra.Command{
+		Use:   "clear [url]",
+		Short: "Navigate to a URL, delete all cookies, and print what remains",
+		Example: `  clicker cookies clear https://example.com
+  # Prints: No cookies`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				client, closePage := openPage(args[0], nil)
+				defer closePage()
+
+				fmt.Println("Clearing cookies...")
+				if err := client.DeleteCookies(bidi.CookieFilter{}); err != nil {
+					fmt.Fprintf(os.Stderr, "Error clearing cookies: %v\n", err)
+					os.Exit(1)
+				}
+
+				cookies, err := client.GetCookies(bidi.CookieFilter{})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error getting cookies: %v\n", err)
+					os.Exit(1)
+				}
+
+				printCookies(cookies)
+			})
+		},
+	})
+
+	rootCmd.AddCommand(cookiesCmd)
+
+	storageCmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Get, set, or clear a page's localStorage and sessionStorage",
+	}
+
+	storageCmd.AddCommand(&cobra.Command{
+		Use:   "get [url]",
+		Short: "Navigate to a URL and print its localStorage and sessionStorage",
+		Example: `  clicker storage get https://example.com
+  # Prints: key=value for each item, by storage area`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				client, closePage := openPage(args[0], nil)
+				defer closePage()
+
+				printWebStorage(client)
+			})
+		},
+	})
+
+	storageSetCmd := &cobra.Command{
+		Use:   "set [url] [key] [value]",
+		Short: "Navigate to a URL, store an item, and print its storage",
+		Example: `  clicker storage set https://example.com theme dark
+  # Sets localStorage theme=dark and prints the page's storage
+
+  clicker storage set https://example.com step 2 --session
+  # Uses sessionStorage instead`,
+		Args: cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				area := bidi.LocalStorage
+				if session, _ := cmd.Flags().GetBool("session"); session {
+					area = bidi.SessionStorage
+				}
+
+				client, closePage := openPage(args[0], nil)
+				defer closePage()
+
+				fmt.Printf("Setting %s item: %s\n", area, args[1])
+				if err := client.SetWebStorageItems("", area, map[string]string{args[1]: args[2]}); err != nil {
+					fmt.Fprintf(os.Stderr, "Error setting %s item: %v\n", area, err)
+					os.Exit(1)
+				}
+
+				printWebStorage(client)
+			})
+		},
+	}
+	storageSetCmd.Flags().Bool("session", false, "Use sessionStorage instead of localStorage")
+	storageCmd.AddCommand(storageSetCmd)
+
+	storageCmd.AddCommand(&cobra.Command{
+		Use:   "clear [url]",
+		Short: "Navigate to a URL, clear its localStorage and sessionStorage, and print what remains",
+		Example: `  clicker storage clear https://example.com
+  # Prints: No items, for each storage area`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			process.WithCleanup(func() {
+				client, closePage := openPage(args[0], nil)
+				defer closePage()
+
+				fmt.Println("Clearing storage...")
+				for _, area := range []bidi.WebStorage{bidi.LocalStorage, bidi.SessionStorage} {
+					if err := client.ClearWebStorage("", area); err != nil {
+						fmt.Fprintf(os.Stderr, "Error clearing %s: %v\n", area, err)
+						os.Exit(1)
+					}
+				}
+
+				printWebStorage(client)
+			})
+		},
+	})
+
+	rootCmd.AddCommand(storageCmd)
+
 	serveCmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start WebSocket proxy server for browser automation",
+		Long: `Start the WebSocket proxy server. Each client that connects gets its own
+browser session.
+
+A client connecting with ?observe=<client id> (see /sessions) instead watches
+that client's session read-only: it gets the same responses and events, can
+subscribe to more events, and can send read-only commands such as
+browsingContext.captureScreenshot.
+
+Besides the WebSocket endpoint, the server answers HTTP requests on:
+  /healthz          200 while the server runs
+  /readyz           200 if a new client would get a session right away, else 503
+  /sessions         active sessions as JSON (needs the token)
+  /sessions/{id}    DELETE to end the session of a client (needs the token)
+  /metrics          Prometheus metrics (needs the token)`,
 		Example: `  clicker serve
   # Starts server on default port 9515, visible browser
 
@@ -663,18 +1192,134 @@ func main() {
   # Starts server on port 8080
 
   clicker serve --headless
-  # Starts server with headless browser`,
+  # Starts server with headless browser
+
+  clicker serve --storage-state auth.json
+  # Restores saved cookies and storage into every new session
+
+  clicker serve --init-script mocks.js
+  # Runs mocks.js in every page before the page's own scripts
+
+  clicker serve --pool-size 2 --max-sessions 4
+  # Keeps 2 browsers ready for new clients, at most 4 sessions at once
+
+  clicker serve --shared-browsers 1
+  # One browser for all clients, each in its own isolated user context
+
+  clicker serve --idle-timeout 5m --max-lifetime 1h --max-memory 2048
+  # Closes sessions idle for 5 minutes, older than an hour, or whose browser
+  # uses more than 2 GB of memory (Linux only)
+
+  clicker serve --record recordings/
+  # Writes every message of each session to recordings/*.jsonl, for
+  # clicker replay
+
+  clicker serve --keep-alive 30s
+  # Clients that drop can reconnect within 30s with ?resume=<token> from the
+  # vibium:sessionReady event and get the events they missed
+
+  clicker serve --allow-origin staging.example.com --allow-origin "*.cdn.example.com"
+  # Blocks navigation anywhere else, including file:// and chrome:// URLs
+
+  clicker serve --host 0.0.0.0 --token "$TOKEN" --tls-cert cert.pem --tls-key key.pem
+  # Accepts remote clients over wss:// that send "Authorization: Bearer $TOKEN"
+
+  clicker serve --client-origin http://localhost:3000
+  # Lets pages served from localhost:3000 connect (with the token)`,
 		Run: func(cmd *cobra.Command, args []string) {
 			process.WithCleanup(func() {
 				port, _ := cmd.Flags().GetInt("port")
+				storageState, _ := cmd.Flags().GetString("storage-state")
+				poolSize, _ := cmd.Flags().GetInt("pool-size")
+				maxSessions, _ := cmd.Flags().GetInt("max-sessions")
+				queueTimeout, _ := cmd.Flags().GetDuration("queue-timeout")
+				sharedBrowsers, _ := cmd.Flags().GetInt("shared-browsers")
+				keepAlive, _ := cmd.Flags().GetDuration("keep-alive")
+				idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+				maxLifetime, _ := cmd.Flags().GetDuration("max-lifetime")
+				maxMemory, _ := cmd.Flags().GetInt("max-memory")
+				recordDir, _ := cmd.Flags().GetString("record")
+				host, _ := cmd.Flags().GetString("host")
+				token, _ := cmd.Flags().GetString("token")
+				clientOrigins, _ := cmd.Flags().GetStringArray("client-origin")
+				tlsCert, _ := cmd.Flags().GetString("tls-cert")
+				tlsKey, _ := cmd.Flags().GetString("tls-key")
+
+				if poolSize < 0 || maxSessions < 0 || sharedBrowsers < 0 {
+					fmt.Fprintln(os.Stderr, "Error: --pool-size, --max-sessions and --shared-browsers can't be negative")
+					os.Exit(1)
+				}
+				if idleTimeout < 0 || maxLifetime < 0 || maxMemory < 0 {
+					fmt.Fprintln(os.Stderr, "Error: --idle-timeout, --max-lifetime and --max-memory can't be negative")
+					os.Exit(1)
+				}
+				if maxMemory > 0 && !browser.MemoryUsageSupported {
+					fmt.Fprintln(os.Stderr, "Error: --max-memory is only supported on Linux")
+					os.Exit(1)
+				}
+				if poolSize > 0 && sharedBrowsers > 0 {
+					fmt.Fprintln(os.Stderr, "Error: --pool-size can't be combined with --shared-browsers")
+					os.Exit(1)
+				}
+
+				if (tlsCert == "") != (tlsKey == "") {
+					fmt.Fprintln(os.Stderr, "Error: --tls-cert and --tls-key must be used together")
+					os.Exit(1)
+				}
+
+				// Without a token, generate one: any web page could
+				// otherwise drive the browser through localhost
+				if token == "" {
+					token = os.Getenv("CLICKER_TOKEN")
+				}
+				generatedToken := token == ""
+				if generatedToken {
+					var err error
+					if token, err = auth.GenerateToken(); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
 
 				fmt.Printf("Starting Clicker proxy server on port %d...\n", port)
 
+				// Fail fast on an unreadable storage state file
+				if storageState != "" {
+					if _, err := features.ReadStorageState(storageState); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
+
 				// Create router to manage browser sessions
-				router := proxy.NewRouter(headless)
+				router := proxy.NewRouter(headless,
+					proxy.WithVersion(version),
+					proxy.WithStorageState(storageState),
+					proxy.WithInitScripts(readInitScripts()),
+					proxy.WithEmulation(readEmulation()),
+					proxy.WithPolicy(readPolicy(cmd)),
+					proxy.WithPool(proxy.PoolOptions{
+						Size:        poolSize,
+						MaxSessions: maxSessions,
+						WaitTimeout: queueTimeout,
+					}),
+					proxy.WithSharedBrowsers(sharedBrowsers),
+					proxy.WithKeepAlive(keepAlive),
+					proxy.WithSessionLimits(proxy.SessionLimits{
+						IdleTimeout: idleTimeout,
+						MaxLifetime: maxLifetime,
+						MaxMemory:   uint64(maxMemory) << 20,
+					}),
+					proxy.WithRecording(recordDir),
+				)
 
 				server := proxy.NewServer(
+					proxy.WithHost(host),
 					proxy.WithPort(port),
+					proxy.WithToken(token),
+					proxy.WithAllowedOrigins(clientOrigins),
+					proxy.WithTLS(tlsCert, tlsKey),
+					proxy.WithControlHandler(router.ControlHandler()),
 					proxy.WithOnConnect(router.OnClientConnect),
 					proxy.WithOnMessage(router.OnClientMessage),
 					proxy.WithOnClose(router.OnClientDisconnect),
@@ -685,7 +1330,11 @@ func main() {
 					os.Exit(1)
 				}
 
-				fmt.Printf("Server listening on ws://localhost:%d\n", port)
+				fmt.Printf("Server listening on %s\n", server.URL())
+				if generatedToken {
+					fmt.Printf("Token: %s\n", token)
+					fmt.Printf("Connect with: %s/?token=%s\n", server.URL(), token)
+				}
 				fmt.Println("Press Ctrl+C to stop...")
 
 				// Wait for signal
@@ -703,24 +1352,114 @@ func main() {
 		},
 	}
 	serveCmd.Flags().IntP("port", "p", 9515, "Port to listen on")
+	serveCmd.Flags().String("host", "127.0.0.1", "Address to listen on (use 0.0.0.0 to accept remote clients)")
+	serveCmd.Flags().String("token", "", "Token clients must send as a bearer token or ?token= parameter (default: $CLICKER_TOKEN, or generated and printed)")
+	serveCmd.Flags().StringArray("client-origin", nil, "Origin of web pages allowed to connect, e.g. http://localhost:3000 (repeatable, \"*\" for any)")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file, to serve wss://")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file, to serve wss://")
+	serveCmd.Flags().String("storage-state", "", "Storage state file (cookies, localStorage, sessionStorage) to restore into each session")
+	serveCmd.Flags().Int("pool-size", 0, "Browsers to keep launched and ready for new clients")
+	serveCmd.Flags().Int("max-sessions", 0, "Maximum concurrent browser sessions, further clients wait (0 = unlimited)")
+	serveCmd.Flags().Duration("queue-timeout", 30*time.Second, "With --max-sessions, how long a client waits for a free session")
+	serveCmd.Flags().Duration("keep-alive", 0, "Keep a disconnected client's browser session this long, so it can reconnect and resume it (0 = close right away)")
+	serveCmd.Flags().Duration("idle-timeout", 0, "Close sessions whose client sends no command for this long (0 = never)")
+	serveCmd.Flags().Duration("max-lifetime", 0, "Close sessions this long after they start (0 = never)")
+	serveCmd.Flags().Int("max-memory", 0, "Close sessions whose browser uses more than this many MB of memory, Linux only (0 = unlimited)")
+	serveCmd.Flags().String("record", "", "Directory to record each session's messages to, as JSONL for clicker replay")
+	serveCmd.Flags().Int("shared-browsers", 0, "Share this many browsers between clients, giving each client an isolated user context (0 = a browser per client)")
+	addPolicyFlags(serveCmd)
 	rootCmd.AddCommand(serveCmd)
 
+	replayCmd := &cobra.Command{
+		Use:   "replay [recording.jsonl]",
+		Short: "Replay a session recorded by serve --record and compare responses",
+		Long: `Replay the client commands of a session recorded with clicker serve --record
+against a fresh browser, one command at a time, and compare each response with
+the recorded one. The browser gets the init scripts, storage state, emulation,
+URL policy and shared mode the session was recorded with. IDs the browser
+assigns, like browsing context IDs, are mapped from the recording to the
+replay; timestamps and screenshot data are not compared.
+
+Exits with status 1 if any response differs.`,
+		Example: `  clicker replay recordings/session-20260101-120000-client-1.jsonl --headless
+  # Replays the session and lists responses that differ`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			var result *proxy.ReplayResult
+			var err error
+			process.WithCleanup(func() {
+				result, err = proxy.Replay(args[0], proxy.ReplayOptions{
+					Headless: headless,
+					Timeout:  timeout,
+				})
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			for _, diff := range result.Diffs {
+				fmt.Printf("\nCommand %d (%s) differs:\n", diff.ID, diff.Method)
+				for _, d := range diff.Differences {
+					fmt.Printf("  %s\n", d)
+				}
+			}
+			fmt.Printf("\nReplayed %d commands, %d of %d responses match\n", result.Commands, result.Compared-len(result.Diffs), result.Compared)
+			if len(result.Diffs) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	replayCmd.Flags().Duration("timeout", 60*time.Second, "How long to wait for each response")
+	rootCmd.AddCommand(replayCmd)
+
 	mcpCmd := &cobra.Command{
 		Use:   "mcp",
 		Short: "Start MCP server (stdio JSON-RPC for LLM agents)",
 		Long: `Start the Model Context Protocol (MCP) server.
 
 This runs a JSON-RPC 2.0 server over stdin/stdout, designed for integration
-with LLM agents like Claude Code.
+with LLM agents like Claude Code. With --http, it serves the MCP Streamable
+HTTP transport instead, and each MCP session gets its own browser.
 
 The server provides browser automation tools:
-  - browser_launch: Start a browser session
+  - browser_launch: Start a browser session (name sessions to run several)
   - browser_navigate: Go to a URL
   - browser_click: Click an element
   - browser_type: Type into an element
   - browser_screenshot: Capture the page
   - browser_find: Find element info
-  - browser_quit: Close the browser`,
+  - browser_snapshot: Accessibility tree with element refs for click/type
+  - browser_get_content: Read the page as Markdown, text or HTML
+  - browser_cookies_get: Read cookies
+  - browser_cookies_set: Set a cookie
+  - browser_cookies_clear: Delete cookies
+  - browser_save_state: Save cookies and storage to a file in the state directory
+  - browser_load_state: Restore cookies and storage from a file
+  - browser_evaluate: Run JavaScript in the page (disable with --disable-evaluate)
+  - browser_sessions_list: List open browser sessions
+  - browser_quit: Close a browser session
+
+Every tool takes an optional session argument; without it, tools use the
+most recently launched session. The current page, its snapshot, the console
+log and saved screenshots are also available as MCP resources.
+
+With --allow-origin, --block-origin or --policy, navigation is restricted to
+the allowed origins, whether by browser_navigate or by the page itself (links,
+scripts, redirects). Blocked navigations fail with a policy violation error.
+
+With --http, the server listens on 127.0.0.1 unless the address names a
+host, and clients must send the token as "Authorization: Bearer <token>".
+Requests from web pages are rejected unless their origin is allowed with
+--client-origin.
+
+The server also offers prompts for common workflows (explore_site, fill_form,
+reproduce_bug). Add your own with --prompt-dir: each *.json file in the
+directory defines a prompt with a name, description, arguments and template,
+e.g. {"name": "smoke", "arguments": [{"name": "url", "required": true}],
+"template": "Open {{.url}} and check that the page loads."}`,
 		Example: `  # Run directly (for testing)
   clicker mcp
 
@@ -733,12 +1472,29 @@ The server provides browser automation tools:
   # Disable screenshot file saving (inline only)
   clicker mcp --screenshot-dir ""
 
+  # Serve over HTTP for local agents (endpoint: http://localhost:8931/mcp)
+  clicker mcp --http :8931 --token "$TOKEN"
+
+  # Serve over HTTP for remote agents
+  clicker mcp --http 0.0.0.0:8931 --token "$TOKEN"
+
+  # Don't allow agents to run arbitrary JavaScript
+  clicker mcp --disable-evaluate
+
+  # Share team QA prompts
+  clicker mcp --prompt-dir ./qa-prompts
+
+  # Keep agents on the staging site
+  clicker mcp --allow-origin staging.example.com --block-origin "*.ads.example.com"
+
   # Test with echo
   echo '{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}' | clicker mcp`,
 		Run: func(cmd *cobra.Command, args []string) {
 			process.WithCleanup(func() {
+				httpAddr, _ := cmd.Flags().GetString("http")
+
 				// If running in a terminal, print helpful info to stderr
-				if stat, _ := os.Stdin.Stat(); (stat.Mode() & os.ModeCharDevice) != 0 {
+				if stat, _ := os.Stdin.Stat(); httpAddr == "" && (stat.Mode()&os.ModeCharDevice) != 0 {
 					fmt.Fprintf(os.Stderr, "Vibium MCP server v%s\n", version)
 					fmt.Fprintln(os.Stderr, "This server communicates via JSON-RPC over stdin/stdout.")
 					fmt.Fprintln(os.Stderr, "It's meant to be run by an MCP client (e.g., Claude Desktop).")
@@ -776,9 +1532,82 @@ The server provides browser automation tools:
 					}
 				}
 
-				server := mcp.NewServer(version, mcp.ServerOptions{
-					ScreenshotDir: screenshotDir,
-				})
+				var stateDir string
+				if cmd.Flags().Changed("state-dir") {
+					stateDir, _ = cmd.Flags().GetString("state-dir")
+				} else {
+					defaultDir, err := paths.GetStateDir()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: could not determine default state directory: %v\n", err)
+					} else {
+						stateDir = defaultDir
+					}
+				}
+
+				// Fail fast on invalid prompt files
+				promptDir, _ := cmd.Flags().GetString("prompt-dir")
+				if promptDir != "" {
+					if _, err := mcp.LoadPrompts(promptDir); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
+
+				disableEvaluate, _ := cmd.Flags().GetBool("disable-evaluate")
+				serverOpts := mcp.ServerOptions{
+					ScreenshotDir:   screenshotDir,
+					StateDir:        stateDir,
+					DisableEvaluate: disableEvaluate,
+					Policy:          readPolicy(cmd),
+					PromptDir:       promptDir,
+					Emulation:       readEmulation(),
+				}
+
+				if httpAddr != "" {
+					idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+					token, _ := cmd.Flags().GetString("token")
+					clientOrigins, _ := cmd.Flags().GetStringArray("client-origin")
+
+					// Without a token, generate one, like serve does
+					if token == "" {
+						token = os.Getenv("CLICKER_TOKEN")
+					}
+					generatedToken := token == ""
+					if generatedToken {
+						var err error
+						if token, err = auth.GenerateToken(); err != nil {
+							fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+							os.Exit(1)
+						}
+					}
+
+					server := mcp.NewHTTPServer(version, mcp.HTTPOptions{
+						ServerOptions:  serverOpts,
+						IdleTimeout:    idleTimeout,
+						Token:          token,
+						AllowedOrigins: clientOrigins,
+					})
+					if err := server.Start(httpAddr); err != nil {
+						fmt.Fprintf(os.Stderr, "Error starting MCP server: %v\n", err)
+						os.Exit(1)
+					}
+
+					if generatedToken {
+						fmt.Fprintf(os.Stderr, "Token: %s\n", token)
+					}
+					fmt.Fprintf(os.Stderr, "Vibium MCP server v%s listening on http://%s%s\n", version, server.Addr(), mcp.HTTPPath)
+					fmt.Fprintln(os.Stderr, "Press Ctrl+C to stop...")
+
+					process.WaitForSignal()
+
+					fmt.Fprintln(os.Stderr, "\nShutting down...")
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					server.Stop(ctx)
+					return
+				}
+
+				server := mcp.NewServer(version, serverOpts)
 				defer server.Close()
 
 				if err := server.Run(); err != nil {
@@ -789,6 +1618,14 @@ The server provides browser automation tools:
 		},
 	}
 	mcpCmd.Flags().String("screenshot-dir", "", "Directory for saving screenshots (default: ~/Pictures/Vibium, use \"\" to disable)")
+	mcpCmd.Flags().String("state-dir", "", "Directory for browser_save_state and browser_load_state files (default: the vibium cache directory's state/, use \"\" to disable)")
+	mcpCmd.Flags().String("http", "", "Serve MCP over Streamable HTTP on this address (e.g. :8931, on 127.0.0.1 without a host) instead of stdio")
+	mcpCmd.Flags().Duration("idle-timeout", mcp.DefaultIdleTimeout, "With --http, close sessions and their browsers after this much inactivity")
+	mcpCmd.Flags().String("token", "", "With --http, token clients must send as a bearer token (default: $CLICKER_TOKEN, or generated and printed)")
+	mcpCmd.Flags().StringArray("client-origin", nil, "With --http, origin of web pages allowed to connect, e.g. http://localhost:3000 (repeatable, \"*\" for any)")
+	mcpCmd.Flags().Bool("disable-evaluate", false, "Disable the browser_evaluate tool (no arbitrary JavaScript execution)")
+	mcpCmd.Flags().String("prompt-dir", "", "Directory of prompt templates (*.json) to serve besides the built-in prompts")
+	addPolicyFlags(mcpCmd)
 	rootCmd.AddCommand(mcpCmd)
 
 	rootCmd.Version = version
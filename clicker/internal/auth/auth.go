@@ -0,0 +1,77 @@
+// Package auth checks the bearer tokens and Origin headers of requests to
+// the servers clicker runs (serve and mcp --http).
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GenerateToken returns a random token for clients to authenticate with.
+func GenerateToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// BearerToken returns the token of an "Authorization: Bearer <token>"
+// header, or "" if there is none.
+func BearerToken(r *http.Request) string {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// TokenMatches compares a token sent by a client with the expected one in
+// constant time. An empty expected token matches anything.
+func TokenMatches(got, want string) bool {
+	if want == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// OriginAllowed allows requests without an Origin header, which come from
+// programs rather than web pages, and requests from the allowed origins
+// (e.g. "http://localhost:3000"). "*" allows all origins.
+func OriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	normalized := normalizeOrigin(origin)
+	for _, a := range allowed {
+		if a == "*" || normalizeOrigin(a) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOrigin lowercases an origin and drops the scheme's default port,
+// so "HTTP://Example.com:80" and "http://example.com" compare equal.
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(strings.ToLower(strings.TrimSuffix(origin, "/")))
+	if err != nil || u.Host == "" {
+		return origin
+	}
+
+	host := u.Host
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		host = u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return u.Scheme + "://" + host
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"no origin", "", nil, true},
+		{"not allowed", "http://evil.example", nil, false},
+		{"allowed", "http://localhost:3000", []string{"http://localhost:3000"}, true},
+		{"default port", "HTTP://Example.com:80", []string{"http://example.com"}, true},
+		{"other port", "http://localhost:3001", []string{"http://localhost:3000"}, false},
+		{"any", "https://a.example", []string{"*"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := OriginAllowed(r, tt.allowed); got != tt.want {
+				t.Errorf("OriginAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"Bearer abc", "abc"},
+		{"bearer  abc ", "abc"},
+		{"Basic abc", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", tt.header)
+		if got := BearerToken(r); got != tt.want {
+			t.Errorf("BearerToken(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTokenMatches(t *testing.T) {
+	if !TokenMatches("anything", "") {
+		t.Error("an empty expected token should match")
+	}
+	if !TokenMatches("abc", "abc") || TokenMatches("abd", "abc") || TokenMatches("", "abc") {
+		t.Error("tokens should match exactly")
+	}
+}
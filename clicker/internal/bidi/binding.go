@@ -0,0 +1,105 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BindingHandler receives the arguments page JavaScript passed to a
+// binding, deserialized like script results (see DeserializeRemoteValue).
+type BindingHandler func(args []interface{})
+
+// Binding is a named function exposed to page JavaScript.
+type Binding struct {
+	Name          string
+	Channel       string // script.message channel carrying the calls
+	preloadScript string
+	unsubscribe   func()
+}
+
+// ExposeBinding installs window[name] in every document. Calling it from
+// page JavaScript delivers the arguments to handler through a BiDi
+// script.message channel; the call returns nothing. Call StartEventLoop to
+// receive calls while no command is pending.
+func (c *Client) ExposeBinding(name string, handler BindingHandler) (*Binding, error) {
+	if name == "" {
+		return nil, fmt.Errorf("binding name is required")
+	}
+
+	if err := c.ensureSubscribed("script.message"); err != nil {
+		return nil, err
+	}
+
+	binding := &Binding{
+		Name:    name,
+		Channel: "vibium-binding-" + name,
+	}
+	binding.unsubscribe = c.OnEvent("script.message", func(event *Event) {
+		var params struct {
+			Channel string          `json:"channel"`
+			Data    json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(event.Params, &params); err != nil || params.Channel != binding.Channel {
+			return
+		}
+		data, err := DeserializeRemoteValue(params.Data)
+		if err != nil {
+			return
+		}
+		args, _ := data.([]interface{})
+		handler(args)
+	})
+
+	nameJSON, _ := json.Marshal(name)
+	declaration := fmt.Sprintf(`(send) => {
+		window[%s] = (...args) => { send(args); };
+	}`, nameJSON)
+	channel := map[string]interface{}{
+		"type": "channel",
+		"value": map[string]interface{}{
+			"channel":   binding.Channel,
+			"ownership": "none",
+		},
+	}
+
+	msg, err := c.SendCommand("script.addPreloadScript", map[string]interface{}{
+		"functionDeclaration": declaration,
+		"arguments":           []interface{}{channel},
+	})
+	if err != nil {
+		binding.unsubscribe()
+		return nil, err
+	}
+	var result AddPreloadScriptResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		binding.unsubscribe()
+		return nil, fmt.Errorf("failed to parse script.addPreloadScript result: %w", err)
+	}
+	binding.preloadScript = result.Script
+
+	// Install the binding in documents that are already loaded
+	tree, err := c.GetTree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get browsing contexts: %w", err)
+	}
+	for _, ctx := range tree.Contexts {
+		if _, err := c.SendCommand("script.callFunction", map[string]interface{}{
+			"functionDeclaration": declaration,
+			"target":              map[string]interface{}{"context": ctx.Context},
+			"arguments":           []interface{}{channel},
+			"awaitPromise":        false,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to install binding: %w", err)
+		}
+	}
+
+	return binding, nil
+}
+
+// RemoveBinding stops delivering calls for the binding and removes it from
+// new documents. Documents that already have it keep window[name], but its
+// calls are ignored.
+func (c *Client) RemoveBinding(binding *Binding) error {
+	binding.unsubscribe()
+	return c.RemovePreloadScript(binding.preloadScript)
+}
@@ -0,0 +1,86 @@
+package bidi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExposeBinding(t *testing.T) {
+	message := func(channel string) fakeEvent {
+		return fakeEvent{Method: "script.message", Params: map[string]interface{}{
+			"channel": channel,
+			"data": map[string]interface{}{"type": "array", "value": []interface{}{
+				map[string]interface{}{"type": "string", "value": "clicked"},
+				map[string]interface{}{"type": "number", "value": 2},
+			}},
+			"source": map[string]interface{}{"realm": "realm-1"},
+		}}
+	}
+
+	client, browser := newFakeBrowser(t, func(cmd fakeCommand) fakeReply {
+		switch cmd.Method {
+		case "script.addPreloadScript":
+			return fakeReply{Result: map[string]interface{}{"script": "preload-1"}}
+		case "browsingContext.getTree":
+			return fakeReply{Result: map[string]interface{}{"contexts": []interface{}{
+				map[string]interface{}{"context": "ctx-1", "url": "about:blank", "children": []interface{}{}},
+			}}}
+		case "session.status":
+			// Calls of the binding and of another channel
+			return fakeReply{
+				Result: map[string]interface{}{"ready": true, "message": ""},
+				Events: []fakeEvent{message("other"), message("vibium-binding-report")},
+			}
+		}
+		return fakeReply{}
+	})
+
+	var calls [][]interface{}
+	binding, err := client.ExposeBinding("report", func(args []interface{}) {
+		calls = append(calls, args)
+	})
+	if err != nil {
+		t.Fatalf("ExposeBinding: %v", err)
+	}
+
+	if _, err := client.SessionStatus(); err != nil {
+		t.Fatalf("SessionStatus: %v", err)
+	}
+	want := [][]interface{}{{"clicked", float64(2)}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// The preload script and the loaded document get the channel
+	for _, cmd := range browser.received() {
+		if cmd.Method != "script.addPreloadScript" && cmd.Method != "script.callFunction" {
+			continue
+		}
+		args, _ := cmd.Params["arguments"].([]interface{})
+		if len(args) != 1 {
+			t.Fatalf("%s arguments = %v, want the channel", cmd.Method, cmd.Params["arguments"])
+		}
+		channel := args[0].(map[string]interface{})
+		value, _ := channel["value"].(map[string]interface{})
+		if channel["type"] != "channel" || value["channel"] != binding.Channel {
+			t.Errorf("%s argument = %v, want channel %s", cmd.Method, channel, binding.Channel)
+		}
+	}
+
+	if err := client.RemoveBinding(binding); err != nil {
+		t.Fatalf("RemoveBinding: %v", err)
+	}
+	received := browser.received()
+	last := received[len(received)-1]
+	if last.Method != "script.removePreloadScript" || last.Params["script"] != "preload-1" {
+		t.Errorf("RemoveBinding sent %s %v", last.Method, last.Params)
+	}
+
+	// Calls after removal are ignored
+	if _, err := client.SessionStatus(); err != nil {
+		t.Fatalf("SessionStatus: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("got %d calls after RemoveBinding, want 1", len(calls))
+	}
+}
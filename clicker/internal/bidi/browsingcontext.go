@@ -128,3 +128,50 @@ func (c *Client) CaptureScreenshot(context string) (string, error) {
 
 	return result.Data, nil
 }
+
+// OnLoad subscribes to browsingContext.load and calls handler with the
+// context and URL whenever a document finishes loading. Returns a function
+// that removes the handler. Call StartEventLoop to receive loads while no
+// command is pending.
+func (c *Client) OnLoad(handler func(context, url string)) (func(), error) {
+	if err := c.ensureSubscribed("browsingContext.load"); err != nil {
+		return nil, err
+	}
+
+	return c.OnEvent("browsingContext.load", func(event *Event) {
+		var info struct {
+			Context string `json:"context"`
+			URL     string `json:"url"`
+		}
+		if err := json.Unmarshal(event.Params, &info); err != nil {
+			return
+		}
+		handler(info.Context, info.URL)
+	}), nil
+}
+
+// NavigationStartedParams are the params of the
+// browsingContext.navigationStarted event.
+type NavigationStartedParams struct {
+	Context    string `json:"context"`
+	Navigation string `json:"navigation"`
+	URL        string `json:"url"`
+}
+
+// OnNavigationStarted subscribes to browsingContext.navigationStarted and
+// calls handler whenever a navigation starts, including ones started by the
+// page (links, scripts, redirects). Returns a function that removes the
+// handler.
+func (c *Client) OnNavigationStarted(handler func(params *NavigationStartedParams)) (func(), error) {
+	if err := c.ensureSubscribed("browsingContext.navigationStarted"); err != nil {
+		return nil, err
+	}
+
+	return c.OnEvent("browsingContext.navigationStarted", func(event *Event) {
+		var params NavigationStartedParams
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return
+		}
+		handler(&params)
+	}), nil
+}
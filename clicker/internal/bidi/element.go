@@ -1,7 +1,6 @@
 package bidi
 
 import (
-	"encoding/json"
 	"fmt"
 
 	errs "github.com/vibium/clicker/internal/errors"
@@ -23,7 +22,39 @@ type BoxInfo struct {
 	Height float64 `json:"height"`
 }
 
-// FindElement finds an element by CSS selector and returns its info.
+// RefSelectorPrefix starts a selector that names a snapshot ref instead of
+// CSS, e.g. "ref=e12". Refs are kept in the page's RefRegistry.
+const RefSelectorPrefix = "ref="
+
+// RefRegistry is the Symbol.for key of the page's snapshot ref registry, an
+// object with refs (a Map of ref to WeakRef of the element), ids (a WeakMap
+// of element to ref) and next (the next ref number). It is not enumerable
+// and leaves the DOM untouched.
+const RefRegistry = "vibium.refs"
+
+// WithElementQuery wraps a function declaration so its body can call
+// queryElement(selector) and queryElements(selector). They work like
+// document.querySelector and querySelectorAll, and also resolve snapshot
+// refs (see RefSelectorPrefix), including elements inside shadow roots.
+func WithElementQuery(functionDeclaration string) string {
+	return fmt.Sprintf(`(...args) => {
+		const queryElement = (selector) => {
+			if (!selector.startsWith(%q)) return document.querySelector(selector);
+			const registry = window[Symbol.for(%q)];
+			const ref = registry && registry.refs.get(selector.slice(%d));
+			const el = ref && ref.deref();
+			return el && el.isConnected ? el : null;
+		};
+		const queryElements = (selector) => {
+			if (!selector.startsWith(%q)) return Array.from(document.querySelectorAll(selector));
+			const el = queryElement(selector);
+			return el ? [el] : [];
+		};
+		return (%s)(...args);
+	}`, RefSelectorPrefix, RefRegistry, len(RefSelectorPrefix), RefSelectorPrefix, functionDeclaration)
+}
+
+// FindElement finds an element by CSS selector or snapshot ref and returns its info.
 // If context is empty, it uses the first available context.
 func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 	// If no context provided, get the first one from the tree
@@ -38,14 +69,13 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 		context = tree.Contexts[0].Context
 	}
 
-	// JavaScript to find element and extract info as JSON string
-	// We return a JSON string to avoid BiDi's complex object serialization
+	// JavaScript to find element and extract its info
 	script := `
 		(selector) => {
-			const el = document.querySelector(selector);
+			const el = queryElement(selector);
 			if (!el) return null;
 			const rect = el.getBoundingClientRect();
-			return JSON.stringify({
+			return {
 				tag: el.tagName.toLowerCase(),
 				text: (el.textContent || '').trim().substring(0, 100),
 				box: {
@@ -54,60 +84,21 @@ func (c *Client) FindElement(context, selector string) (*ElementInfo, error) {
 					width: rect.width,
 					height: rect.height
 				}
-			});
+			};
 		}
 	`
 
-	params := map[string]interface{}{
-		"functionDeclaration": script,
-		"target":              map[string]interface{}{"context": context},
-		"arguments": []map[string]interface{}{
-			{"type": "string", "value": selector},
-		},
-		"awaitPromise":    false,
-		"resultOwnership": "root",
-	}
-
-	msg, err := c.SendCommand("script.callFunction", params)
-	if err != nil {
+	var info *ElementInfo
+	if err := c.CallFunctionInto(context, WithElementQuery(script), []interface{}{selector}, &info); err != nil {
 		return nil, err
 	}
 
-	// Parse the result
-	var callResult struct {
-		Type   string          `json:"type"`
-		Result json.RawMessage `json:"result"`
-	}
-	if err := json.Unmarshal(msg.Result, &callResult); err != nil {
-		return nil, fmt.Errorf("failed to parse script.callFunction result: %w", err)
-	}
-
-	if callResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(callResult.Result))
-	}
-
-	// Parse the remote value (string containing JSON)
-	var remoteValue struct {
-		Type  string `json:"type"`
-		Value string `json:"value,omitempty"`
-	}
-
-	if err := json.Unmarshal(callResult.Result, &remoteValue); err != nil {
-		return nil, fmt.Errorf("failed to parse remote value: %w", err)
-	}
-
 	// Check if element was found
-	if remoteValue.Type == "null" {
+	if info == nil {
 		return nil, &errs.ElementNotFoundError{Selector: selector, Context: context}
 	}
 
-	// Parse the JSON string value
-	var info ElementInfo
-	if err := json.Unmarshal([]byte(remoteValue.Value), &info); err != nil {
-		return nil, fmt.Errorf("failed to parse element info: %w", err)
-	}
-
-	return &info, nil
+	return info, nil
 }
 
 // GetElementCenter returns the center coordinates of an element's bounding box.
@@ -0,0 +1,47 @@
+package bidi
+
+import (
+	"errors"
+	"testing"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+func TestFindElement(t *testing.T) {
+	found := map[string]interface{}{
+		"type": "object",
+		"value": []interface{}{
+			[]interface{}{"tag", map[string]interface{}{"type": "string", "value": "button"}},
+			[]interface{}{"text", map[string]interface{}{"type": "string", "value": "Submit"}},
+			[]interface{}{"box", map[string]interface{}{"type": "object", "value": []interface{}{
+				[]interface{}{"x", map[string]interface{}{"type": "number", "value": 10}},
+				[]interface{}{"y", map[string]interface{}{"type": "number", "value": 20}},
+				[]interface{}{"width", map[string]interface{}{"type": "number", "value": 30}},
+				[]interface{}{"height", map[string]interface{}{"type": "number", "value": 40}},
+			}}},
+		},
+	}
+	client, _ := newFakeBrowser(t, func(cmd fakeCommand) fakeReply {
+		args, _ := cmd.Params["arguments"].([]interface{})
+		selector := args[0].(map[string]interface{})["value"]
+		if selector == "#missing" {
+			return fakeReply{Result: map[string]interface{}{"type": "success", "result": map[string]interface{}{"type": "null"}}}
+		}
+		return fakeReply{Result: map[string]interface{}{"type": "success", "result": found}}
+	})
+
+	info, err := client.FindElement("ctx-1", "#submit")
+	if err != nil {
+		t.Fatalf("FindElement() = %v", err)
+	}
+	want := ElementInfo{Tag: "button", Text: "Submit", Box: BoxInfo{X: 10, Y: 20, Width: 30, Height: 40}}
+	if *info != want {
+		t.Errorf("FindElement() = %+v, want %+v", *info, want)
+	}
+
+	_, err = client.FindElement("ctx-1", "#missing")
+	var notFound *errs.ElementNotFoundError
+	if !errors.As(err, &notFound) || notFound.Selector != "#missing" || notFound.Context != "ctx-1" {
+		t.Errorf("FindElement(#missing) = %v, want ElementNotFoundError", err)
+	}
+}
@@ -0,0 +1,294 @@
+package bidi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// DefaultUserContext is the user context of browsing contexts created
+// without one, i.e. the browser's own profile.
+const DefaultUserContext = "default"
+
+// Geolocation represents an emulated device position.
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy,omitempty"` // Meters, defaults to 1
+}
+
+// EmulationOptions configures emulated device and user preferences.
+// Empty fields are left untouched.
+type EmulationOptions struct {
+	Geolocation   *Geolocation `json:"geolocation,omitempty"`
+	Timezone      string       `json:"timezone,omitempty"`      // IANA timezone ID, e.g. "Europe/Berlin"
+	Locale        string       `json:"locale,omitempty"`        // BCP 47 language tag, e.g. "de-DE"
+	ColorScheme   string       `json:"colorScheme,omitempty"`   // "light" or "dark"
+	ReducedMotion string       `json:"reducedMotion,omitempty"` // "reduce" or "no-preference"
+}
+
+// IsEmpty returns true if no emulation override is set.
+func (o *EmulationOptions) IsEmpty() bool {
+	return o == nil || (o.Geolocation == nil && o.Timezone == "" && o.Locale == "" &&
+		o.ColorScheme == "" && o.ReducedMotion == "")
+}
+
+// Validate checks that the options contain supported values.
+func (o *EmulationOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	switch o.ColorScheme {
+	case "", "light", "dark":
+	default:
+		return fmt.Errorf("invalid colorScheme %q (expected \"light\" or \"dark\")", o.ColorScheme)
+	}
+	switch o.ReducedMotion {
+	case "", "reduce", "no-preference":
+	default:
+		return fmt.Errorf("invalid reducedMotion %q (expected \"reduce\" or \"no-preference\")", o.ReducedMotion)
+	}
+	if g := o.Geolocation; g != nil {
+		if g.Latitude < -90 || g.Latitude > 90 {
+			return fmt.Errorf("invalid latitude %v (expected -90 to 90)", g.Latitude)
+		}
+		if g.Longitude < -180 || g.Longitude > 180 {
+			return fmt.Errorf("invalid longitude %v (expected -180 to 180)", g.Longitude)
+		}
+	}
+	return nil
+}
+
+// EmulationCommand is a BiDi emulation.* command for a single override.
+type EmulationCommand struct {
+	Field  string // Option the command applies, e.g. "timezone"
+	Method string
+	Params map[string]interface{}
+}
+
+// EmulationCommands returns the BiDi emulation.* commands for the options.
+// The overrides apply to the browsing contexts if given, else to every
+// browsing context of the user contexts; with neither, to the
+// DefaultUserContext. Color scheme and reduced motion have no BiDi command
+// and are only covered by Chrome args and EmulationPreloadScript.
+func EmulationCommands(opts *EmulationOptions, contexts, userContexts []string) []EmulationCommand {
+	if opts == nil {
+		return nil
+	}
+	if len(contexts) == 0 && len(userContexts) == 0 {
+		userContexts = []string{DefaultUserContext}
+	}
+
+	withContexts := func(params map[string]interface{}) map[string]interface{} {
+		if len(contexts) > 0 {
+			params["contexts"] = contexts
+		} else {
+			params["userContexts"] = userContexts
+		}
+		return params
+	}
+
+	var cmds []EmulationCommand
+	if g := opts.Geolocation; g != nil {
+		accuracy := g.Accuracy
+		if accuracy <= 0 {
+			accuracy = 1
+		}
+		cmds = append(cmds, EmulationCommand{
+			Field:  "geolocation",
+			Method: "emulation.setGeolocationOverride",
+			Params: withContexts(map[string]interface{}{
+				"coordinates": map[string]interface{}{
+					"latitude":  g.Latitude,
+					"longitude": g.Longitude,
+					"accuracy":  accuracy,
+				},
+			}),
+		})
+	}
+	if opts.Timezone != "" {
+		cmds = append(cmds, EmulationCommand{
+			Field:  "timezone",
+			Method: "emulation.setTimezoneOverride",
+			Params: withContexts(map[string]interface{}{"timezone": opts.Timezone}),
+		})
+	}
+	if opts.Locale != "" {
+		cmds = append(cmds, EmulationCommand{
+			Field:  "locale",
+			Method: "emulation.setLocaleOverride",
+			Params: withContexts(map[string]interface{}{"locale": opts.Locale}),
+		})
+	}
+	return cmds
+}
+
+// IsUnknownCommand returns true if err is the browser's answer to a command
+// it doesn't implement.
+func IsUnknownCommand(err error) bool {
+	var protocolErr *errs.ProtocolError
+	return errors.As(err, &protocolErr) && protocolErr.Code == "unknown command"
+}
+
+// EmulationPreloadScript returns a function declaration for script.addPreloadScript
+// that emulates the given options from page JavaScript. It is used for overrides
+// the browser cannot apply natively. Returns "" if there is nothing to emulate.
+func EmulationPreloadScript(opts *EmulationOptions) string {
+	if opts.IsEmpty() {
+		return ""
+	}
+
+	data, _ := json.Marshal(opts)
+
+	return fmt.Sprintf(`() => {
+		const opts = %s;
+
+		if (opts.geolocation) {
+			const coords = {
+				latitude: opts.geolocation.latitude,
+				longitude: opts.geolocation.longitude,
+				accuracy: opts.geolocation.accuracy || 1,
+				altitude: null,
+				altitudeAccuracy: null,
+				heading: null,
+				speed: null,
+			};
+			const position = () => ({ coords, timestamp: Date.now() });
+			let nextWatchId = 1;
+			const geolocation = {
+				getCurrentPosition: (success) => setTimeout(() => success(position()), 0),
+				watchPosition: (success) => {
+					setTimeout(() => success(position()), 0);
+					return nextWatchId++;
+				},
+				clearWatch: () => {},
+			};
+			Object.defineProperty(Navigator.prototype, 'geolocation', {
+				get: () => geolocation,
+				configurable: true,
+			});
+		}
+
+		if (opts.locale) {
+			const languages = Object.freeze([opts.locale]);
+			Object.defineProperty(Navigator.prototype, 'language', {
+				get: () => opts.locale,
+				configurable: true,
+			});
+			Object.defineProperty(Navigator.prototype, 'languages', {
+				get: () => languages,
+				configurable: true,
+			});
+		}
+
+		if (opts.timezone) {
+			// An unknown timezone would make every DateTimeFormat throw
+			try {
+				new Intl.DateTimeFormat(undefined, { timeZone: opts.timezone });
+			} catch (e) {
+				opts.timezone = '';
+			}
+		}
+
+		if (opts.timezone || opts.locale) {
+			const OriginalDateTimeFormat = Intl.DateTimeFormat;
+			const DateTimeFormat = function (locales, options) {
+				const o = Object.assign({}, options);
+				if (opts.timezone && !o.timeZone) o.timeZone = opts.timezone;
+				return new OriginalDateTimeFormat(locales || opts.locale || undefined, o);
+			};
+			DateTimeFormat.prototype = OriginalDateTimeFormat.prototype;
+			DateTimeFormat.supportedLocalesOf = OriginalDateTimeFormat.supportedLocalesOf;
+			Intl.DateTimeFormat = DateTimeFormat;
+		}
+
+		if (opts.colorScheme || opts.reducedMotion) {
+			const originalMatchMedia = window.matchMedia.bind(window);
+			const override = (query) => {
+				const q = query.replace(/\s+/g, '');
+				if (opts.colorScheme) {
+					const m = q.match(/\(prefers-color-scheme:(light|dark)\)/);
+					if (m) return m[1] === opts.colorScheme;
+				}
+				if (opts.reducedMotion) {
+					const m = q.match(/\(prefers-reduced-motion:(reduce|no-preference)\)/);
+					if (m) return m[1] === opts.reducedMotion;
+				}
+				return null;
+			};
+			window.matchMedia = (query) => {
+				const result = originalMatchMedia(query);
+				const matches = override(String(query));
+				if (matches === null) return result;
+				return Object.assign(Object.create(result), {
+					matches,
+					media: result.media,
+					addListener: () => {},
+					removeListener: () => {},
+					addEventListener: () => {},
+					removeEventListener: () => {},
+				});
+			};
+		}
+	}`, string(data))
+}
+
+// ApplyEmulation applies emulation overrides to the browser's default user
+// context, e.g. the LaunchResult.Emulation requested at launch.
+// BiDi emulation.* commands are used where the browser supports them; the
+// remaining overrides are installed as a preload script for new documents
+// and run once in the existing browsing contexts.
+func (c *Client) ApplyEmulation(opts *EmulationOptions) error {
+	if opts.IsEmpty() {
+		return nil
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	fallback := &EmulationOptions{
+		ColorScheme:   opts.ColorScheme,
+		ReducedMotion: opts.ReducedMotion,
+	}
+
+	for _, cmd := range EmulationCommands(opts, nil, nil) {
+		if _, err := c.SendCommand(cmd.Method, cmd.Params); err != nil {
+			// Older browsers don't implement emulation.*, emulate from script instead
+			if !IsUnknownCommand(err) {
+				return fmt.Errorf("failed to emulate %s: %w", cmd.Field, err)
+			}
+			switch cmd.Field {
+			case "geolocation":
+				fallback.Geolocation = opts.Geolocation
+			case "timezone":
+				fallback.Timezone = opts.Timezone
+			case "locale":
+				fallback.Locale = opts.Locale
+			}
+		}
+	}
+
+	script := EmulationPreloadScript(fallback)
+	if script == "" {
+		return nil
+	}
+
+	if _, err := c.AddPreloadScript(script); err != nil {
+		return fmt.Errorf("failed to add emulation preload script: %w", err)
+	}
+
+	// Preload scripts only run in new documents, so apply to the current ones too
+	tree, err := c.GetTree()
+	if err != nil {
+		return fmt.Errorf("failed to get browsing context: %w", err)
+	}
+	for _, ctx := range tree.Contexts {
+		if _, err := c.CallFunction(ctx.Context, script, nil); err != nil {
+			return fmt.Errorf("failed to apply emulation: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package bidi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmulationCommandsTarget(t *testing.T) {
+	opts := &EmulationOptions{Timezone: "Europe/Berlin"}
+
+	tests := []struct {
+		name         string
+		contexts     []string
+		userContexts []string
+		wantKey      string
+		want         []string
+	}{
+		{"default user context", nil, nil, "userContexts", []string{DefaultUserContext}},
+		{"user contexts", nil, []string{"uc-1"}, "userContexts", []string{"uc-1"}},
+		{"contexts", []string{"ctx-1"}, nil, "contexts", []string{"ctx-1"}},
+		{"contexts win", []string{"ctx-1"}, []string{"uc-1"}, "contexts", []string{"ctx-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds := EmulationCommands(opts, tt.contexts, tt.userContexts)
+			if len(cmds) != 1 {
+				t.Fatalf("got %d commands, want 1", len(cmds))
+			}
+			params := cmds[0].Params
+			if got := params[tt.wantKey]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.wantKey, got, tt.want)
+			}
+			_, hasContexts := params["contexts"]
+			_, hasUserContexts := params["userContexts"]
+			if hasContexts && hasUserContexts {
+				t.Errorf("params have both contexts and userContexts: %v", params)
+			}
+		})
+	}
+}
+
+func TestEmulationCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *EmulationOptions
+		methods []string
+	}{
+		{"nil", nil, nil},
+		{"script only", &EmulationOptions{ColorScheme: "dark", ReducedMotion: "reduce"}, nil},
+		{
+			"all",
+			&EmulationOptions{
+				Geolocation: &Geolocation{Latitude: 52.52, Longitude: 13.4},
+				Timezone:    "Europe/Berlin",
+				Locale:      "de-DE",
+				ColorScheme: "dark",
+			},
+			[]string{"emulation.setGeolocationOverride", "emulation.setTimezoneOverride", "emulation.setLocaleOverride"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var methods []string
+			for _, cmd := range EmulationCommands(tt.opts, nil, nil) {
+				methods = append(methods, cmd.Method)
+			}
+			if !reflect.DeepEqual(methods, tt.methods) {
+				t.Errorf("methods = %v, want %v", methods, tt.methods)
+			}
+		})
+	}
+}
+
+func TestEmulationGeolocationAccuracy(t *testing.T) {
+	cmds := EmulationCommands(&EmulationOptions{Geolocation: &Geolocation{Latitude: 1, Longitude: 2}}, nil, nil)
+	coords := cmds[0].Params["coordinates"].(map[string]interface{})
+	if coords["accuracy"] != float64(1) {
+		t.Errorf("accuracy = %v, want 1", coords["accuracy"])
+	}
+}
+
+func TestEmulationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *EmulationOptions
+		wantErr string
+	}{
+		{"nil", nil, ""},
+		{"valid", &EmulationOptions{ColorScheme: "light", ReducedMotion: "no-preference", Geolocation: &Geolocation{Latitude: -90, Longitude: 180}}, ""},
+		{"color scheme", &EmulationOptions{ColorScheme: "blue"}, "invalid colorScheme"},
+		{"reduced motion", &EmulationOptions{ReducedMotion: "some"}, "invalid reducedMotion"},
+		{"latitude", &EmulationOptions{Geolocation: &Geolocation{Latitude: 91}}, "invalid latitude"},
+		{"longitude", &EmulationOptions{Geolocation: &Geolocation{Longitude: -181}}, "invalid longitude"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEmulation(t *testing.T) {
+	tree := map[string]interface{}{
+		"contexts": []interface{}{map[string]interface{}{"context": "ctx-1", "url": "about:blank", "children": []interface{}{}}},
+	}
+	evaluated := map[string]interface{}{"type": "success", "result": map[string]interface{}{"type": "undefined"}, "realm": "r"}
+
+	tests := []struct {
+		name string
+		// Error code of the fake browser for emulation.* commands ("" = success)
+		emulationError string
+		opts           *EmulationOptions
+		wantErr        string
+		wantMethods    []string
+		wantFallback   string // Expected in the preload script
+	}{
+		{
+			name:        "native",
+			opts:        &EmulationOptions{Timezone: "Europe/Berlin", Locale: "de-DE"},
+			wantMethods: []string{"emulation.setTimezoneOverride", "emulation.setLocaleOverride"},
+		},
+		{
+			name:           "unsupported falls back to script",
+			emulationError: "unknown command",
+			opts:           &EmulationOptions{Timezone: "Europe/Berlin"},
+			wantMethods:    []string{"emulation.setTimezoneOverride", "script.addPreloadScript", "browsingContext.getTree", "script.callFunction"},
+			wantFallback:   `"timezone":"Europe/Berlin"`,
+		},
+		{
+			name:           "invalid timezone is an error",
+			emulationError: "invalid argument",
+			opts:           &EmulationOptions{Timezone: "Mars/Olympus"},
+			wantErr:        "failed to emulate timezone",
+			wantMethods:    []string{"emulation.setTimezoneOverride"},
+		},
+		{
+			name:         "color scheme from script",
+			opts:         &EmulationOptions{ColorScheme: "dark"},
+			wantMethods:  []string{"script.addPreloadScript", "browsingContext.getTree", "script.callFunction"},
+			wantFallback: `"colorScheme":"dark"`,
+		},
+		{
+			name:    "invalid options",
+			opts:    &EmulationOptions{ColorScheme: "blue"},
+			wantErr: "invalid colorScheme",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, browser := newFakeBrowser(t, func(cmd fakeCommand) fakeReply {
+				switch {
+				case strings.HasPrefix(cmd.Method, "emulation.") && tt.emulationError != "":
+					return fakeReply{Error: tt.emulationError, Message: "rejected"}
+				case cmd.Method == "script.addPreloadScript":
+					return fakeReply{Result: map[string]interface{}{"script": "s-1"}}
+				case cmd.Method == "browsingContext.getTree":
+					return fakeReply{Result: tree}
+				case cmd.Method == "script.callFunction":
+					return fakeReply{Result: evaluated}
+				}
+				return fakeReply{}
+			})
+
+			err := client.ApplyEmulation(tt.opts)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ApplyEmulation() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ApplyEmulation() = %v, want %q", err, tt.wantErr)
+			}
+
+			var methods []string
+			for _, cmd := range browser.received() {
+				methods = append(methods, cmd.Method)
+				if strings.HasPrefix(cmd.Method, "emulation.") {
+					if _, ok := cmd.Params["userContexts"]; !ok {
+						t.Errorf("%s without userContexts: %v", cmd.Method, cmd.Params)
+					}
+				}
+				if cmd.Method == "script.addPreloadScript" && !strings.Contains(cmd.Params["functionDeclaration"].(string), tt.wantFallback) {
+					t.Errorf("preload script lacks %s", tt.wantFallback)
+				}
+			}
+			if !reflect.DeepEqual(methods, tt.wantMethods) {
+				t.Errorf("commands = %v, want %v", methods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestIsUnknownCommand(t *testing.T) {
+	client, _ := newFakeBrowser(t, func(cmd fakeCommand) fakeReply {
+		if cmd.Method == "emulation.setLocaleOverride" {
+			return fakeReply{Error: "unknown command", Message: "not implemented"}
+		}
+		return fakeReply{Error: "invalid argument", Message: "bad"}
+	})
+
+	_, err := client.SendCommand("emulation.setLocaleOverride", map[string]interface{}{})
+	if !IsUnknownCommand(err) {
+		t.Errorf("IsUnknownCommand(%v) = false", err)
+	}
+	_, err = client.SendCommand("emulation.setTimezoneOverride", map[string]interface{}{})
+	if IsUnknownCommand(err) {
+		t.Errorf("IsUnknownCommand(%v) = true", err)
+	}
+	if !strings.Contains(err.Error(), "invalid argument: bad") {
+		t.Errorf("error = %q, want code and message", err)
+	}
+}
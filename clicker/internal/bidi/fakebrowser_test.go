@@ -0,0 +1,114 @@
+package bidi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeCommand is a command the fake browser received.
+type fakeCommand struct {
+	Method string
+	Params map[string]interface{}
+}
+
+// fakeReply is the fake browser's answer to a command: a result, or an
+// error code with a message. Events are sent before the answer.
+type fakeReply struct {
+	Result  interface{}
+	Error   string
+	Message string
+	Events  []fakeEvent
+}
+
+// fakeEvent is an event the fake browser sends.
+type fakeEvent struct {
+	Method string
+	Params interface{}
+}
+
+// fakeBrowser is a WebDriver BiDi endpoint answering commands with a
+// function of the test.
+type fakeBrowser struct {
+	mu       sync.Mutex
+	commands []fakeCommand
+}
+
+// received returns the commands received so far.
+func (b *fakeBrowser) received() []fakeCommand {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeCommand(nil), b.commands...)
+}
+
+// newFakeBrowser starts a fake browser and returns a client connected to it.
+func newFakeBrowser(t *testing.T, answer func(cmd fakeCommand) fakeReply) (*Client, *fakeBrowser) {
+	t.Helper()
+
+	b := &fakeBrowser{}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg struct {
+				ID     int64                  `json:"id"`
+				Method string                 `json:"method"`
+				Params map[string]interface{} `json:"params"`
+			}
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			cmd := fakeCommand{Method: msg.Method, Params: msg.Params}
+			b.mu.Lock()
+			b.commands = append(b.commands, cmd)
+			b.mu.Unlock()
+
+			reply := answer(cmd)
+			for _, event := range reply.Events {
+				if err := conn.WriteJSON(map[string]interface{}{
+					"type":   "event",
+					"method": event.Method,
+					"params": event.Params,
+				}); err != nil {
+					return
+				}
+			}
+			resp := map[string]interface{}{"id": msg.ID}
+			if reply.Error != "" {
+				resp["type"] = "error"
+				resp["error"] = reply.Error
+				resp["message"] = reply.Message
+			} else {
+				resp["type"] = "success"
+				if reply.Result == nil {
+					reply.Result = map[string]interface{}{}
+				}
+				resp["result"] = reply.Result
+			}
+			if err := conn.WriteJSON(resp); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := Connect("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn), b
+}
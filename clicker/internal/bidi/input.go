@@ -206,7 +206,7 @@ func (c *Client) GetElementValue(context, selector string) (string, error) {
 		context = tree.Contexts[0].Context
 	}
 
-	result, err := c.Evaluate(context, fmt.Sprintf(`document.querySelector(%q)?.value || ''`, selector))
+	result, err := c.CallFunction(context, WithElementQuery(`(selector) => queryElement(selector)?.value || ''`), []interface{}{selector})
 	if err != nil {
 		return "", err
 	}
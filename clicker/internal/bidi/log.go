@@ -0,0 +1,36 @@
+package bidi
+
+import (
+	"encoding/json"
+)
+
+// LogEntry is a console message or uncaught JavaScript error reported by
+// the log.entryAdded event.
+type LogEntry struct {
+	Type      string `json:"type"`             // "console" or "javascript"
+	Level     string `json:"level"`            // "debug", "info", "warn" or "error"
+	Method    string `json:"method,omitempty"` // Console method, e.g. "log" or "warn"
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"` // Milliseconds since the epoch
+	Source    struct {
+		Context string `json:"context,omitempty"`
+	} `json:"source"`
+}
+
+// OnLogEntry subscribes to log.entryAdded and calls handler for every console
+// message and uncaught error in any browsing context. Returns a function that
+// removes the handler. Call StartEventLoop to receive entries while no command
+// is pending.
+func (c *Client) OnLogEntry(handler func(entry *LogEntry)) (func(), error) {
+	if err := c.ensureSubscribed("log.entryAdded"); err != nil {
+		return nil, err
+	}
+
+	return c.OnEvent("log.entryAdded", func(event *Event) {
+		var entry LogEntry
+		if err := json.Unmarshal(event.Params, &entry); err != nil {
+			return
+		}
+		handler(&entry)
+	}), nil
+}
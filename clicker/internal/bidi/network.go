@@ -0,0 +1,109 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BeforeRequestSentParams are the params of the network.beforeRequestSent
+// event.
+type BeforeRequestSentParams struct {
+	Context    string   `json:"context"`
+	Navigation *string  `json:"navigation"` // Set for document requests of a navigation
+	IsBlocked  bool     `json:"isBlocked"`  // Paused by an intercept until continued or failed
+	Intercepts []string `json:"intercepts,omitempty"`
+	Request    struct {
+		Request string `json:"request"`
+		URL     string `json:"url"`
+		Method  string `json:"method"`
+	} `json:"request"`
+}
+
+// BlockedBy returns true if the request is paused by the given intercept.
+func (p *BeforeRequestSentParams) BlockedBy(intercept string) bool {
+	if !p.IsBlocked {
+		return false
+	}
+	for _, id := range p.Intercepts {
+		if id == intercept {
+			return true
+		}
+	}
+	return false
+}
+
+// AddInterceptResult represents the result of network.addIntercept.
+type AddInterceptResult struct {
+	Intercept string `json:"intercept"`
+}
+
+// AddIntercept pauses matching requests in the given phases (e.g.
+// "beforeRequestSent") until ContinueRequest or FailRequest is called.
+// With no URL patterns, all requests are intercepted. Returns the intercept ID.
+func (c *Client) AddIntercept(phases []string, urlPatterns []string) (string, error) {
+	params := map[string]interface{}{
+		"phases": phases,
+	}
+	if len(urlPatterns) > 0 {
+		patterns := make([]map[string]interface{}, len(urlPatterns))
+		for i, pattern := range urlPatterns {
+			patterns[i] = map[string]interface{}{"type": "string", "pattern": pattern}
+		}
+		params["urlPatterns"] = patterns
+	}
+
+	msg, err := c.SendCommand("network.addIntercept", params)
+	if err != nil {
+		return "", err
+	}
+
+	var result AddInterceptResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse network.addIntercept result: %w", err)
+	}
+
+	return result.Intercept, nil
+}
+
+// RemoveIntercept removes an intercept added with AddIntercept.
+func (c *Client) RemoveIntercept(intercept string) error {
+	_, err := c.SendCommand("network.removeIntercept", map[string]interface{}{
+		"intercept": intercept,
+	})
+	return err
+}
+
+// ContinueRequest lets a paused request proceed unchanged.
+func (c *Client) ContinueRequest(request string) error {
+	_, err := c.SendCommand("network.continueRequest", map[string]interface{}{
+		"request": request,
+	})
+	return err
+}
+
+// FailRequest fails a paused request with a network error.
+func (c *Client) FailRequest(request string) error {
+	_, err := c.SendCommand("network.failRequest", map[string]interface{}{
+		"request": request,
+	})
+	return err
+}
+
+// OnBeforeRequestSent subscribes to network.beforeRequestSent and calls
+// handler for every request. Requests paused by an intercept must be
+// continued or failed; handlers run on the goroutine reading from the
+// connection, so do that from another goroutine. Returns a function that
+// removes the handler.
+func (c *Client) OnBeforeRequestSent(handler func(params *BeforeRequestSentParams)) (func(), error) {
+	if err := c.ensureSubscribed("network.beforeRequestSent"); err != nil {
+		return nil, err
+	}
+
+	return c.OnEvent("network.beforeRequestSent", func(event *Event) {
+		var params BeforeRequestSentParams
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return
+		}
+		handler(&params)
+	}), nil
+}
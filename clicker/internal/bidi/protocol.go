@@ -46,6 +46,9 @@ type Message struct {
 	Result json.RawMessage `json:"result,omitempty"`
 	Error  json.RawMessage `json:"error,omitempty"`
 
+	// Error message, next to the error code in WebDriver BiDi error responses
+	ErrorMessage string `json:"message,omitempty"`
+
 	// Event fields
 	Method string          `json:"method,omitempty"`
 	Params json.RawMessage `json:"params,omitempty"`
@@ -80,7 +83,11 @@ func (m *Message) GetError() (*ErrorData, error) {
 		if err := json.Unmarshal(m.Error, &errStr); err != nil {
 			return nil, err
 		}
-		return &ErrorData{Error: errStr, Message: errStr}, nil
+		message := m.ErrorMessage
+		if message == "" {
+			message = errStr
+		}
+		return &ErrorData{Error: errStr, Message: message}, nil
 	}
 	return &errData, nil
 }
@@ -0,0 +1,537 @@
+package bidi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Deserialized RemoteValues map to Go values as follows:
+//
+//	undefined, null          -> nil
+//	string, boolean          -> string, bool
+//	number                   -> float64 (including NaN, ±Infinity and -0)
+//	bigint                   -> *big.Int
+//	array                    -> []interface{}
+//	set                      -> Set
+//	nodelist, htmlcollection -> []interface{} of *NodeRef
+//	object                   -> map[string]interface{}
+//	map                      -> Map (keeps non-string keys and order)
+//	date                     -> time.Time (the raw string if Go can't parse it)
+//	regexp                   -> *RegExp
+//	node                     -> *NodeRef
+//	window                   -> *WindowRef
+//	anything else            -> *RemoteReference (functions, promises, errors, ...)
+
+// Map is a deserialized JavaScript Map, in insertion order.
+type Map []MapEntry
+
+// MapEntry is a single key/value pair of a Map.
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Get returns the value for a key and whether it was found.
+func (m Map) Get(key interface{}) (interface{}, bool) {
+	for _, e := range m {
+		if reflect.DeepEqual(e.Key, key) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set is a deserialized JavaScript Set, in insertion order. It serializes
+// back as a Set rather than an array.
+type Set []interface{}
+
+// RegExp is a deserialized JavaScript regular expression.
+type RegExp struct {
+	Pattern string `json:"pattern"`
+	Flags   string `json:"flags,omitempty"`
+}
+
+// String returns the regexp in JavaScript literal form.
+func (r *RegExp) String() string {
+	return "/" + r.Pattern + "/" + r.Flags
+}
+
+// NodeRef is a reference to a DOM node. Its SharedID can be passed back to
+// script.callFunction to refer to the same node.
+type NodeRef struct {
+	SharedID   string            `json:"sharedId,omitempty"`
+	Handle     string            `json:"handle,omitempty"`
+	NodeType   int               `json:"nodeType"`
+	LocalName  string            `json:"localName,omitempty"`
+	NodeValue  string            `json:"nodeValue,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	ChildCount int               `json:"childNodeCount"`
+}
+
+// WindowRef is a reference to a window proxy.
+type WindowRef struct {
+	Context string `json:"context"`
+}
+
+// RemoteReference is a value that cannot be copied into Go, such as a
+// function or promise. It can be passed back to the browser if it has a
+// Handle. Repeated objects within one result carry only an InternalID.
+type RemoteReference struct {
+	Type       string `json:"type"`
+	Handle     string `json:"handle,omitempty"`
+	InternalID string `json:"internalId,omitempty"`
+}
+
+// remoteValue is the wire form of a script.RemoteValue.
+type remoteValue struct {
+	Type       string          `json:"type"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Handle     string          `json:"handle,omitempty"`
+	InternalID string          `json:"internalId,omitempty"`
+	SharedID   string          `json:"sharedId,omitempty"`
+}
+
+// DeserializeRemoteValue converts a script.RemoteValue into a Go value.
+func DeserializeRemoteValue(raw json.RawMessage) (interface{}, error) {
+	var rv remoteValue
+	if err := json.Unmarshal(raw, &rv); err != nil {
+		return nil, fmt.Errorf("failed to parse remote value: %w", err)
+	}
+
+	switch rv.Type {
+	case "undefined", "null":
+		return nil, nil
+
+	case "string":
+		var s string
+		if err := json.Unmarshal(rv.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid string value: %w", err)
+		}
+		return s, nil
+
+	case "boolean":
+		var b bool
+		if err := json.Unmarshal(rv.Value, &b); err != nil {
+			return nil, fmt.Errorf("invalid boolean value: %w", err)
+		}
+		return b, nil
+
+	case "number":
+		return deserializeNumber(rv.Value)
+
+	case "bigint":
+		var s string
+		if err := json.Unmarshal(rv.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid bigint value: %w", err)
+		}
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid bigint value: %q", s)
+		}
+		return n, nil
+
+	case "date":
+		var s string
+		if err := json.Unmarshal(rv.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid date value: %w", err)
+		}
+		// Extended years (e.g. "+275760-09-13T00:00:00.000Z") and
+		// "Invalid Date" don't fit time.Time; keep the browser's string
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return s, nil
+		}
+		return t, nil
+
+	case "regexp":
+		var re RegExp
+		if err := json.Unmarshal(rv.Value, &re); err != nil {
+			return nil, fmt.Errorf("invalid regexp value: %w", err)
+		}
+		return &re, nil
+	}
+
+	// Container and reference types. A repeated object is sent only once;
+	// later occurrences have no value, just the internalId.
+	if rv.Value == nil {
+		if rv.Type == "node" && rv.SharedID != "" {
+			return &NodeRef{SharedID: rv.SharedID, Handle: rv.Handle}, nil
+		}
+		return &RemoteReference{Type: rv.Type, Handle: rv.Handle, InternalID: rv.InternalID}, nil
+	}
+
+	switch rv.Type {
+	case "array", "nodelist", "htmlcollection":
+		return deserializeList(rv.Value)
+
+	case "set":
+		list, err := deserializeList(rv.Value)
+		if err != nil {
+			return nil, err
+		}
+		return Set(list), nil
+
+	case "object":
+		entries, err := deserializeEntries(rv.Value)
+		if err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			obj[fmt.Sprint(e.Key)] = e.Value
+		}
+		return obj, nil
+
+	case "map":
+		entries, err := deserializeEntries(rv.Value)
+		if err != nil {
+			return nil, err
+		}
+		return Map(entries), nil
+
+	case "node":
+		node := NodeRef{SharedID: rv.SharedID, Handle: rv.Handle}
+		if err := json.Unmarshal(rv.Value, &node); err != nil {
+			return nil, fmt.Errorf("invalid node value: %w", err)
+		}
+		return &node, nil
+
+	case "window":
+		var w WindowRef
+		if err := json.Unmarshal(rv.Value, &w); err != nil {
+			return nil, fmt.Errorf("invalid window value: %w", err)
+		}
+		return &w, nil
+
+	default:
+		return &RemoteReference{Type: rv.Type, Handle: rv.Handle, InternalID: rv.InternalID}, nil
+	}
+}
+
+// deserializeNumber handles numbers, including the special values BiDi
+// sends as strings.
+func deserializeNumber(raw json.RawMessage) (interface{}, error) {
+	var special string
+	if err := json.Unmarshal(raw, &special); err == nil {
+		switch special {
+		case "NaN":
+			return math.NaN(), nil
+		case "-0":
+			return math.Copysign(0, -1), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		default:
+			return nil, fmt.Errorf("invalid number value: %q", special)
+		}
+	}
+
+	var f float64
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("invalid number value: %w", err)
+	}
+	return f, nil
+}
+
+// deserializeList handles a ListRemoteValue.
+func deserializeList(raw json.RawMessage) ([]interface{}, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("invalid list value: %w", err)
+	}
+
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		v, err := DeserializeRemoteValue(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// deserializeEntries handles a MappingRemoteValue, a list of [key, value]
+// pairs where keys are strings or RemoteValues.
+func deserializeEntries(raw json.RawMessage) ([]MapEntry, error) {
+	var pairs [][2]json.RawMessage
+	if err := json.Unmarshal(raw, &pairs); err != nil {
+		return nil, fmt.Errorf("invalid mapping value: %w", err)
+	}
+
+	entries := make([]MapEntry, 0, len(pairs))
+	for _, pair := range pairs {
+		var key interface{}
+		var s string
+		if err := json.Unmarshal(pair[0], &s); err == nil {
+			key = s
+		} else {
+			k, err := DeserializeRemoteValue(pair[0])
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		}
+
+		value, err := DeserializeRemoteValue(pair[1])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, MapEntry{Key: key, Value: value})
+	}
+	return entries, nil
+}
+
+// serializeValue converts a Go value to a BiDi serialized LocalValue.
+// It is the inverse of DeserializeRemoteValue: values returned by the
+// browser can be passed back as arguments. References without a handle or
+// shared ID (e.g. results of resultOwnership "none") can't be passed back.
+func serializeValue(v interface{}) (map[string]interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return map[string]interface{}{"type": "undefined"}, nil
+	case bool:
+		return map[string]interface{}{"type": "boolean", "value": val}, nil
+	case string:
+		return map[string]interface{}{"type": "string", "value": val}, nil
+	case float32:
+		return serializeNumber(float64(val)), nil
+	case float64:
+		return serializeNumber(val), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return map[string]interface{}{"type": "number", "value": val}, nil
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return serializeNumber(f), nil
+		}
+		return map[string]interface{}{"type": "string", "value": val.String()}, nil
+	case *big.Int:
+		if val == nil {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		return map[string]interface{}{"type": "bigint", "value": val.String()}, nil
+	case time.Time:
+		return map[string]interface{}{"type": "date", "value": val.UTC().Format("2006-01-02T15:04:05.000Z")}, nil
+	case *RegExp:
+		if val == nil {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		re := map[string]interface{}{"pattern": val.Pattern}
+		if val.Flags != "" {
+			re["flags"] = val.Flags
+		}
+		return map[string]interface{}{"type": "regexp", "value": re}, nil
+	case RegExp:
+		return serializeValue(&val)
+	case *NodeRef:
+		if val == nil {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		return serializeReference("node", val.SharedID, val.Handle)
+	case *RemoteReference:
+		if val == nil {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		return serializeReference(val.Type, "", val.Handle)
+	case Map:
+		pairs := make([]interface{}, 0, len(val))
+		for _, e := range val {
+			key, err := serializeMapKey(e.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := serializeValue(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, []interface{}{key, value})
+		}
+		return map[string]interface{}{"type": "map", "value": pairs}, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]interface{}, 0, len(val))
+		for _, k := range keys {
+			value, err := serializeValue(val[k])
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, []interface{}{k, value})
+		}
+		return map[string]interface{}{"type": "object", "value": pairs}, nil
+	case []interface{}:
+		items := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			value, err := serializeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return map[string]interface{}{"type": "array", "value": items}, nil
+	case Set:
+		list, err := serializeValue([]interface{}(val))
+		if err != nil {
+			return nil, err
+		}
+		list["type"] = "set"
+		return list, nil
+	}
+
+	// Other slices, maps and structs: convert via reflection or JSON
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		return serializeValue(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		items := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+		return serializeValue(items)
+	case reflect.Map:
+		if rv.IsNil() {
+			return map[string]interface{}{"type": "null"}, nil
+		}
+		if rv.Type().Key().Kind() == reflect.String {
+			obj := make(map[string]interface{}, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				obj[iter.Key().String()] = iter.Value().Interface()
+			}
+			return serializeValue(obj)
+		}
+	case reflect.Struct:
+		// Use the struct's JSON representation
+		if data, err := json.Marshal(v); err == nil {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(data, &obj); err == nil {
+				return serializeValue(obj)
+			}
+		}
+	}
+
+	// Fallback: serialize as string
+	return map[string]interface{}{"type": "string", "value": fmt.Sprintf("%v", v)}, nil
+}
+
+// serializeReference serializes a RemoteReference to a browser object.
+func serializeReference(typ, sharedID, handle string) (map[string]interface{}, error) {
+	ref := map[string]interface{}{}
+	if sharedID != "" {
+		ref["sharedId"] = sharedID
+	}
+	if handle != "" {
+		ref["handle"] = handle
+	}
+	if len(ref) == 0 {
+		return nil, fmt.Errorf("cannot pass %s back to the browser: it has no handle", typ)
+	}
+	return ref, nil
+}
+
+// serializeNumber serializes a float, using BiDi's special values where needed.
+func serializeNumber(f float64) map[string]interface{} {
+	switch {
+	case math.IsNaN(f):
+		return map[string]interface{}{"type": "number", "value": "NaN"}
+	case math.IsInf(f, 1):
+		return map[string]interface{}{"type": "number", "value": "Infinity"}
+	case math.IsInf(f, -1):
+		return map[string]interface{}{"type": "number", "value": "-Infinity"}
+	case f == 0 && math.Signbit(f):
+		return map[string]interface{}{"type": "number", "value": "-0"}
+	default:
+		return map[string]interface{}{"type": "number", "value": f}
+	}
+}
+
+// serializeMapKey serializes a Map key. String keys are sent as-is.
+func serializeMapKey(key interface{}) (interface{}, error) {
+	if s, ok := key.(string); ok {
+		return s, nil
+	}
+	return serializeValue(key)
+}
+
+// UnmarshalRemoteValue decodes a script.RemoteValue into out via its JSON
+// form, e.g. to fill a struct from a returned object.
+func UnmarshalRemoteValue(raw json.RawMessage, out interface{}) error {
+	v, err := DeserializeRemoteValue(raw)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode remote value: %w", err)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// JSONValue converts a deserialized value into one encoding/json can
+// marshal: special numbers and bigints become strings, Maps with string keys
+// become objects (other Maps become [key, value] pairs), Sets become arrays,
+// and regexps use their literal form.
+func JSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(val):
+			return "NaN"
+		case math.IsInf(val, 1):
+			return "Infinity"
+		case math.IsInf(val, -1):
+			return "-Infinity"
+		}
+		return val
+	case *big.Int:
+		return val.String() + "n"
+	case *RegExp:
+		return val.String()
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = JSONValue(item)
+		}
+		return out
+	case Set:
+		return JSONValue([]interface{}(val))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = JSONValue(item)
+		}
+		return out
+	case Map:
+		obj := make(map[string]interface{}, len(val))
+		for _, e := range val {
+			k, ok := e.Key.(string)
+			if !ok {
+				pairs := make([]interface{}, len(val))
+				for i, e := range val {
+					pairs[i] = []interface{}{JSONValue(e.Key), JSONValue(e.Value)}
+				}
+				return pairs
+			}
+			obj[k] = JSONValue(e.Value)
+		}
+		return obj
+	default:
+		return v
+	}
+}
@@ -0,0 +1,237 @@
+package bidi
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadRemoteValues reads the RemoteValue fixtures by name.
+func loadRemoteValues(t *testing.T) map[string]json.RawMessage {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/remote_values.json")
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	var fixtures map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("failed to parse fixtures: %v", err)
+	}
+	return fixtures
+}
+
+func TestDeserializeRemoteValue(t *testing.T) {
+	fixtures := loadRemoteValues(t)
+	bigint, _ := new(big.Int).SetString("9007199254740993", 10)
+
+	tests := []struct {
+		fixture string
+		want    interface{}
+	}{
+		{"undefined", nil},
+		{"null", nil},
+		{"string", "héllo"},
+		{"boolean", true},
+		{"number", 42.5},
+		{"infinity", math.Inf(1)},
+		{"negative infinity", math.Inf(-1)},
+		{"bigint", bigint},
+		{"date", time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)},
+		{"regexp", &RegExp{Pattern: "a+b", Flags: "gi"}},
+		{"array", []interface{}{1.0, "two", nil}},
+		{"set", Set{"a"}},
+		{"object", map[string]interface{}{"a": 1.0, "nested": map[string]interface{}{"b": false}}},
+		{"map", Map{{Key: 1.0, Value: "one"}, {Key: "two", Value: 2.0}}},
+		{"node", &NodeRef{SharedID: "f.6A35E1.d.0F1B4E.e.7", NodeType: 1, LocalName: "button", ChildCount: 1, Attributes: map[string]string{"id": "submit"}}},
+		{"nodelist", []interface{}{&NodeRef{SharedID: "f.6A35E1.d.0F1B4E.e.8", NodeType: 3, NodeValue: "text"}}},
+		{"window", &WindowRef{Context: "6A35E1"}},
+		{"function", &RemoteReference{Type: "function"}},
+		{"promise with handle", &RemoteReference{Type: "promise", Handle: "6.123.4"}},
+		{"repeated object", []interface{}{map[string]interface{}{}, &RemoteReference{Type: "object", InternalID: "1"}}},
+		{"error", &RemoteReference{Type: "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw, ok := fixtures[tt.fixture]
+			if !ok {
+				t.Fatalf("no fixture %q", tt.fixture)
+			}
+			got, err := DeserializeRemoteValue(raw)
+			if err != nil {
+				t.Fatalf("DeserializeRemoteValue() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeserializeRemoteValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeSpecialNumbers(t *testing.T) {
+	fixtures := loadRemoteValues(t)
+
+	got, err := DeserializeRemoteValue(fixtures["nan"])
+	if f, ok := got.(float64); err != nil || !ok || !math.IsNaN(f) {
+		t.Errorf("nan = %v, %v", got, err)
+	}
+	got, err = DeserializeRemoteValue(fixtures["negative zero"])
+	if f, ok := got.(float64); err != nil || !ok || f != 0 || !math.Signbit(f) {
+		t.Errorf("negative zero = %v, %v", got, err)
+	}
+}
+
+// TestDeserializeUnparsableDates checks that dates time.Time can't hold
+// come back as the browser's string instead of failing the whole result.
+func TestDeserializeUnparsableDates(t *testing.T) {
+	for _, s := range []string{"+275760-09-13T00:00:00.000Z", "Invalid Date"} {
+		raw, _ := json.Marshal(map[string]string{"type": "date", "value": s})
+		got, err := DeserializeRemoteValue(raw)
+		if err != nil || got != s {
+			t.Errorf("DeserializeRemoteValue(%s) = %#v, %v, want %q", raw, got, err, s)
+		}
+	}
+}
+
+func TestDeserializeRemoteValueErrors(t *testing.T) {
+	tests := []string{
+		`{"type": "number", "value": "one"}`,
+		`{"type": "bigint", "value": "1.5"}`,
+		`{"type": "string", "value": 1}`,
+		`{"type": "array", "value": [{"type": "number", "value": "x"}]}`,
+		`not json`,
+	}
+	for _, raw := range tests {
+		if _, err := DeserializeRemoteValue(json.RawMessage(raw)); err == nil {
+			t.Errorf("DeserializeRemoteValue(%s) = nil error", raw)
+		}
+	}
+}
+
+// TestSerializeRoundTrip checks that values deserialized from fixtures
+// serialize back to their LocalValue form.
+func TestSerializeRoundTrip(t *testing.T) {
+	fixtures := loadRemoteValues(t)
+
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"string", `{"type":"string","value":"héllo"}`},
+		{"negative zero", `{"type":"number","value":"-0"}`},
+		{"bigint", `{"type":"bigint","value":"9007199254740993"}`},
+		{"date", `{"type":"date","value":"2024-01-02T03:04:05.678Z"}`},
+		{"regexp", `{"type":"regexp","value":{"flags":"gi","pattern":"a+b"}}`},
+		{"set", `{"type":"set","value":[{"type":"string","value":"a"}]}`},
+		{"map", `{"type":"map","value":[[{"type":"number","value":1},{"type":"string","value":"one"}],["two",{"type":"number","value":2}]]}`},
+		{"object", `{"type":"object","value":[["a",{"type":"number","value":1}],["nested",{"type":"object","value":[["b",{"type":"boolean","value":false}]]}]]}`},
+		{"node", `{"sharedId":"f.6A35E1.d.0F1B4E.e.7"}`},
+		{"promise with handle", `{"handle":"6.123.4"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			v, err := DeserializeRemoteValue(fixtures[tt.fixture])
+			if err != nil {
+				t.Fatalf("DeserializeRemoteValue() = %v", err)
+			}
+			local, err := serializeValue(v)
+			if err != nil {
+				t.Fatalf("serializeValue() = %v", err)
+			}
+			got, _ := json.Marshal(local)
+			if string(got) != tt.want {
+				t.Errorf("serializeValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSerializeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr string
+	}{
+		{"nil", nil, `{"type":"undefined"}`, ""},
+		{"int", 3, `{"type":"number","value":3}`, ""},
+		{"nil node", (*NodeRef)(nil), `{"type":"null"}`, ""},
+		{"nil regexp", (*RegExp)(nil), `{"type":"null"}`, ""},
+		{"nil reference", (*RemoteReference)(nil), `{"type":"null"}`, ""},
+		{"nil big int", (*big.Int)(nil), `{"type":"null"}`, ""},
+		{"nil slice", []string(nil), `{"type":"null"}`, ""},
+		{"string slice", []string{"a"}, `{"type":"array","value":[{"type":"string","value":"a"}]}`, ""},
+		{"node with handle", &NodeRef{Handle: "h-1"}, `{"handle":"h-1"}`, ""},
+		{"reference without handle", &RemoteReference{Type: "function"}, "", "cannot pass function"},
+		{"node without handle", &NodeRef{NodeType: 1}, "", "cannot pass node"},
+		{"nested reference without handle", []interface{}{&RemoteReference{Type: "object", InternalID: "1"}}, "", "cannot pass object"},
+		{"map key without handle", Map{{Key: &RemoteReference{Type: "function"}, Value: 1}}, "", "cannot pass function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, err := serializeValue(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("serializeValue() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("serializeValue() = %v", err)
+			}
+			got, _ := json.Marshal(local)
+			if string(got) != tt.want {
+				t.Errorf("serializeValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	fixtures := loadRemoteValues(t)
+
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{"infinity", `"Infinity"`},
+		{"bigint", `"9007199254740993n"`},
+		{"regexp", `"/a+b/gi"`},
+		{"map", `[[1,"one"],["two",2]]`},
+		{"object", `{"a":1,"nested":{"b":false}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			v, err := DeserializeRemoteValue(fixtures[tt.fixture])
+			if err != nil {
+				t.Fatalf("DeserializeRemoteValue() = %v", err)
+			}
+			got, err := json.Marshal(JSONValue(v))
+			if err != nil {
+				t.Fatalf("json.Marshal() = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSONValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallFunctionRejectsUnreferencedArguments(t *testing.T) {
+	client, browser := newFakeBrowser(t, func(cmd fakeCommand) fakeReply {
+		return fakeReply{}
+	})
+
+	_, err := client.CallFunction("ctx-1", "(x) => x", []interface{}{&RemoteReference{Type: "function"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid argument 0") {
+		t.Fatalf("CallFunction() = %v, want invalid argument", err)
+	}
+	if len(browser.received()) != 0 {
+		t.Errorf("sent %d commands, want none", len(browser.received()))
+	}
+}
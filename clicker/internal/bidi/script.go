@@ -3,6 +3,9 @@ package bidi
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
 // RealmInfo represents information about a JavaScript realm.
@@ -50,9 +53,22 @@ type RemoteValue struct {
 	Value interface{} `json:"value,omitempty"`
 }
 
-// Evaluate evaluates a JavaScript expression and returns the result.
+// ScriptOptions configures script evaluation.
+type ScriptOptions struct {
+	AwaitPromise bool // Wait for a returned promise to settle and use its value
+}
+
+// Evaluate evaluates a JavaScript expression and returns the result
+// deserialized into a Go value (see DeserializeRemoteValue).
 // If context is empty, it uses the first available context.
 func (c *Client) Evaluate(context, expression string) (interface{}, error) {
+	return c.EvaluateWithOptions(context, expression, ScriptOptions{AwaitPromise: true})
+}
+
+// EvaluateWithOptions evaluates a JavaScript expression with the given options.
+// A thrown exception is returned as an *errors.ScriptExceptionError.
+// If context is empty, it uses the first available context.
+func (c *Client) EvaluateWithOptions(context, expression string, opts ScriptOptions) (interface{}, error) {
 	// If no context provided, get the first one from the tree
 	if context == "" {
 		tree, err := c.GetTree()
@@ -66,9 +82,9 @@ func (c *Client) Evaluate(context, expression string) (interface{}, error) {
 	}
 
 	params := map[string]interface{}{
-		"expression":    expression,
-		"target":        map[string]interface{}{"context": context},
-		"awaitPromise":  true,
+		"expression":      expression,
+		"target":          map[string]interface{}{"context": context},
+		"awaitPromise":    opts.AwaitPromise,
 		"resultOwnership": "none",
 	}
 
@@ -77,31 +93,41 @@ func (c *Client) Evaluate(context, expression string) (interface{}, error) {
 		return nil, err
 	}
 
-	// Parse the result
-	var evalResult struct {
-		Type   string          `json:"type"`
-		Result json.RawMessage `json:"result"`
-	}
-	if err := json.Unmarshal(msg.Result, &evalResult); err != nil {
-		return nil, fmt.Errorf("failed to parse script.evaluate result: %w", err)
-	}
+	return parseScriptResult("script.evaluate", msg.Result)
+}
 
-	if evalResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(evalResult.Result))
-	}
+// CallFunction calls a JavaScript function with arguments and returns the
+// result deserialized into a Go value (see DeserializeRemoteValue).
+// If context is empty, it uses the first available context.
+func (c *Client) CallFunction(context, functionDeclaration string, args []interface{}) (interface{}, error) {
+	return c.CallFunctionWithOptions(context, functionDeclaration, args, ScriptOptions{AwaitPromise: true})
+}
 
-	// Parse the remote value
-	var remoteValue RemoteValue
-	if err := json.Unmarshal(evalResult.Result, &remoteValue); err != nil {
-		return nil, fmt.Errorf("failed to parse remote value: %w", err)
+// CallFunctionWithOptions calls a JavaScript function with the given options.
+// A thrown exception is returned as an *errors.ScriptExceptionError.
+// If context is empty, it uses the first available context.
+func (c *Client) CallFunctionWithOptions(context, functionDeclaration string, args []interface{}, opts ScriptOptions) (interface{}, error) {
+	raw, err := c.callFunction(context, functionDeclaration, args, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	return remoteValue.Value, nil
+	return DeserializeRemoteValue(raw)
 }
 
-// CallFunction calls a JavaScript function with arguments.
+// CallFunctionInto calls a JavaScript function and decodes the returned
+// value into out (see UnmarshalRemoteValue), e.g. to fill a struct from a
+// returned object. A null or undefined result leaves out unchanged.
 // If context is empty, it uses the first available context.
-func (c *Client) CallFunction(context, functionDeclaration string, args []interface{}) (interface{}, error) {
+func (c *Client) CallFunctionInto(context, functionDeclaration string, args []interface{}, out interface{}) error {
+	raw, err := c.callFunction(context, functionDeclaration, args, ScriptOptions{AwaitPromise: true})
+	if err != nil {
+		return err
+	}
+	return UnmarshalRemoteValue(raw, out)
+}
+
+// callFunction sends script.callFunction and returns the result's RemoteValue.
+func (c *Client) callFunction(context, functionDeclaration string, args []interface{}, opts ScriptOptions) (json.RawMessage, error) {
 	// If no context provided, get the first one from the tree
 	if context == "" {
 		tree, err := c.GetTree()
@@ -117,14 +143,18 @@ func (c *Client) CallFunction(context, functionDeclaration string, args []interf
 	// Convert args to serialized values
 	serializedArgs := make([]map[string]interface{}, len(args))
 	for i, arg := range args {
-		serializedArgs[i] = serializeValue(arg)
+		value, err := serializeValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d: %w", i, err)
+		}
+		serializedArgs[i] = value
 	}
 
 	params := map[string]interface{}{
 		"functionDeclaration": functionDeclaration,
 		"target":              map[string]interface{}{"context": context},
 		"arguments":           serializedArgs,
-		"awaitPromise":        true,
+		"awaitPromise":        opts.AwaitPromise,
 		"resultOwnership":     "none",
 	}
 
@@ -133,41 +163,108 @@ func (c *Client) CallFunction(context, functionDeclaration string, args []interf
 		return nil, err
 	}
 
-	// Parse the result
-	var callResult struct {
-		Type   string          `json:"type"`
-		Result json.RawMessage `json:"result"`
-	}
-	if err := json.Unmarshal(msg.Result, &callResult); err != nil {
-		return nil, fmt.Errorf("failed to parse script.callFunction result: %w", err)
+	return scriptResultValue("script.callFunction", msg.Result)
+}
+
+// parseScriptResult parses a script.EvaluateResult into a Go value or a
+// script exception error.
+func parseScriptResult(method string, raw json.RawMessage) (interface{}, error) {
+	value, err := scriptResultValue(method, raw)
+	if err != nil {
+		return nil, err
 	}
+	return DeserializeRemoteValue(value)
+}
 
-	if callResult.Type == "exception" {
-		return nil, fmt.Errorf("script exception: %s", string(callResult.Result))
+// scriptResultValue returns the RemoteValue of a script.EvaluateResult, or
+// a script exception error.
+func scriptResultValue(method string, raw json.RawMessage) (json.RawMessage, error) {
+	var result struct {
+		Type             string          `json:"type"`
+		Result           json.RawMessage `json:"result"`
+		ExceptionDetails struct {
+			Text         string `json:"text"`
+			LineNumber   int    `json:"lineNumber"`
+			ColumnNumber int    `json:"columnNumber"`
+			StackTrace   struct {
+				CallFrames []struct {
+					FunctionName string `json:"functionName"`
+					URL          string `json:"url"`
+					LineNumber   int    `json:"lineNumber"`
+					ColumnNumber int    `json:"columnNumber"`
+				} `json:"callFrames"`
+			} `json:"stackTrace"`
+		} `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s result: %w", method, err)
 	}
 
-	// Parse the remote value
-	var remoteValue RemoteValue
-	if err := json.Unmarshal(callResult.Result, &remoteValue); err != nil {
-		return nil, fmt.Errorf("failed to parse remote value: %w", err)
+	if result.Type == "exception" {
+		details := result.ExceptionDetails
+		var stack strings.Builder
+		for _, frame := range details.StackTrace.CallFrames {
+			name := frame.FunctionName
+			if name == "" {
+				name = "<anonymous>"
+			}
+			// BiDi line and column numbers are zero-based
+			fmt.Fprintf(&stack, "    at %s (%s:%d:%d)\n", name, frame.URL, frame.LineNumber+1, frame.ColumnNumber+1)
+		}
+		return nil, &errs.ScriptExceptionError{
+			Message: details.Text,
+			Line:    details.LineNumber + 1,
+			Column:  details.ColumnNumber + 1,
+			Stack:   strings.TrimRight(stack.String(), "\n"),
+		}
 	}
 
-	return remoteValue.Value, nil
+	return result.Result, nil
+}
+
+// AddPreloadScriptResult represents the result of script.addPreloadScript.
+type AddPreloadScriptResult struct {
+	Script string `json:"script"`
 }
 
-// serializeValue converts a Go value to a BiDi serialized value.
-func serializeValue(v interface{}) map[string]interface{} {
-	switch val := v.(type) {
-	case nil:
-		return map[string]interface{}{"type": "undefined"}
-	case bool:
-		return map[string]interface{}{"type": "boolean", "value": val}
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-		return map[string]interface{}{"type": "number", "value": val}
-	case string:
-		return map[string]interface{}{"type": "string", "value": val}
-	default:
-		// For complex types, try to serialize as string
-		return map[string]interface{}{"type": "string", "value": fmt.Sprintf("%v", val)}
+// AddPreloadScript registers a function that runs in every new document
+// before any page script. Returns the preload script ID.
+func (c *Client) AddPreloadScript(functionDeclaration string) (string, error) {
+	params := map[string]interface{}{
+		"functionDeclaration": functionDeclaration,
 	}
+
+	msg, err := c.SendCommand("script.addPreloadScript", params)
+	if err != nil {
+		return "", err
+	}
+
+	var result AddPreloadScriptResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to parse script.addPreloadScript result: %w", err)
+	}
+
+	return result.Script, nil
+}
+
+// RemovePreloadScript removes a preload script by ID.
+// Documents that already ran the script are not affected.
+func (c *Client) RemovePreloadScript(script string) error {
+	_, err := c.SendCommand("script.removePreloadScript", map[string]interface{}{
+		"script": script,
+	})
+	return err
+}
+
+// InitScriptDeclaration wraps plain JavaScript source (e.g. the contents of
+// an init script file) as a function declaration for script.addPreloadScript.
+func InitScriptDeclaration(source string) string {
+	return "() => {\n" + source + "\n}"
+}
+
+// AddInitScript registers JavaScript source that runs in every new document
+// before any page script, e.g. to mock Date.now or set feature flags.
+// Returns the preload script ID.
+func (c *Client) AddInitScript(source string) (string, error) {
+	return c.AddPreloadScript(InitScriptDeclaration(source))
 }
@@ -3,17 +3,44 @@ package bidi
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
+
+	errs "github.com/vibium/clicker/internal/errors"
 )
 
+// EventHandler handles a BiDi event. Handlers run on the goroutine reading
+// from the connection, so they must not block or send commands directly.
+type EventHandler func(event *Event)
+
 // Client is a BiDi client that wraps a WebSocket connection.
 type Client struct {
 	conn    *Connection
 	verbose bool
+
+	// Event handlers by method
+	handlersMu    sync.Mutex
+	handlers      map[string]map[int]EventHandler
+	nextHandlerID int
+	subscribed    map[string]bool // Events subscribed via ensureSubscribed
+
+	// Background read loop state (see StartEventLoop)
+	loopMu   sync.Mutex
+	looping  bool
+	pending  map[int64]chan *Message
+	loopDone chan struct{}
+	loopErr  error
+
+	// interrupted is closed by Interrupt to abort commands waiting for a response
+	interrupted chan struct{}
 }
 
 // NewClient creates a new BiDi client from a WebSocket connection.
 func NewClient(conn *Connection) *Client {
-	return &Client{conn: conn}
+	return &Client{
+		conn:       conn,
+		handlers:   make(map[string]map[int]EventHandler),
+		subscribed: make(map[string]bool),
+	}
 }
 
 // SetVerbose enables or disables verbose logging of JSON messages.
@@ -21,6 +48,116 @@ func (c *Client) SetVerbose(verbose bool) {
 	c.verbose = verbose
 }
 
+// OnEvent registers a handler for events with the given method
+// (e.g. "script.message", see ExposeBinding). Returns a function that removes the handler.
+// Events are only delivered while the client reads from the connection:
+// during SendCommand, or continuously after StartEventLoop.
+func (c *Client) OnEvent(method string, handler EventHandler) func() {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+
+	id := c.nextHandlerID
+	c.nextHandlerID++
+	if c.handlers[method] == nil {
+		c.handlers[method] = make(map[int]EventHandler)
+	}
+	c.handlers[method][id] = handler
+
+	return func() {
+		c.handlersMu.Lock()
+		defer c.handlersMu.Unlock()
+		delete(c.handlers[method], id)
+	}
+}
+
+// dispatchEvent delivers an event message to the registered handlers.
+func (c *Client) dispatchEvent(msg *Message) {
+	c.handlersMu.Lock()
+	handlers := make([]EventHandler, 0, len(c.handlers[msg.Method]))
+	for _, h := range c.handlers[msg.Method] {
+		handlers = append(handlers, h)
+	}
+	c.handlersMu.Unlock()
+
+	event := &Event{Method: msg.Method, Params: msg.Params}
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// StartEventLoop starts reading from the connection in the background so
+// events are delivered as they arrive, not only while a command is pending.
+// Once started, SendCommand may be called from multiple goroutines.
+// The connection must not be read by anything else afterwards.
+func (c *Client) StartEventLoop() {
+	c.loopMu.Lock()
+	defer c.loopMu.Unlock()
+
+	if c.looping {
+		return
+	}
+	c.looping = true
+	c.pending = make(map[int64]chan *Message)
+	c.loopDone = make(chan struct{})
+	c.interrupted = make(chan struct{})
+
+	go c.readLoop()
+}
+
+// Interrupt makes commands currently waiting for a response return
+// errors.ErrCancelled. The browser may still complete them. Commands sent
+// afterwards are not affected. Interrupt requires StartEventLoop.
+func (c *Client) Interrupt() {
+	c.loopMu.Lock()
+	defer c.loopMu.Unlock()
+
+	if !c.looping {
+		return
+	}
+	close(c.interrupted)
+	c.interrupted = make(chan struct{})
+}
+
+// readLoop routes responses to pending commands and dispatches events.
+func (c *Client) readLoop() {
+	defer close(c.loopDone)
+
+	for {
+		resp, err := c.conn.Receive()
+		if err != nil {
+			c.loopMu.Lock()
+			c.loopErr = err
+			c.loopMu.Unlock()
+			return
+		}
+
+		if c.verbose {
+			fmt.Printf("       <-- %s\n", resp)
+		}
+
+		msg, err := UnmarshalMessage([]byte(resp))
+		if err != nil {
+			continue
+		}
+
+		if msg.IsResponse() {
+			c.loopMu.Lock()
+			ch := c.pending[*msg.ID]
+			delete(c.pending, *msg.ID)
+			c.loopMu.Unlock()
+
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+
+		if msg.IsEvent() {
+			c.dispatchEvent(msg)
+		}
+	}
+}
+
 // SendCommand sends a BiDi command and waits for the response.
 func (c *Client) SendCommand(method string, params interface{}) (*Message, error) {
 	cmd := NewCommand(method, params)
@@ -34,10 +171,44 @@ func (c *Client) SendCommand(method string, params interface{}) (*Message, error
 		fmt.Printf("       --> %s\n", string(data))
 	}
 
+	// With the event loop running, wait for the loop to route the response
+	c.loopMu.Lock()
+	looping := c.looping
+	var respCh chan *Message
+	var interrupted chan struct{}
+	if looping {
+		respCh = make(chan *Message, 1)
+		c.pending[cmd.ID] = respCh
+		interrupted = c.interrupted
+	}
+	c.loopMu.Unlock()
+
 	if err := c.conn.Send(string(data)); err != nil {
+		if looping {
+			c.loopMu.Lock()
+			delete(c.pending, cmd.ID)
+			c.loopMu.Unlock()
+		}
 		return nil, fmt.Errorf("failed to send command: %w", err)
 	}
 
+	if looping {
+		select {
+		case msg := <-respCh:
+			return responseResult(msg)
+		case <-c.loopDone:
+			c.loopMu.Lock()
+			loopErr := c.loopErr
+			c.loopMu.Unlock()
+			return nil, fmt.Errorf("failed to receive response: %w", loopErr)
+		case <-interrupted:
+			c.loopMu.Lock()
+			delete(c.pending, cmd.ID)
+			c.loopMu.Unlock()
+			return nil, fmt.Errorf("%s: %w", method, errs.ErrCancelled)
+		}
+	}
+
 	// Wait for response with matching ID
 	for {
 		resp, err := c.conn.Receive()
@@ -56,26 +227,62 @@ func (c *Client) SendCommand(method string, params interface{}) (*Message, error
 
 		// Check if this is the response we're waiting for
 		if msg.ID != nil && *msg.ID == cmd.ID {
-			if msg.IsError() {
-				errData, _ := msg.GetError()
-				if errData != nil {
-					return nil, fmt.Errorf("BiDi error: %s - %s", errData.Error, errData.Message)
-				}
-				return nil, fmt.Errorf("BiDi error: %s", string(msg.Error))
-			}
-			return msg, nil
+			return responseResult(msg)
 		}
 
-		// If it's an event, skip it for now (could be handled by event listener)
+		// Deliver events that arrive while waiting
 		if msg.IsEvent() {
-			if c.verbose {
-				fmt.Printf("       (event, skipping)\n")
-			}
+			c.dispatchEvent(msg)
 			continue
 		}
 	}
 }
 
+// responseResult returns the response message, or an error if it is an error response.
+func responseResult(msg *Message) (*Message, error) {
+	if msg.IsError() {
+		errData, _ := msg.GetError()
+		if errData != nil {
+			return nil, fmt.Errorf("BiDi error: %w", &errs.ProtocolError{Code: errData.Error, Message: errData.Message})
+		}
+		return nil, fmt.Errorf("BiDi error: %s", string(msg.Error))
+	}
+	return msg, nil
+}
+
+// Subscribe subscribes to BiDi events (e.g. "browsingContext.load").
+// If contexts is empty, the subscription applies to all browsing contexts.
+func (c *Client) Subscribe(events []string, contexts []string) error {
+	params := map[string]interface{}{
+		"events": events,
+	}
+	if len(contexts) > 0 {
+		params["contexts"] = contexts
+	}
+
+	_, err := c.SendCommand("session.subscribe", params)
+	return err
+}
+
+// ensureSubscribed subscribes to an event for all contexts unless already subscribed.
+func (c *Client) ensureSubscribed(event string) error {
+	c.handlersMu.Lock()
+	done := c.subscribed[event]
+	c.handlersMu.Unlock()
+	if done {
+		return nil
+	}
+
+	if err := c.Subscribe([]string{event}, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", event, err)
+	}
+
+	c.handlersMu.Lock()
+	c.subscribed[event] = true
+	c.handlersMu.Unlock()
+	return nil
+}
+
 // SessionStatusResult represents the result of session.status command.
 type SessionStatusResult struct {
 	Ready   bool   `json:"ready"`
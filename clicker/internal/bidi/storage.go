@@ -0,0 +1,233 @@
+package bidi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Cookie represents a browser cookie.
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	SameSite string `json:"sameSite,omitempty"` // "strict", "lax" or "none"
+	Expiry   int64  `json:"expiry,omitempty"`   // Unix seconds, 0 = session cookie
+}
+
+// CookieFilter selects cookies by attribute. Empty fields match any value.
+type CookieFilter struct {
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// bytesValue is a network.BytesValue.
+type bytesValue struct {
+	Type  string `json:"type"` // "string" or "base64"
+	Value string `json:"value"`
+}
+
+// networkCookie is a network.Cookie as sent over the wire.
+type networkCookie struct {
+	Name     string     `json:"name"`
+	Value    bytesValue `json:"value"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	HTTPOnly bool       `json:"httpOnly"`
+	Secure   bool       `json:"secure"`
+	SameSite string     `json:"sameSite"`
+	Expiry   *int64     `json:"expiry,omitempty"`
+}
+
+// Params returns the filter as storage.CookieFilter params.
+func (f CookieFilter) Params() map[string]interface{} {
+	filter := map[string]interface{}{}
+	if f.Name != "" {
+		filter["name"] = f.Name
+	}
+	if f.Domain != "" {
+		filter["domain"] = f.Domain
+	}
+	if f.Path != "" {
+		filter["path"] = f.Path
+	}
+	return filter
+}
+
+// GetCookiesParams returns the params for storage.getCookies.
+func GetCookiesParams(filter CookieFilter) map[string]interface{} {
+	return map[string]interface{}{"filter": filter.Params()}
+}
+
+// SetCookieParams returns the params for storage.setCookie.
+func SetCookieParams(cookie Cookie) map[string]interface{} {
+	partial := map[string]interface{}{
+		"name":   cookie.Name,
+		"value":  map[string]interface{}{"type": "string", "value": cookie.Value},
+		"domain": cookie.Domain,
+	}
+	if cookie.Path != "" {
+		partial["path"] = cookie.Path
+	}
+	if cookie.HTTPOnly {
+		partial["httpOnly"] = true
+	}
+	if cookie.Secure {
+		partial["secure"] = true
+	}
+	if cookie.SameSite != "" {
+		partial["sameSite"] = cookie.SameSite
+	}
+	if cookie.Expiry > 0 {
+		partial["expiry"] = cookie.Expiry
+	}
+	return map[string]interface{}{"cookie": partial}
+}
+
+// DeleteCookiesParams returns the params for storage.deleteCookies.
+func DeleteCookiesParams(filter CookieFilter) map[string]interface{} {
+	return map[string]interface{}{"filter": filter.Params()}
+}
+
+// ParseCookies parses the result of storage.getCookies.
+func ParseCookies(result json.RawMessage) ([]Cookie, error) {
+	var parsed struct {
+		Cookies []networkCookie `json:"cookies"`
+	}
+	if err := json.Unmarshal(result, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse storage.getCookies result: %w", err)
+	}
+
+	cookies := make([]Cookie, 0, len(parsed.Cookies))
+	for _, nc := range parsed.Cookies {
+		value := nc.Value.Value
+		if nc.Value.Type == "base64" {
+			if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+				value = string(decoded)
+			}
+		}
+		cookie := Cookie{
+			Name:     nc.Name,
+			Value:    value,
+			Domain:   nc.Domain,
+			Path:     nc.Path,
+			HTTPOnly: nc.HTTPOnly,
+			Secure:   nc.Secure,
+			SameSite: nc.SameSite,
+		}
+		if nc.Expiry != nil {
+			cookie.Expiry = *nc.Expiry
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, nil
+}
+
+// GetCookies returns the cookies matching the filter.
+func (c *Client) GetCookies(filter CookieFilter) ([]Cookie, error) {
+	msg, err := c.SendCommand("storage.getCookies", GetCookiesParams(filter))
+	if err != nil {
+		return nil, err
+	}
+	return ParseCookies(msg.Result)
+}
+
+// SetCookie sets a cookie. The cookie's Domain is required.
+func (c *Client) SetCookie(cookie Cookie) error {
+	if cookie.Name == "" || cookie.Domain == "" {
+		return fmt.Errorf("cookie name and domain are required")
+	}
+	_, err := c.SendCommand("storage.setCookie", SetCookieParams(cookie))
+	return err
+}
+
+// DeleteCookies deletes the cookies matching the filter.
+// An empty filter deletes all cookies.
+func (c *Client) DeleteCookies(filter CookieFilter) error {
+	_, err := c.SendCommand("storage.deleteCookies", DeleteCookiesParams(filter))
+	return err
+}
+
+// WebStorage identifies a Web Storage area of a document.
+type WebStorage string
+
+const (
+	LocalStorage   WebStorage = "localStorage"
+	SessionStorage WebStorage = "sessionStorage"
+)
+
+// validate checks that the storage area is supported.
+func (s WebStorage) validate() error {
+	switch s {
+	case LocalStorage, SessionStorage:
+		return nil
+	default:
+		return fmt.Errorf("invalid storage %q (expected %q or %q)", string(s), LocalStorage, SessionStorage)
+	}
+}
+
+// GetWebStorage returns all items in the localStorage or sessionStorage
+// of the document in the given context.
+// If context is empty, it uses the first available context.
+func (c *Client) GetWebStorage(context string, storage WebStorage) (map[string]string, error) {
+	if err := storage.validate(); err != nil {
+		return nil, err
+	}
+
+	script := `
+		(storage) => {
+			const area = window[storage];
+			const items = {};
+			for (let i = 0; i < area.length; i++) {
+				const key = area.key(i);
+				items[key] = area.getItem(key);
+			}
+			return items;
+		}
+	`
+
+	items := map[string]string{}
+	if err := c.CallFunctionInto(context, script, []interface{}{string(storage)}, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// SetWebStorageItems stores items in the localStorage or sessionStorage
+// of the document in the given context.
+// If context is empty, it uses the first available context.
+func (c *Client) SetWebStorageItems(context string, storage WebStorage, items map[string]string) error {
+	if err := storage.validate(); err != nil {
+		return err
+	}
+
+	script := `
+		(storage, items) => {
+			const area = window[storage];
+			for (const [key, value] of Object.entries(items)) {
+				area.setItem(key, value);
+			}
+		}
+	`
+
+	_, err := c.CallFunction(context, script, []interface{}{string(storage), items})
+	return err
+}
+
+// ClearWebStorage removes all items from the localStorage or sessionStorage
+// of the document in the given context.
+// If context is empty, it uses the first available context.
+func (c *Client) ClearWebStorage(context string, storage WebStorage) error {
+	if err := storage.validate(); err != nil {
+		return err
+	}
+
+	_, err := c.CallFunction(context, `(storage) => window[storage].clear()`, []interface{}{string(storage)})
+	return err
+}
@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/features"
 	"github.com/vibium/clicker/internal/log"
 	"github.com/vibium/clicker/internal/paths"
 	"github.com/vibium/clicker/internal/process"
@@ -51,14 +54,33 @@ type LaunchOptions struct {
 	Headless bool
 	Port     int  // Chromedriver port, 0 = auto-select
 	Verbose  bool // Show chromedriver output
+
+	// Emulation overrides. Chrome args and environment apply what they can
+	// at launch; call bidi.Client.ApplyEmulation with LaunchResult.Emulation
+	// once connected for the rest, e.g. geolocation.
+	Emulation *bidi.EmulationOptions
+
+	// StorageState is the path of a saved storage state file (see
+	// features.SaveStorageState). It is read at launch and must be restored
+	// with features.ApplyStorageState using LaunchResult.StorageState
+	// once connected, before the first navigation.
+	StorageState string
+
+	// UserDataDir is the Chrome profile directory. Empty uses a temporary
+	// profile created by chromedriver.
+	UserDataDir string
 }
 
 // LaunchResult contains the result of launching the browser via chromedriver.
 type LaunchResult struct {
 	WebSocketURL   string
 	SessionID      string
+	Capabilities   map[string]interface{} // Capabilities of the WebDriver session
 	ChromedriverCmd *exec.Cmd
+	ChromePath     string // Browser executable started by chromedriver
 	Port           int
+	Emulation      *bidi.EmulationOptions // Emulation requested at launch
+	StorageState   *features.StorageState // Storage state to restore, if any
 }
 
 // sessionRequest is the payload for creating a new session.
@@ -95,6 +117,19 @@ type sessionValue struct {
 func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	log.Debug("launching browser", "headless", opts.Headless)
 
+	if err := opts.Emulation.Validate(); err != nil {
+		return nil, err
+	}
+
+	var storageState *features.StorageState
+	if opts.StorageState != "" {
+		state, err := features.ReadStorageState(opts.StorageState)
+		if err != nil {
+			return nil, err
+		}
+		storageState = state
+	}
+
 	chromedriverPath, err := paths.GetChromedriverPath()
 	if err != nil {
 		return nil, fmt.Errorf("chromedriver not found: %w (run 'clicker install' first)", err)
@@ -120,6 +155,10 @@ func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	// Start chromedriver as a process group leader so we can kill all children
 	cmd := exec.Command(chromedriverPath, fmt.Sprintf("--port=%d", port))
 	setProcGroup(cmd)
+	if opts.Emulation != nil && opts.Emulation.Timezone != "" {
+		// Chrome inherits the timezone from chromedriver's environment
+		cmd.Env = append(os.Environ(), "TZ="+opts.Emulation.Timezone)
+	}
 	if opts.Verbose {
 		fmt.Println("       ------- chromedriver -------")
 		pw := newPrefixWriter(os.Stdout, "       ")
@@ -145,7 +184,7 @@ func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	}
 
 	// Create session with BiDi enabled
-	sessionID, wsURL, err := createSession(baseURL, chromePath, opts.Headless, opts.Verbose)
+	sessionID, capabilities, wsURL, err := createSession(baseURL, chromePath, opts)
 	if err != nil {
 		cmd.Process.Kill()
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -155,8 +194,12 @@ func Launch(opts LaunchOptions) (*LaunchResult, error) {
 	return &LaunchResult{
 		WebSocketURL:    wsURL,
 		SessionID:       sessionID,
+		Capabilities:    capabilities,
 		ChromedriverCmd: cmd,
+		ChromePath:      chromePath,
 		Port:            port,
+		Emulation:       opts.Emulation,
+		StorageState:    storageState,
 	}, nil
 }
 
@@ -186,8 +229,9 @@ func waitForChromedriver(baseURL string, timeout time.Duration) error {
 	return fmt.Errorf("timeout waiting for chromedriver")
 }
 
-// createSession creates a new WebDriver session with BiDi enabled.
-func createSession(baseURL, chromePath string, headless, verbose bool) (string, string, error) {
+// createSession creates a new WebDriver session with BiDi enabled. Returns
+// the session ID, its capabilities and the BiDi WebSocket URL.
+func createSession(baseURL, chromePath string, opts LaunchOptions) (string, map[string]interface{}, string, error) {
 	args := []string{
 		"--no-first-run",
 		"--no-default-browser-check",
@@ -218,10 +262,23 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 		"--use-mock-keychain",
 	}
 
-	if headless {
+	if opts.Headless {
 		args = append(args, "--headless=new")
 	}
 
+	if opts.UserDataDir != "" {
+		args = append(args, "--user-data-dir="+opts.UserDataDir)
+	}
+
+	prefs := map[string]interface{}{
+		"credentials_enable_service":                          false,
+		"profile.password_manager_enabled":                    false,
+		"profile.password_manager_leak_detection":             false,
+		"profile.default_content_setting_values.notifications": 2,
+	}
+
+	args, prefs = applyEmulationArgs(args, prefs, opts.Emulation)
+
 	reqBody := map[string]interface{}{
 		"capabilities": map[string]interface{}{
 			"alwaysMatch": map[string]interface{}{
@@ -231,12 +288,7 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 					"binary":          chromePath,
 					"args":            args,
 					"excludeSwitches": []string{"enable-automation"},
-					"prefs": map[string]interface{}{
-						"credentials_enable_service":                          false,
-						"profile.password_manager_enabled":                    false,
-						"profile.password_manager_leak_detection":             false,
-						"profile.default_content_setting_values.notifications": 2,
-					},
+					"prefs":           prefs,
 				},
 			},
 		},
@@ -244,46 +296,77 @@ func createSession(baseURL, chromePath string, headless, verbose bool) (string,
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", "", err
+		return "", nil, "", err
 	}
 
-	if verbose {
+	if opts.Verbose {
 		fmt.Println("       ------- POST /session -------")
 		fmt.Printf("       --> %s\n", string(jsonBody))
 	}
 
 	resp, err := http.Post(baseURL+"/session", "application/json", bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", "", err
+		return "", nil, "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", "", fmt.Errorf("failed to create session: HTTP %d", resp.StatusCode)
+		return "", nil, "", fmt.Errorf("failed to create session: HTTP %d", resp.StatusCode)
 	}
 
 	// Read response body for logging and parsing
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read session response: %w", err)
+		return "", nil, "", fmt.Errorf("failed to read session response: %w", err)
 	}
 
-	if verbose {
+	if opts.Verbose {
 		fmt.Printf("       <-- %s\n", string(respBody))
 		fmt.Println("       ------------------------------")
 	}
 
 	var sessResp sessionResponse
 	if err := json.Unmarshal(respBody, &sessResp); err != nil {
-		return "", "", fmt.Errorf("failed to decode session response: %w", err)
+		return "", nil, "", fmt.Errorf("failed to decode session response: %w", err)
 	}
 
 	wsURL, ok := sessResp.Value.Capabilities["webSocketUrl"].(string)
 	if !ok || wsURL == "" {
-		return "", "", fmt.Errorf("webSocketUrl not found in session capabilities")
+		return "", nil, "", fmt.Errorf("webSocketUrl not found in session capabilities")
 	}
 
-	return sessResp.Value.SessionID, wsURL, nil
+	return sessResp.Value.SessionID, sessResp.Value.Capabilities, wsURL, nil
+}
+
+// applyEmulationArgs adds the Chrome args and profile prefs for launch-time emulation.
+func applyEmulationArgs(args []string, prefs map[string]interface{}, emu *bidi.EmulationOptions) ([]string, map[string]interface{}) {
+	if emu == nil {
+		return args, prefs
+	}
+
+	if emu.Locale != "" {
+		// --lang sets navigator.language, intl.accept_languages sets the Accept-Language header
+		args = append(args, "--lang="+emu.Locale)
+		prefs["intl.accept_languages"] = emu.Locale
+	}
+
+	switch emu.ColorScheme {
+	case "dark":
+		args = append(args, "--blink-settings=preferredColorScheme=0")
+	case "light":
+		args = append(args, "--blink-settings=preferredColorScheme=1")
+	}
+
+	if emu.ReducedMotion == "reduce" {
+		args = append(args, "--force-prefers-reduced-motion")
+	}
+
+	if emu.Geolocation != nil {
+		// Allow geolocation without a permission prompt
+		prefs["profile.default_content_setting_values.geolocation"] = 1
+	}
+
+	return args, prefs
 }
 
 // Close terminates a chromedriver session and process.
@@ -317,6 +400,36 @@ func (r *LaunchResult) Close() error {
 	return nil
 }
 
+// BrowserPID returns the PID of the browser process started by chromedriver,
+// or 0 if it isn't running or can't be found.
+func (r *LaunchResult) BrowserPID() int {
+	if r.ChromedriverCmd == nil || r.ChromedriverCmd.Process == nil {
+		return 0
+	}
+
+	// The browser runs the Chrome executable without --type=, which its
+	// helpers (renderer, GPU, utility) all have
+	for _, pid := range getDescendants(r.ChromedriverCmd.Process.Pid) {
+		args, err := processArgs(pid)
+		if err != nil || !strings.HasPrefix(args, r.ChromePath) {
+			continue
+		}
+		if !strings.Contains(args, " --type=") {
+			return pid
+		}
+	}
+	return 0
+}
+
+// processArgs returns the command line of a process.
+func processArgs(pid int) (string, error) {
+	output, err := exec.Command("ps", "-o", "args=", "-p", fmt.Sprintf("%d", pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(output)), nil
+}
+
 // killProcessTree kills a process and all its descendants.
 func killProcessTree(pid int) {
 	// First, find all descendant PIDs while parent relationships still exist
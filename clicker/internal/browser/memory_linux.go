@@ -0,0 +1,123 @@
+//go:build linux
+
+package browser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MemoryUsage returns the resident memory of chromedriver, the browser and
+// all their helper processes, in bytes. Pages shared between processes are
+// counted once per process, so this overestimates somewhat.
+func (r *LaunchResult) MemoryUsage() (uint64, error) {
+	if r.ChromedriverCmd == nil || r.ChromedriverCmd.Process == nil {
+		return 0, fmt.Errorf("browser is not running")
+	}
+
+	pid := r.ChromedriverCmd.Process.Pid
+	total, err := residentMemory(pid)
+	if err != nil {
+		return 0, err
+	}
+	children, err := childProcesses()
+	if err != nil {
+		return 0, err
+	}
+	for _, child := range descendantsOf(pid, children) {
+		// Helpers may exit between listing and reading
+		if rss, err := residentMemory(child); err == nil {
+			total += rss
+		}
+	}
+	return total, nil
+}
+
+// childProcesses reads the parent of every process from /proc and returns
+// the children of each process.
+func childProcesses() (map[int][]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if ppid, err := parentPID(pid); err == nil {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+	return children, nil
+}
+
+// parentPID reads the parent of a process from /proc/<pid>/stat.
+func parentPID(pid int) (int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// Formatted as "pid (comm) state ppid ...", where comm may contain
+	// spaces and parentheses
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// descendantsOf returns the descendants of a process, given the children
+// of each process.
+func descendantsOf(pid int, children map[int][]int) []int {
+	var descendants []int
+	for _, child := range children[pid] {
+		descendants = append(descendants, child)
+		descendants = append(descendants, descendantsOf(child, children)...)
+	}
+	return descendants
+}
+
+// MemoryUsageSupported reports whether MemoryUsage works on this platform.
+const MemoryUsageSupported = true
+
+// residentMemory reads the VmRSS of a process from /proc, in bytes.
+func residentMemory(pid int) (uint64, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "VmRSS:")
+		if !ok {
+			continue
+		}
+		// Formatted as "  123456 kB"
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			break
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid VmRSS of process %d: %w", pid, err)
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	// Kernel threads and zombies have no VmRSS
+	return 0, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package browser
+
+import "fmt"
+
+// MemoryUsage is only supported on Linux, where it reads /proc.
+func (r *LaunchResult) MemoryUsage() (uint64, error) {
+	return 0, fmt.Errorf("memory usage is only available on Linux")
+}
+
+// MemoryUsageSupported reports whether MemoryUsage works on this platform.
+const MemoryUsageSupported = false
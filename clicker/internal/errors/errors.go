@@ -2,10 +2,14 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"time"
 )
 
+// ErrCancelled is returned when an operation is cancelled before it completes.
+var ErrCancelled = stderrors.New("operation cancelled")
+
 // ConnectionError is returned when a connection to the browser fails.
 type ConnectionError struct {
 	URL   string
@@ -31,6 +35,9 @@ type TimeoutError struct {
 }
 
 func (e *TimeoutError) Error() string {
+	if e.Selector == "" {
+		return fmt.Sprintf("timeout after %s: %s", e.Timeout, e.Reason)
+	}
 	if e.Reason != "" {
 		return fmt.Sprintf("timeout after %s waiting for '%s': %s", e.Timeout, e.Selector, e.Reason)
 	}
@@ -40,10 +47,14 @@ func (e *TimeoutError) Error() string {
 // ElementNotFoundError is returned when a selector matches no elements.
 type ElementNotFoundError struct {
 	Selector string
-	Context  string // browsing context ID
+	Context  string        // browsing context ID
+	Timeout  time.Duration // How long the element was waited for, if at all
 }
 
 func (e *ElementNotFoundError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("element not found after %s: %s", e.Timeout, e.Selector)
+	}
 	if e.Context != "" {
 		return fmt.Sprintf("element not found: %s (context: %s)", e.Selector, e.Context)
 	}
@@ -62,3 +73,86 @@ func (e *BrowserCrashedError) Error() string {
 	}
 	return fmt.Sprintf("browser crashed with exit code %d", e.ExitCode)
 }
+
+// ScriptExceptionError is returned when evaluated JavaScript throws.
+type ScriptExceptionError struct {
+	Message string // e.g. "Error: something broke"
+	Line    int    // 1-based line of the throw site
+	Column  int    // 1-based column of the throw site
+	Stack   string // JavaScript call stack, one "at ..." frame per line
+}
+
+func (e *ScriptExceptionError) Error() string {
+	if e.Stack != "" {
+		return fmt.Sprintf("script exception: %s\n%s", e.Message, e.Stack)
+	}
+	return fmt.Sprintf("script exception: %s", e.Message)
+}
+
+// PolicyViolationError is returned when the URL policy blocks a navigation.
+type PolicyViolationError struct {
+	URL    string
+	Reason string // e.g. "not in the allowed origins (staging.example.com)"
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("policy violation: navigation to %s is blocked: %s", e.URL, e.Reason)
+}
+
+// ElementNotInteractableError is returned when an element exists but keeps
+// failing an actionability check until the timeout.
+type ElementNotInteractableError struct {
+	Selector string
+	Check    string // e.g. "Visible"
+	Reason   string // e.g. "zero size"
+	Timeout  time.Duration
+}
+
+func (e *ElementNotInteractableError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("element not interactable after %s: '%s' failed check '%s': %s", e.Timeout, e.Selector, e.Check, e.Reason)
+	}
+	return fmt.Sprintf("element not interactable after %s: '%s' failed check '%s'", e.Timeout, e.Selector, e.Check)
+}
+
+// InvalidArgumentError is returned when a command has missing or malformed
+// parameters.
+type InvalidArgumentError struct {
+	Message string // e.g. "selector is required"
+}
+
+func (e *InvalidArgumentError) Error() string {
+	return e.Message
+}
+
+// UnknownCommandError is returned for a command method that doesn't exist.
+type UnknownCommandError struct {
+	Method string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command: %s", e.Method)
+}
+
+// UnsupportedOperationError is returned for a command the client isn't
+// allowed to send, e.g. a command that changes the page from an observer.
+type UnsupportedOperationError struct {
+	Method string
+	Reason string // e.g. "observers can only send read-only commands"
+}
+
+func (e *UnsupportedOperationError) Error() string {
+	return fmt.Sprintf("unsupported operation: %s: %s", e.Method, e.Reason)
+}
+
+// ProtocolError is an error response from the browser, with its WebDriver
+// BiDi error code.
+type ProtocolError struct {
+	Code       string // e.g. "no such frame"
+	Message    string
+	Stacktrace string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
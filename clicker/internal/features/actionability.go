@@ -1,9 +1,8 @@
 package features
 
 import (
-	"encoding/json"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/vibium/clicker/internal/bidi"
 )
@@ -23,124 +22,19 @@ type ActionabilityResult struct {
 // - visibility is not "hidden"
 // - display is not "none"
 func CheckVisible(client *bidi.Client, context, selector string) (bool, error) {
-	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return JSON.stringify({ error: 'not found' });
-
-			const rect = el.getBoundingClientRect();
-			if (rect.width === 0 || rect.height === 0) {
-				return JSON.stringify({ visible: false, reason: 'zero size' });
-			}
-
-			const style = window.getComputedStyle(el);
-			if (style.visibility === 'hidden') {
-				return JSON.stringify({ visible: false, reason: 'visibility hidden' });
-			}
-			if (style.display === 'none') {
-				return JSON.stringify({ visible: false, reason: 'display none' });
-			}
-
-			return JSON.stringify({ visible: true });
-		}
-	`
-
-	result, err := callCheckFunction(client, context, selector, script)
-	if err != nil {
-		return false, err
-	}
-
-	var data struct {
-		Visible bool   `json:"visible"`
-		Error   string `json:"error,omitempty"`
-	}
-	if err := json.Unmarshal([]byte(result), &data); err != nil {
-		return false, fmt.Errorf("failed to parse visibility result: %w", err)
-	}
-
-	if data.Error != "" {
-		return false, fmt.Errorf("element %s", data.Error)
-	}
-
-	return data.Visible, nil
+	return runCheckScript(client, context, selector, CheckVisibleType)
 }
 
 // CheckStable verifies the element's bounding box hasn't changed between two checks.
 // Compares position at t and t+50ms - if same, element is stable (not animating).
 func CheckStable(client *bidi.Client, context, selector string) (bool, error) {
-	// Get bounding box at time t
-	box1, err := getBoundingBox(client, context, selector)
-	if err != nil {
-		return false, err
-	}
-
-	// Wait 50ms
-	time.Sleep(50 * time.Millisecond)
-
-	// Get bounding box at time t+50ms
-	box2, err := getBoundingBox(client, context, selector)
-	if err != nil {
-		return false, err
-	}
-
-	// Compare - stable if all values match
-	stable := box1.X == box2.X &&
-		box1.Y == box2.Y &&
-		box1.Width == box2.Width &&
-		box1.Height == box2.Height
-
-	return stable, nil
+	return runCheckScript(client, context, selector, CheckStableType)
 }
 
 // CheckReceivesEvents verifies the element is the hit target at its center point.
 // Uses elementFromPoint() to check if the element (or a descendant) receives pointer events.
 func CheckReceivesEvents(client *bidi.Client, context, selector string) (bool, error) {
-	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return JSON.stringify({ error: 'not found' });
-
-			const rect = el.getBoundingClientRect();
-			const centerX = rect.x + rect.width / 2;
-			const centerY = rect.y + rect.height / 2;
-
-			// Get element at center point
-			const hitTarget = document.elementFromPoint(centerX, centerY);
-			if (!hitTarget) {
-				return JSON.stringify({ receivesEvents: false, reason: 'no element at point' });
-			}
-
-			// Check if hit target is the element or a descendant
-			if (el === hitTarget || el.contains(hitTarget)) {
-				return JSON.stringify({ receivesEvents: true });
-			}
-
-			// Element is obscured by another element
-			return JSON.stringify({
-				receivesEvents: false,
-				reason: 'obscured by ' + hitTarget.tagName.toLowerCase()
-			});
-		}
-	`
-
-	result, err := callCheckFunction(client, context, selector, script)
-	if err != nil {
-		return false, err
-	}
-
-	var data struct {
-		ReceivesEvents bool   `json:"receivesEvents"`
-		Error          string `json:"error,omitempty"`
-	}
-	if err := json.Unmarshal([]byte(result), &data); err != nil {
-		return false, fmt.Errorf("failed to parse receivesEvents result: %w", err)
-	}
-
-	if data.Error != "" {
-		return false, fmt.Errorf("element %s", data.Error)
-	}
-
-	return data.ReceivesEvents, nil
+	return runCheckScript(client, context, selector, CheckReceivesEventsType)
 }
 
 // CheckEnabled verifies the element is not disabled.
@@ -149,53 +43,7 @@ func CheckReceivesEvents(client *bidi.Client, context, selector string) (bool, e
 // - It has aria-disabled="true"
 // - It's inside a disabled <fieldset>
 func CheckEnabled(client *bidi.Client, context, selector string) (bool, error) {
-	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return JSON.stringify({ error: 'not found' });
-
-			// Check disabled attribute
-			if (el.disabled === true) {
-				return JSON.stringify({ enabled: false, reason: 'disabled attribute' });
-			}
-
-			// Check aria-disabled
-			if (el.getAttribute('aria-disabled') === 'true') {
-				return JSON.stringify({ enabled: false, reason: 'aria-disabled' });
-			}
-
-			// Check if inside disabled fieldset
-			const fieldset = el.closest('fieldset[disabled]');
-			if (fieldset) {
-				// Exception: elements in the first legend are not disabled
-				const legend = fieldset.querySelector('legend');
-				if (!legend || !legend.contains(el)) {
-					return JSON.stringify({ enabled: false, reason: 'inside disabled fieldset' });
-				}
-			}
-
-			return JSON.stringify({ enabled: true });
-		}
-	`
-
-	result, err := callCheckFunction(client, context, selector, script)
-	if err != nil {
-		return false, err
-	}
-
-	var data struct {
-		Enabled bool   `json:"enabled"`
-		Error   string `json:"error,omitempty"`
-	}
-	if err := json.Unmarshal([]byte(result), &data); err != nil {
-		return false, fmt.Errorf("failed to parse enabled result: %w", err)
-	}
-
-	if data.Error != "" {
-		return false, fmt.Errorf("element %s", data.Error)
-	}
-
-	return data.Enabled, nil
+	return runCheckScript(client, context, selector, CheckEnabledType)
 }
 
 // CheckEditable verifies the element can accept text input.
@@ -205,73 +53,7 @@ func CheckEnabled(client *bidi.Client, context, selector string) (bool, error) {
 // - It does not have aria-readonly="true"
 // - For contenteditable, it must be "true" or ""
 func CheckEditable(client *bidi.Client, context, selector string) (bool, error) {
-	// First check if enabled
-	enabled, err := CheckEnabled(client, context, selector)
-	if err != nil {
-		return false, err
-	}
-	if !enabled {
-		return false, nil
-	}
-
-	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return JSON.stringify({ error: 'not found' });
-
-			// Check readonly attribute
-			if (el.readOnly === true) {
-				return JSON.stringify({ editable: false, reason: 'readonly attribute' });
-			}
-
-			// Check aria-readonly
-			if (el.getAttribute('aria-readonly') === 'true') {
-				return JSON.stringify({ editable: false, reason: 'aria-readonly' });
-			}
-
-			// For input/textarea, check if it's a type that accepts text
-			const tag = el.tagName.toLowerCase();
-			if (tag === 'input') {
-				const type = (el.type || 'text').toLowerCase();
-				const textTypes = ['text', 'password', 'email', 'number', 'search', 'tel', 'url'];
-				if (!textTypes.includes(type)) {
-					return JSON.stringify({ editable: false, reason: 'input type ' + type + ' not editable' });
-				}
-			}
-
-			// Check contenteditable
-			if (el.isContentEditable) {
-				return JSON.stringify({ editable: true });
-			}
-
-			// For form elements, they're editable if we got here
-			if (tag === 'input' || tag === 'textarea') {
-				return JSON.stringify({ editable: true });
-			}
-
-			// Non-form elements without contenteditable are not editable
-			return JSON.stringify({ editable: false, reason: 'not a form element or contenteditable' });
-		}
-	`
-
-	result, err := callCheckFunction(client, context, selector, script)
-	if err != nil {
-		return false, err
-	}
-
-	var data struct {
-		Editable bool   `json:"editable"`
-		Error    string `json:"error,omitempty"`
-	}
-	if err := json.Unmarshal([]byte(result), &data); err != nil {
-		return false, fmt.Errorf("failed to parse editable result: %w", err)
-	}
-
-	if data.Error != "" {
-		return false, fmt.Errorf("element %s", data.Error)
-	}
-
-	return data.Editable, nil
+	return runCheckScript(client, context, selector, CheckEditableType)
 }
 
 // CheckAll runs all actionability checks and returns the results.
@@ -308,101 +90,143 @@ func CheckAll(client *bidi.Client, context, selector string) (*ActionabilityResu
 	return result, nil
 }
 
-// callCheckFunction is a helper to execute a script and return the JSON string result.
-func callCheckFunction(client *bidi.Client, context, selector, script string) (string, error) {
-	if context == "" {
-		tree, err := client.GetTree()
-		if err != nil {
-			return "", fmt.Errorf("failed to get browsing context: %w", err)
+// checkScripts holds each check as a JavaScript function (el, rect) that
+// returns why the element fails the check, or an empty string if it passes.
+// rect is the element's bounding box when the checks started. Functions may
+// call each other through checks, e.g. Editable runs Enabled first. Both the
+// single check scripts and ActionableScript are built from them.
+var checkScripts = []struct {
+	check  Check
+	script string
+}{
+	{CheckVisibleType, `(el, rect) => {
+		if (rect.width === 0 || rect.height === 0) return 'zero size';
+		const style = window.getComputedStyle(el);
+		if (style.visibility === 'hidden') return 'visibility hidden';
+		if (style.display === 'none') return 'display none';
+		return '';
+	}`},
+	{CheckStableType, `async (el, rect) => {
+		// Compare the box with the one 50ms later
+		await new Promise((resolve) => setTimeout(resolve, 50));
+		const later = el.getBoundingClientRect();
+		if (later.x !== rect.x || later.y !== rect.y || later.width !== rect.width || later.height !== rect.height) {
+			return 'still moving';
 		}
-		if len(tree.Contexts) == 0 {
-			return "", fmt.Errorf("no browsing contexts available")
+		return '';
+	}`},
+	{CheckReceivesEventsType, `(el, rect) => {
+		// Get element at center point, within the element's shadow root if any
+		const root = el.getRootNode();
+		const hit = (root.elementFromPoint ? root : document).elementFromPoint(rect.x + rect.width / 2, rect.y + rect.height / 2);
+		if (!hit) return 'no element at point';
+		// Check if hit target is the element or a descendant
+		if (hit !== el && !el.contains(hit)) return 'obscured by ' + hit.tagName.toLowerCase();
+		return '';
+	}`},
+	{CheckEnabledType, `(el, rect) => {
+		if (el.disabled === true) return 'disabled attribute';
+		if (el.getAttribute('aria-disabled') === 'true') return 'aria-disabled';
+		const fieldset = el.closest('fieldset[disabled]');
+		if (fieldset) {
+			// Exception: elements in the first legend are not disabled
+			const legend = fieldset.querySelector('legend');
+			if (!legend || !legend.contains(el)) return 'inside disabled fieldset';
 		}
-		context = tree.Contexts[0].Context
-	}
-
-	params := map[string]interface{}{
-		"functionDeclaration": script,
-		"target":              map[string]interface{}{"context": context},
-		"arguments": []map[string]interface{}{
-			{"type": "string", "value": selector},
-		},
-		"awaitPromise":    false,
-		"resultOwnership": "root",
-	}
-
-	msg, err := client.SendCommand("script.callFunction", params)
-	if err != nil {
-		return "", err
-	}
-
-	// Parse the result
-	var callResult struct {
-		Type   string          `json:"type"`
-		Result json.RawMessage `json:"result"`
-	}
-	if err := json.Unmarshal(msg.Result, &callResult); err != nil {
-		return "", fmt.Errorf("failed to parse script.callFunction result: %w", err)
-	}
-
-	if callResult.Type == "exception" {
-		return "", fmt.Errorf("script exception: %s", string(callResult.Result))
-	}
-
-	// Parse the remote value (string containing JSON)
-	var remoteValue struct {
-		Type  string `json:"type"`
-		Value string `json:"value,omitempty"`
-	}
+		return '';
+	}`},
+	{CheckEditableType, `(el, rect) => {
+		const disabled = checks.Enabled(el, rect);
+		if (disabled) return disabled;
+		if (el.readOnly === true) return 'readonly attribute';
+		if (el.getAttribute('aria-readonly') === 'true') return 'aria-readonly';
+		// For input, check if it's a type that accepts text
+		const tag = el.tagName.toLowerCase();
+		if (tag === 'input') {
+			const type = (el.type || 'text').toLowerCase();
+			const textTypes = ['text', 'password', 'email', 'number', 'search', 'tel', 'url'];
+			if (!textTypes.includes(type)) return 'input type ' + type + ' not editable';
+		}
+		// Non-form elements without contenteditable are not editable
+		if (!el.isContentEditable && tag !== 'input' && tag !== 'textarea') {
+			return 'not a form element or contenteditable';
+		}
+		return '';
+	}`},
+}
 
-	if err := json.Unmarshal(callResult.Result, &remoteValue); err != nil {
-		return "", fmt.Errorf("failed to parse remote value: %w", err)
+// checksObject returns the JavaScript source of an object mapping each
+// check name to its function in checkScripts.
+func checksObject() string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, c := range checkScripts {
+		fmt.Fprintf(&b, "\t\t%s: %s,\n", c.check, c.script)
 	}
-
-	return remoteValue.Value, nil
+	b.WriteString("\t}")
+	return b.String()
 }
 
-// getBoundingBox returns the element's bounding box coordinates.
-func getBoundingBox(client *bidi.Client, context, selector string) (*bidi.BoxInfo, error) {
-	script := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return JSON.stringify({ error: 'not found' });
-
-			const rect = el.getBoundingClientRect();
-			return JSON.stringify({
-				x: rect.x,
-				y: rect.y,
-				width: rect.width,
-				height: rect.height
-			});
-		}
-	`
-
-	result, err := callCheckFunction(client, context, selector, script)
-	if err != nil {
-		return nil, err
-	}
+// checkScript is a function declaration for script.callFunction that takes
+// a selector and runs one check, resolving to { reason } with an empty
+// reason if the check passes, or { error } if no element matches.
+func checkScript(check Check) string {
+	return bidi.WithElementQuery(fmt.Sprintf(`
+	async (selector) => {
+		const checks = %s;
+		const el = queryElement(selector);
+		if (!el) return { error: 'not found' };
+		return { reason: await checks[%q](el, el.getBoundingClientRect()) };
+	}
+`, checksObject(), check))
+}
 
+// runCheckScript runs a single check on the element.
+func runCheckScript(client *bidi.Client, context, selector string, check Check) (bool, error) {
 	var data struct {
-		X      float64 `json:"x"`
-		Y      float64 `json:"y"`
-		Width  float64 `json:"width"`
-		Height float64 `json:"height"`
-		Error  string  `json:"error,omitempty"`
+		Reason string `json:"reason"`
+		Error  string `json:"error,omitempty"`
 	}
-	if err := json.Unmarshal([]byte(result), &data); err != nil {
-		return nil, fmt.Errorf("failed to parse bounding box: %w", err)
+	if err := client.CallFunctionInto(context, checkScript(check), []interface{}{selector}, &data); err != nil {
+		return false, err
 	}
 
 	if data.Error != "" {
-		return nil, fmt.Errorf("element %s", data.Error)
+		return false, fmt.Errorf("element %s", data.Error)
 	}
 
-	return &bidi.BoxInfo{
-		X:      data.X,
-		Y:      data.Y,
-		Width:  data.Width,
-		Height: data.Height,
-	}, nil
+	return data.Reason == "", nil
 }
+
+// ActionableScript is a function declaration for script.callFunction that
+// takes (selector, checks), where checks are Check names such as "Visible",
+// and runs the checks in one call, for callers that send raw BiDi commands.
+// It resolves to an object with the element's tag, text and box, plus
+// the first failed check and its reason, or null if no element matches, or
+// an invalid field if the selector is malformed. The Stable check waits
+// 50ms, like CheckStable. The selector may be a snapshot ref.
+var ActionableScript = bidi.WithElementQuery(`
+	async (selector, names) => {
+		const checks = ` + checksObject() + `;
+		let el;
+		try {
+			el = queryElement(selector);
+		} catch (e) {
+			return { invalid: e.message };
+		}
+		if (!el) return null;
+
+		const rect = el.getBoundingClientRect();
+		const info = {
+			tag: el.tagName.toLowerCase(),
+			text: (el.textContent || '').trim().substring(0, 100),
+			box: { x: rect.x, y: rect.y, width: rect.width, height: rect.height }
+		};
+		for (const name of names) {
+			if (!checks[name]) continue;
+			const reason = await checks[name](el, rect);
+			if (reason) return { ...info, failed: name, reason };
+		}
+		return info;
+	}
+`)
@@ -0,0 +1,150 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDOM is a minimal page for running the check scripts in node. Elements
+// are looked up by selector; hit says what elementFromPoint returns ("self",
+// "none" or "cover"), and a moving element shifts right on every
+// getBoundingClientRect call.
+const fakeDOM = `
+const elements = {};
+let current = null;
+const element = (props) => ({
+	tagName: 'INPUT', type: 'text', textContent: '', disabled: false, readOnly: false,
+	isContentEditable: false, attrs: {}, style: { visibility: 'visible', display: 'block' },
+	box: { x: 0, y: 0, width: 10, height: 10 }, moving: false, hit: 'self', fieldset: null,
+	...props,
+	getBoundingClientRect() {
+		const box = { ...this.box };
+		if (this.moving) this.box.x++;
+		return box;
+	},
+	getAttribute(name) { return name in this.attrs ? this.attrs[name] : null; },
+	closest() { return this.fieldset; },
+	getRootNode() { return document; },
+	contains(other) { return other === this; },
+});
+globalThis.window = globalThis;
+window.getComputedStyle = (el) => el.style;
+globalThis.document = {
+	querySelector: (selector) => elements[selector] || null,
+	elementFromPoint: () => ({ self: current, none: null, cover: { tagName: 'DIV' } })[current.hit],
+};
+`
+
+func TestCheckScriptsAgree(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	// Each element fails check with reason, or passes every check
+	tests := []struct {
+		selector string
+		props    string
+		check    Check
+		reason   string
+	}{
+		{"#ok", `{}`, CheckEditableType, ""},
+		{"#zero", `{ box: { x: 0, y: 0, width: 0, height: 10 } }`, CheckVisibleType, "zero size"},
+		{"#hidden", `{ style: { visibility: 'hidden', display: 'block' } }`, CheckVisibleType, "visibility hidden"},
+		{"#none", `{ style: { visibility: 'visible', display: 'none' } }`, CheckVisibleType, "display none"},
+		{"#moving", `{ moving: true }`, CheckStableType, "still moving"},
+		{"#covered", `{ hit: 'cover' }`, CheckReceivesEventsType, "obscured by div"},
+		{"#offscreen", `{ hit: 'none' }`, CheckReceivesEventsType, "no element at point"},
+		{"#disabled", `{ disabled: true }`, CheckEnabledType, "disabled attribute"},
+		{"#aria-disabled", `{ attrs: { 'aria-disabled': 'true' } }`, CheckEnabledType, "aria-disabled"},
+		{"#fieldset", `{ fieldset: { querySelector: () => null } }`, CheckEnabledType, "inside disabled fieldset"},
+		{"#readonly", `{ readOnly: true }`, CheckEditableType, "readonly attribute"},
+		{"#checkbox", `{ type: 'checkbox' }`, CheckEditableType, "input type checkbox not editable"},
+		{"#div", `{ tagName: 'DIV' }`, CheckEditableType, "not a form element or contenteditable"},
+		{"#contenteditable", `{ tagName: 'DIV', isContentEditable: true }`, CheckEditableType, ""},
+	}
+	allChecks := []Check{CheckVisibleType, CheckStableType, CheckReceivesEventsType, CheckEnabledType, CheckEditableType}
+
+	var program strings.Builder
+	program.WriteString(fakeDOM)
+	for _, tt := range tests {
+		fmt.Fprintf(&program, "elements[%q] = element(%s);\n", tt.selector, tt.props)
+	}
+	program.WriteString("const single = {\n")
+	for _, check := range allChecks {
+		fmt.Fprintf(&program, "%s: %s,\n", check, checkScript(check))
+	}
+	fmt.Fprintf(&program, "};\nconst combined = %s;\n", ActionableScript)
+	program.WriteString(`
+(async () => {
+	const results = {};
+	for (const selector of [...Object.keys(elements), '#missing']) {
+		for (const check of Object.keys(single)) {
+			current = elements[selector];
+			const one = await single[check](selector);
+			const all = await combined(selector, [check]);
+			results[selector + ' ' + check] = { single: one, combined: all };
+		}
+	}
+	console.log(JSON.stringify(results));
+})();
+`)
+
+	path := filepath.Join(t.TempDir(), "checks.js")
+	if err := os.WriteFile(path, []byte(program.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(node, path).Output()
+	if err != nil {
+		t.Fatalf("node: %v", err)
+	}
+
+	type singleResult struct {
+		Reason *string `json:"reason"`
+		Error  string  `json:"error"`
+	}
+	type combinedResult struct {
+		Tag    string `json:"tag"`
+		Failed string `json:"failed"`
+		Reason string `json:"reason"`
+	}
+	var results map[string]struct {
+		Single   singleResult    `json:"single"`
+		Combined *combinedResult `json:"combined"`
+	}
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("failed to parse node output %s: %v", out, err)
+	}
+
+	for _, check := range allChecks {
+		got := results["#missing "+check.String()]
+		if got.Single.Error != "not found" || got.Combined != nil {
+			t.Errorf("#missing %s: single = %+v, combined = %+v, want not found and null", check, got.Single, got.Combined)
+		}
+	}
+
+	for _, tt := range tests {
+		for _, check := range allChecks {
+			name := tt.selector + " " + check.String()
+			got, ok := results[name]
+			if !ok || got.Single.Reason == nil || got.Combined == nil {
+				t.Errorf("%s: single = %+v, combined = %+v", name, got.Single, got.Combined)
+				continue
+			}
+			if *got.Single.Reason != got.Combined.Reason {
+				t.Errorf("%s: single reason %q, combined reason %q", name, *got.Single.Reason, got.Combined.Reason)
+			}
+			if got.Combined.Reason != "" && got.Combined.Failed != check.String() {
+				t.Errorf("%s: combined failed %q", name, got.Combined.Failed)
+			}
+			if check == tt.check && got.Combined.Reason != tt.reason {
+				t.Errorf("%s: reason %q, want %q", name, got.Combined.Reason, tt.reason)
+			}
+		}
+	}
+}
@@ -43,6 +43,24 @@ func (c Check) String() string {
 	}
 }
 
+// waitingFor describes the state a check waits for, for progress messages.
+func (c Check) waitingFor() string {
+	switch c {
+	case CheckVisibleType:
+		return "be visible"
+	case CheckStableType:
+		return "stop moving"
+	case CheckReceivesEventsType:
+		return "receive pointer events"
+	case CheckEnabledType:
+		return "be enabled"
+	case CheckEditableType:
+		return "be editable"
+	default:
+		return "be actionable"
+	}
+}
+
 // Predefined check sets for different actions
 var (
 	// ClickChecks are the checks required before clicking an element.
@@ -67,6 +85,32 @@ var (
 type WaitOptions struct {
 	Timeout  time.Duration
 	Interval time.Duration
+
+	// Done aborts the wait with errors.ErrCancelled when closed (optional).
+	Done <-chan struct{}
+
+	// OnProgress is called with a description of what the wait is blocked
+	// on, e.g. "waiting for 'button' to be visible", whenever it changes (optional).
+	OnProgress func(message string)
+}
+
+// sleep waits for the poll interval. It returns errors.ErrCancelled if the
+// wait is aborted first.
+func (o WaitOptions) sleep() error {
+	select {
+	case <-o.Done:
+		return errs.ErrCancelled
+	case <-time.After(o.Interval):
+		return nil
+	}
+}
+
+// progress reports a progress message if it differs from the last one.
+func (o WaitOptions) progress(last *string, message string) {
+	if o.OnProgress != nil && message != *last {
+		*last = message
+		o.OnProgress(message)
+	}
 }
 
 // DefaultWaitOptions returns the default wait configuration.
@@ -87,6 +131,7 @@ func WaitForSelector(client *bidi.Client, context, selector string, opts WaitOpt
 	}
 
 	deadline := time.Now().Add(opts.Timeout)
+	var lastProgress string
 
 	for {
 		// Check if element exists
@@ -104,8 +149,12 @@ func WaitForSelector(client *bidi.Client, context, selector string, opts WaitOpt
 			}
 		}
 
+		opts.progress(&lastProgress, fmt.Sprintf("waiting for '%s' to appear", selector))
+
 		// Wait before next poll
-		time.Sleep(opts.Interval)
+		if err := opts.sleep(); err != nil {
+			return err
+		}
 	}
 }
 
@@ -119,6 +168,7 @@ func WaitForActionable(client *bidi.Client, context, selector string, checks []C
 	}
 
 	deadline := time.Now().Add(opts.Timeout)
+	var lastProgress string
 
 	for {
 		// Run all checks
@@ -159,8 +209,12 @@ func WaitForActionable(client *bidi.Client, context, selector string, checks []C
 			}
 		}
 
+		opts.progress(&lastProgress, fmt.Sprintf("waiting for '%s' to %s", selector, failedCheck.waitingFor()))
+
 		// Wait before next poll
-		time.Sleep(opts.Interval)
+		if err := opts.sleep(); err != nil {
+			return err
+		}
 	}
 }
 
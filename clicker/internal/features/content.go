@@ -0,0 +1,261 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/vibium/clicker/internal/bidi"
+)
+
+// ContentFormat is the output format of page content extraction.
+type ContentFormat string
+
+const (
+	ContentMarkdown ContentFormat = "markdown"
+	ContentText     ContentFormat = "text"
+	ContentHTML     ContentFormat = "html"
+)
+
+// ParseContentFormat parses a format name. "md" is accepted for markdown and
+// an empty name defaults to markdown.
+func ParseContentFormat(name string) (ContentFormat, error) {
+	switch name {
+	case "", "md", "markdown":
+		return ContentMarkdown, nil
+	case "text", "txt":
+		return ContentText, nil
+	case "html":
+		return ContentHTML, nil
+	default:
+		return "", fmt.Errorf("invalid format %q (expected md, text or html)", name)
+	}
+}
+
+// ContentOptions configures page content extraction.
+type ContentOptions struct {
+	Format    ContentFormat
+	Selector  string // Only extract this element (default: main content)
+	MaxLength int    // Maximum length in characters (0 = unlimited)
+}
+
+// PageContent is the extracted content of a page.
+type PageContent struct {
+	URL       string        `json:"url"`
+	Title     string        `json:"title"`
+	Format    ContentFormat `json:"format"`
+	Content   string        `json:"content"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// ContentScript is a function declaration for script.callFunction that takes
+// (selector, format) and returns the page content as a JSON string with url,
+// title and content fields, or an error field.
+//
+// Without a selector, the first main, [role=main] or article element is
+// used, falling back to the body without navigation and footers. Markdown
+// output keeps headings, links (as absolute URLs), images, emphasis, code,
+// lists, blockquotes and tables.
+var ContentScript = bidi.WithElementQuery(`
+	(selector, format) => {
+		let root;
+		let skipChrome = false;
+		if (selector) {
+			root = queryElement(selector);
+			if (!root) return JSON.stringify({ error: 'not found' });
+		} else {
+			root = document.querySelector('main, [role="main"], article');
+			if (!root) {
+				root = document.body || document.documentElement;
+				skipChrome = true;
+			}
+		}
+
+		const page = { url: location.href, title: document.title };
+		if (format === 'html') {
+			return JSON.stringify({ ...page, content: root.outerHTML });
+		}
+		if (format === 'text') {
+			return JSON.stringify({ ...page, content: (root.innerText || root.textContent || '').trim() });
+		}
+
+		const SKIP = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE', 'SVG', 'CANVAS', 'IFRAME',
+			'BUTTON', 'INPUT', 'SELECT', 'TEXTAREA', 'HEAD']);
+		const CHROME = new Set(['NAV', 'FOOTER', 'ASIDE']);
+		const BLOCK = new Set(['P', 'DIV', 'SECTION', 'ARTICLE', 'MAIN', 'HEADER', 'FOOTER', 'ASIDE',
+			'NAV', 'FORM', 'FIGURE', 'FIGCAPTION', 'DL', 'DT', 'DD', 'DETAILS', 'SUMMARY', 'ADDRESS']);
+
+		const isHidden = (el) => {
+			if (el.hidden || el.getAttribute('aria-hidden') === 'true') return true;
+			const style = window.getComputedStyle(el);
+			return style.display === 'none' || style.visibility === 'hidden';
+		};
+
+		const escapeCell = (s) => s.replace(/\|/g, '\\|').replace(/\n+/g, ' ').trim();
+
+		const inline = (el) => children(el).replace(/\s*\n\s*/g, ' ').trim();
+
+		const children = (el) => {
+			let out = '';
+			for (const child of el.childNodes) out += convert(child);
+			return out;
+		};
+
+		const list = (el, ordered) => {
+			let out = '\n\n';
+			let n = Number(el.getAttribute('start')) || 1;
+			for (const item of el.children) {
+				if (item.tagName !== 'LI' || isHidden(item)) continue;
+				const marker = ordered ? (n++) + '. ' : '- ';
+				const body = children(item).trim().replace(/\n{2,}/g, '\n')
+					.replace(/\n/g, '\n' + ' '.repeat(marker.length));
+				out += marker + body + '\n';
+			}
+			return out + '\n';
+		};
+
+		const table = (el) => {
+			const rows = Array.from(el.querySelectorAll('tr'))
+				.filter((tr) => tr.closest('table') === el)
+				.map((tr) => Array.from(tr.children)
+					.filter((c) => c.tagName === 'TD' || c.tagName === 'TH')
+					.map((c) => escapeCell(inline(c))));
+			if (rows.length === 0) return '';
+			const width = Math.max(...rows.map((r) => r.length));
+			const line = (r) => '| ' + Array.from({ length: width }, (_, i) => r[i] || '').join(' | ') + ' |';
+			let out = '\n\n' + line(rows[0]) + '\n' + line(Array(width).fill('---')) + '\n';
+			for (const r of rows.slice(1)) out += line(r) + '\n';
+			return out + '\n';
+		};
+
+		const convert = (node) => {
+			if (node.nodeType === Node.TEXT_NODE) {
+				return node.textContent.replace(/\s+/g, ' ');
+			}
+			if (node.nodeType !== Node.ELEMENT_NODE) return '';
+
+			const el = node;
+			const tag = el.tagName;
+			if (SKIP.has(tag) || isHidden(el)) return '';
+			if (skipChrome && (CHROME.has(tag) || el.getAttribute('role') === 'navigation')) return '';
+
+			switch (tag) {
+				case 'H1': case 'H2': case 'H3': case 'H4': case 'H5': case 'H6': {
+					const text = inline(el);
+					return text ? '\n\n' + '#'.repeat(Number(tag[1])) + ' ' + text + '\n\n' : '';
+				}
+				case 'BR':
+					return '\n';
+				case 'HR':
+					return '\n\n---\n\n';
+				case 'A': {
+					const text = inline(el);
+					const href = el.getAttribute('href');
+					if (!text) return '';
+					if (!href || href.startsWith('#') || href.startsWith('javascript:')) return text;
+					return '[' + text + '](' + el.href + ')';
+				}
+				case 'IMG': {
+					const alt = (el.getAttribute('alt') || '').trim();
+					return el.src ? '![' + alt + '](' + el.src + ')' : '';
+				}
+				case 'STRONG': case 'B': {
+					const text = inline(el);
+					return text ? '**' + text + '**' : '';
+				}
+				case 'EM': case 'I': {
+					const text = inline(el);
+					return text ? '*' + text + '*' : '';
+				}
+				case 'CODE':
+					return el.closest('pre') ? el.textContent : '` + "`" + `' + el.textContent + '` + "`" + `';
+				case 'PRE':
+					return '\n\n` + "```" + `\n' + el.textContent.replace(/\n$/, '') + '\n` + "```" + `\n\n';
+				case 'UL': case 'MENU':
+					return list(el, false);
+				case 'OL':
+					return list(el, true);
+				case 'TABLE':
+					return table(el);
+				case 'BLOCKQUOTE': {
+					const text = children(el).trim().replace(/\n{3,}/g, '\n\n');
+					return '\n\n' + text.split('\n').map((l) => '> ' + l).join('\n') + '\n\n';
+				}
+				default:
+					if (BLOCK.has(tag)) return '\n\n' + children(el) + '\n\n';
+					return children(el);
+			}
+		};
+
+		// Tidy whitespace left by inline text, except inside code blocks
+		// and the indentation of nested list items
+		let inCode = false;
+		const markdown = convert(root)
+			.split('\n')
+			.map((l) => {
+				if (l.startsWith('` + "```" + `')) inCode = !inCode;
+				if (inCode) return l;
+				l = l.replace(/[ \t]+$/, '');
+				return /^ +(- |\d+\. )/.test(l) ? l : l.replace(/^[ \t]+/, '');
+			})
+			.join('\n')
+			.replace(/\n{3,}/g, '\n\n')
+			.trim();
+
+		return JSON.stringify({ ...page, content: markdown });
+	}
+`)
+
+// ParseContentResult parses the JSON string returned by ContentScript and
+// applies the format and length options.
+func ParseContentResult(result string, opts ContentOptions) (*PageContent, error) {
+	var data struct {
+		URL     string `json:"url"`
+		Title   string `json:"title"`
+		Content string `json:"content"`
+		Error   string `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(result), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse content: %w", err)
+	}
+	if data.Error != "" {
+		return nil, fmt.Errorf("element '%s' %s", opts.Selector, data.Error)
+	}
+
+	content := &PageContent{
+		URL:     data.URL,
+		Title:   data.Title,
+		Format:  opts.Format,
+		Content: data.Content,
+	}
+	content.Content, content.Truncated = TruncateContent(data.Content, opts.MaxLength)
+
+	return content, nil
+}
+
+// TruncateContent shortens s to at most max characters. It returns s
+// unchanged if max is 0 or s is short enough.
+func TruncateContent(s string, max int) (string, bool) {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	runes := []rune(s)
+	return string(runes[:max]), true
+}
+
+// ExtractContent returns the content of the document in the given context
+// as Markdown, plain text or HTML.
+// If context is empty, it uses the first available context.
+func ExtractContent(client *bidi.Client, context string, opts ContentOptions) (*PageContent, error) {
+	if opts.Format == "" {
+		opts.Format = ContentMarkdown
+	}
+
+	result, err := client.CallFunction(context, ContentScript, []interface{}{opts.Selector, string(opts.Format)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract content: %w", err)
+	}
+
+	str, _ := result.(string)
+	return ParseContentResult(str, opts)
+}
@@ -0,0 +1,319 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vibium/clicker/internal/bidi"
+)
+
+var refPattern = regexp.MustCompile(`^e[0-9]+$`)
+
+// SnapshotNode is an element (or run of text) in an accessibility snapshot.
+type SnapshotNode struct {
+	Ref      string          `json:"ref,omitempty"`
+	Role     string          `json:"role"`
+	Name     string          `json:"name,omitempty"`
+	Value    string          `json:"value,omitempty"`
+	Level    int             `json:"level,omitempty"`  // Heading level
+	States   []string        `json:"states,omitempty"` // e.g. "checked", "disabled", "expanded"
+	Children []*SnapshotNode `json:"children,omitempty"`
+}
+
+// Snapshot is a compact accessibility-style tree of a page.
+type Snapshot struct {
+	URL   string          `json:"url"`
+	Title string          `json:"title"`
+	Nodes []*SnapshotNode `json:"nodes"`
+}
+
+// IsRef returns true if s is a snapshot ref such as "e12".
+func IsRef(s string) bool {
+	return refPattern.MatchString(s)
+}
+
+// RefSelector returns the selector that matches the element with the ref.
+// Only functions that resolve selectors with bidi.WithElementQuery accept it.
+func RefSelector(ref string) string {
+	return bidi.RefSelectorPrefix + ref
+}
+
+// TakeSnapshot walks the DOM of the document in the given context and returns
+// its accessibility tree. Hidden elements are skipped, generic containers are
+// flattened, and every element with a role is given a ref. Shadow roots are
+// walked in place of their host's children, with slotted elements under
+// their slot. Refs are kept in the page's bidi.RefRegistry, not in the DOM,
+// and stay the same across snapshots until the document is replaced.
+// If context is empty, it uses the first available context.
+func TakeSnapshot(client *bidi.Client, context string) (*Snapshot, error) {
+	script := fmt.Sprintf(`
+		() => {
+			const REGISTRY = Symbol.for(%q);
+			const MAX_TEXT = 200;
+
+			const SKIP = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE', 'HEAD', 'META', 'LINK']);
+			const NAME_FROM_CONTENT = new Set([
+				'button', 'link', 'heading', 'option', 'menuitem', 'tab', 'cell',
+				'columnheader', 'rowheader', 'checkbox', 'radio', 'treeitem', 'tooltip',
+			]);
+
+			const clean = (s) => {
+				s = (s || '').replace(/\s+/g, ' ').trim();
+				return s.length > MAX_TEXT ? s.slice(0, MAX_TEXT) + '…' : s;
+			};
+
+			const isHidden = (el) => {
+				if (el.hidden || el.getAttribute('aria-hidden') === 'true') return true;
+				const style = window.getComputedStyle(el);
+				return style.display === 'none' || style.visibility === 'hidden';
+			};
+
+			const implicitRole = (el) => {
+				const tag = el.tagName;
+				switch (tag) {
+					case 'A': case 'AREA': return el.hasAttribute('href') ? 'link' : null;
+					case 'BUTTON': case 'SUMMARY': return 'button';
+					case 'INPUT': {
+						const type = (el.getAttribute('type') || 'text').toLowerCase();
+						switch (type) {
+							case 'hidden': return null;
+							case 'checkbox': return 'checkbox';
+							case 'radio': return 'radio';
+							case 'range': return 'slider';
+							case 'number': return 'spinbutton';
+							case 'search': return 'searchbox';
+							case 'button': case 'submit': case 'reset': case 'image': return 'button';
+							default: return 'textbox';
+						}
+					}
+					case 'TEXTAREA': return 'textbox';
+					case 'SELECT': return el.multiple || el.size > 1 ? 'listbox' : 'combobox';
+					case 'OPTION': return 'option';
+					case 'IMG': return el.getAttribute('alt') === '' ? null : 'img';
+					case 'H1': case 'H2': case 'H3': case 'H4': case 'H5': case 'H6': return 'heading';
+					case 'NAV': return 'navigation';
+					case 'MAIN': return 'main';
+					case 'HEADER': return 'banner';
+					case 'FOOTER': return 'contentinfo';
+					case 'ASIDE': return 'complementary';
+					case 'FORM': return 'form';
+					case 'SECTION': return el.hasAttribute('aria-label') || el.hasAttribute('aria-labelledby') ? 'region' : null;
+					case 'ARTICLE': return 'article';
+					case 'UL': case 'OL': case 'MENU': return 'list';
+					case 'LI': return 'listitem';
+					case 'TABLE': return 'table';
+					case 'TR': return 'row';
+					case 'TD': return 'cell';
+					case 'TH': return el.getAttribute('scope') === 'row' ? 'rowheader' : 'columnheader';
+					case 'DIALOG': return 'dialog';
+					case 'P': return 'paragraph';
+					case 'HR': return 'separator';
+					case 'PROGRESS': return 'progressbar';
+					default: return null;
+				}
+			};
+
+			const roleOf = (el) => {
+				const explicit = (el.getAttribute('role') || '').trim().split(/\s+/)[0];
+				if (explicit === 'none' || explicit === 'presentation') return null;
+				return explicit || implicitRole(el);
+			};
+
+			const nameOf = (el, role) => {
+				const label = el.getAttribute('aria-label');
+				if (label && label.trim()) return clean(label);
+
+				const labelledBy = el.getAttribute('aria-labelledby');
+				if (labelledBy) {
+					const text = labelledBy.split(/\s+/)
+						.map((id) => el.getRootNode().getElementById(id))
+						.filter(Boolean)
+						.map((l) => l.textContent)
+						.join(' ');
+					if (text.trim()) return clean(text);
+				}
+
+				if (el.labels && el.labels.length > 0) {
+					return clean(Array.from(el.labels).map((l) => l.textContent).join(' '));
+				}
+				if (el.tagName === 'IMG' || el.tagName === 'AREA') return clean(el.getAttribute('alt'));
+				if (el.tagName === 'INPUT' && ['button', 'submit', 'reset'].includes(el.type)) {
+					return clean(el.value);
+				}
+				if (NAME_FROM_CONTENT.has(role)) return clean(el.innerText || el.textContent);
+				return clean(el.getAttribute('title') || el.getAttribute('placeholder'));
+			};
+
+			const valueOf = (el, role) => {
+				if (el.tagName === 'INPUT') {
+					if (['checkbox', 'radio', 'button', 'submit', 'reset', 'image'].includes(el.type)) return '';
+					return el.type === 'password' && el.value ? '••••' : el.value;
+				}
+				if (el.tagName === 'TEXTAREA') return clean(el.value);
+				if (el.tagName === 'SELECT') {
+					return Array.from(el.selectedOptions).map((o) => clean(o.textContent)).join(', ');
+				}
+				if (role === 'progressbar' || role === 'slider' || role === 'spinbutton') {
+					return el.getAttribute('aria-valuenow') || '';
+				}
+				return '';
+			};
+
+			const statesOf = (el) => {
+				const states = [];
+				if (el.disabled || el.getAttribute('aria-disabled') === 'true') states.push('disabled');
+				if (el.checked === true || el.getAttribute('aria-checked') === 'true') states.push('checked');
+				if (el.getAttribute('aria-checked') === 'mixed' || el.indeterminate) states.push('mixed');
+				if (el.selected === true || el.getAttribute('aria-selected') === 'true') states.push('selected');
+				if (el.getAttribute('aria-pressed') === 'true') states.push('pressed');
+				const expanded = el.getAttribute('aria-expanded') ||
+					(el.tagName === 'DETAILS' ? String(el.open) : null);
+				if (expanded === 'true') states.push('expanded');
+				if (expanded === 'false') states.push('collapsed');
+				if (el.required || el.getAttribute('aria-required') === 'true') states.push('required');
+				if (el.readOnly === true || el.getAttribute('aria-readonly') === 'true') states.push('readonly');
+				if (document.activeElement === el && el !== document.body) states.push('focused');
+				return states;
+			};
+
+			if (!window[REGISTRY]) {
+				Object.defineProperty(window, REGISTRY, {
+					value: { refs: new Map(), ids: new WeakMap(), next: 1 },
+				});
+			}
+			const registry = window[REGISTRY];
+			for (const [ref, target] of registry.refs) {
+				if (!target.deref()) registry.refs.delete(ref);
+			}
+			const refFor = (el) => {
+				let ref = registry.ids.get(el);
+				if (!ref) {
+					ref = 'e' + registry.next++;
+					registry.ids.set(el, ref);
+					registry.refs.set(ref, new WeakRef(el));
+				}
+				return ref;
+			};
+
+			// childrenOf returns the nodes rendered as children: the shadow
+			// tree of a host, the elements assigned to a slot, or the slot's
+			// fallback content if nothing is assigned.
+			const childrenOf = (el) => {
+				if (el.shadowRoot) return el.shadowRoot.childNodes;
+				if (el.tagName === 'SLOT') {
+					const assigned = el.assignedNodes();
+					if (assigned.length > 0) return assigned;
+				}
+				return el.childNodes;
+			};
+
+			// walk returns the snapshot nodes for a DOM node. Elements without
+			// a role return their children so generic containers are flattened.
+			const walk = (node, skipText) => {
+				if (node.nodeType === Node.TEXT_NODE) {
+					if (skipText) return [];
+					const text = clean(node.textContent);
+					return text ? [{ role: 'text', name: text }] : [];
+				}
+				if (node.nodeType !== Node.ELEMENT_NODE || SKIP.has(node.tagName) || isHidden(node)) {
+					return [];
+				}
+
+				const el = node;
+				const role = roleOf(el);
+				const children = [];
+				const childSkipText = skipText || (role !== null && NAME_FROM_CONTENT.has(role));
+				for (const child of childrenOf(el)) {
+					children.push(...walk(child, childSkipText));
+				}
+				if (el.tagName === 'SELECT' || el.tagName === 'TEXTAREA') {
+					children.length = 0;
+				}
+
+				if (!role) return children;
+
+				const item = { ref: refFor(el), role };
+				const name = nameOf(el, role);
+				if (name) item.name = name;
+				const value = valueOf(el, role);
+				if (value) item.value = value;
+				if (role === 'heading') {
+					item.level = Number(el.getAttribute('aria-level')) || Number(el.tagName.slice(1)) || 2;
+				}
+				const states = statesOf(el);
+				if (states.length > 0) item.states = states;
+
+				// A single text child that repeats the name adds nothing
+				if (children.length === 1 && children[0].role === 'text' && children[0].name === item.name) {
+					children.length = 0;
+				}
+				if (children.length > 0) item.children = children;
+				return [item];
+			};
+
+			const nodes = document.body ? walk(document.body, false) : [];
+
+			return JSON.stringify({ url: location.href, title: document.title, nodes });
+		}
+	`, bidi.RefRegistry)
+
+	result, err := client.CallFunction(context, script, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	str, _ := result.(string)
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(str), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// String renders the snapshot as an indented outline, one node per line:
+//
+//	Page: Example Domain
+//	URL: https://example.com/
+//
+//	- heading "Example Domain" [level=1] [ref=e1]
+//	- paragraph [ref=e2]
+//	  - text: This domain is for use in illustrative examples.
+//	  - link "Learn more" [ref=e3]
+func (s *Snapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Page: %s\nURL: %s\n\n", s.Title, s.URL)
+
+	var write func(nodes []*SnapshotNode, depth int)
+	write = func(nodes []*SnapshotNode, depth int) {
+		indent := strings.Repeat("  ", depth)
+		for _, node := range nodes {
+			if node.Role == "text" {
+				fmt.Fprintf(&b, "%s- text: %s\n", indent, node.Name)
+				continue
+			}
+
+			b.WriteString(indent + "- " + node.Role)
+			if node.Name != "" {
+				fmt.Fprintf(&b, " %q", node.Name)
+			}
+			if node.Value != "" {
+				fmt.Fprintf(&b, " [value=%q]", node.Value)
+			}
+			if node.Level > 0 {
+				fmt.Fprintf(&b, " [level=%d]", node.Level)
+			}
+			for _, state := range node.States {
+				fmt.Fprintf(&b, " [%s]", state)
+			}
+			fmt.Fprintf(&b, " [ref=%s]\n", node.Ref)
+
+			write(node.Children, depth+1)
+		}
+	}
+	write(s.Nodes, 0)
+
+	return strings.TrimRight(b.String(), "\n")
+}
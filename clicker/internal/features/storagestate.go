@@ -0,0 +1,194 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/vibium/clicker/internal/bidi"
+)
+
+// StorageState is a snapshot of cookies and Web Storage used to reuse an
+// authenticated session across browsers.
+type StorageState struct {
+	Cookies []bidi.Cookie `json:"cookies"`
+	Origins []OriginState `json:"origins"`
+}
+
+// OriginState holds the Web Storage items of a single origin.
+type OriginState struct {
+	Origin         string            `json:"origin"`
+	LocalStorage   map[string]string `json:"localStorage,omitempty"`
+	SessionStorage map[string]string `json:"sessionStorage,omitempty"`
+}
+
+// ReadStorageState reads a storage state JSON file.
+func ReadStorageState(path string) (*StorageState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage state: %w", err)
+	}
+
+	var state StorageState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse storage state %s: %w", path, err)
+	}
+
+	return &state, nil
+}
+
+// WriteFile writes the storage state as JSON. The file may contain session
+// secrets, so it is only readable by the current user.
+func (s *StorageState) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode storage state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write storage state: %w", err)
+	}
+	return nil
+}
+
+// SaveStorageState captures all cookies plus the localStorage and
+// sessionStorage of every origin loaded in a browsing context.
+func SaveStorageState(client *bidi.Client) (*StorageState, error) {
+	cookies, err := client.GetCookies(bidi.CookieFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+
+	tree, err := client.GetTree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get browsing context: %w", err)
+	}
+
+	script := `
+		() => {
+			const dump = (area) => {
+				const items = {};
+				for (let i = 0; i < area.length; i++) {
+					const key = area.key(i);
+					items[key] = area.getItem(key);
+				}
+				return items;
+			};
+			try {
+				return {
+					origin: location.origin,
+					localStorage: dump(window.localStorage),
+					sessionStorage: dump(window.sessionStorage),
+				};
+			} catch (e) {
+				// Storage is not accessible (e.g., opaque origin)
+				return { origin: 'null' };
+			}
+		}
+	`
+
+	state := &StorageState{Cookies: cookies, Origins: []OriginState{}}
+	seen := map[string]bool{}
+
+	var walk func(contexts []bidi.BrowsingContextInfo) error
+	walk = func(contexts []bidi.BrowsingContextInfo) error {
+		for _, ctx := range contexts {
+			var origin OriginState
+			if err := client.CallFunctionInto(ctx.Context, script, nil, &origin); err != nil {
+				return fmt.Errorf("failed to read storage in context %s: %w", ctx.Context, err)
+			}
+
+			if origin.Origin != "null" && !seen[origin.Origin] {
+				seen[origin.Origin] = true
+				if len(origin.LocalStorage) > 0 || len(origin.SessionStorage) > 0 {
+					state.Origins = append(state.Origins, origin)
+				}
+			}
+
+			if err := walk(ctx.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(tree.Contexts); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// ApplyStorageState restores cookies and installs a preload script that fills
+// each origin's Web Storage when a document of that origin loads, see
+// StorageStatePreloadScript. The documents already loaded get their
+// origin's items right away. Call it on a fresh session before the first
+// navigation.
+func ApplyStorageState(client *bidi.Client, state *StorageState) error {
+	for _, cookie := range state.Cookies {
+		if err := client.SetCookie(cookie); err != nil {
+			return fmt.Errorf("failed to set cookie %s: %w", cookie.Name, err)
+		}
+	}
+
+	script, err := StorageStatePreloadScript(state)
+	if err != nil {
+		return err
+	}
+	if script == "" {
+		return nil
+	}
+
+	if _, err := client.AddPreloadScript(script); err != nil {
+		return fmt.Errorf("failed to add storage state preload script: %w", err)
+	}
+
+	// Documents that are already loaded won't run the preload script
+	tree, err := client.GetTree()
+	if err != nil {
+		return fmt.Errorf("failed to get browsing context: %w", err)
+	}
+	for _, ctx := range tree.Contexts {
+		if _, err := client.CallFunction(ctx.Context, script, []interface{}{true}); err != nil {
+			return fmt.Errorf("failed to restore storage: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// StorageStatePreloadScript returns a function declaration that restores the
+// Web Storage of the current document's origin. A storage area is only
+// filled while it's empty, i.e. localStorage in the first document of the
+// origin and sessionStorage in the first document of the origin in each
+// tab, so the page's own changes are kept. Called with true, it fills the
+// areas regardless. Returns "" if the state has no Web Storage items.
+func StorageStatePreloadScript(state *StorageState) (string, error) {
+	if len(state.Origins) == 0 {
+		return "", nil
+	}
+
+	origins, err := json.Marshal(state.Origins)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode storage state: %w", err)
+	}
+
+	return fmt.Sprintf(`(force) => {
+		const origins = %s;
+
+		const entry = origins.find((o) => o.origin === location.origin);
+		if (!entry) return;
+
+		const fill = (area, items) => {
+			if (!items || (force !== true && area.length > 0)) return;
+			for (const [key, value] of Object.entries(items)) {
+				area.setItem(key, value);
+			}
+		};
+		try {
+			fill(window.localStorage, entry.localStorage);
+			fill(window.sessionStorage, entry.sessionStorage);
+		} catch (e) {
+			// Storage is not accessible in this document
+		}
+	}`, string(origins)), nil
+}
@@ -1,74 +1,245 @@
 package mcp
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/vibium/clicker/internal/bidi"
-	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
 	"github.com/vibium/clicker/internal/features"
 	"github.com/vibium/clicker/internal/log"
+	"github.com/vibium/clicker/internal/policy"
 )
 
-// Handlers manages browser session state and executes tool calls.
+// Handlers manages browser sessions and executes tool calls.
 type Handlers struct {
-	launchResult  *browser.LaunchResult
-	client        *bidi.Client
-	conn          *bidi.Connection
+	sessionsMu sync.Mutex
+	sessions   map[string]*browserSession
+	current    string // ID of the session used when a tool names none
+
 	screenshotDir string
+	stateDir      string
+
+	disableEvaluate bool
+	policy          *policy.Policy
+	emulation       *bidi.EmulationOptions
+
+	onResourceUpdated     func(uri string)
+	onResourceListChanged func()
 }
 
-// NewHandlers creates a new Handlers instance.
-// screenshotDir specifies where screenshots are saved. If empty, file saving is disabled.
-func NewHandlers(screenshotDir string) *Handlers {
+// maxEvaluateResultSize is the maximum size in bytes of the JSON returned by
+// browser_evaluate. Larger results are truncated (see truncateValue).
+const maxEvaluateResultSize = 50000
+
+// defaultContentMaxLength is the default maxLength of browser_get_content.
+const defaultContentMaxLength = 20000
+
+// NewHandlers creates a new Handlers instance configured by opts.
+func NewHandlers(opts ServerOptions) *Handlers {
 	return &Handlers{
-		screenshotDir: screenshotDir,
+		sessions:        make(map[string]*browserSession),
+		screenshotDir:   opts.ScreenshotDir,
+		stateDir:        opts.StateDir,
+		disableEvaluate: opts.DisableEvaluate,
+		policy:          opts.Policy,
+		emulation:       opts.Emulation,
 	}
 }
 
-// Call executes a tool by name with the given arguments.
-func (h *Handlers) Call(name string, args map[string]interface{}) (*ToolsCallResult, error) {
+// Tools returns the schemas of the tools this instance serves.
+func (h *Handlers) Tools() []Tool {
+	tools := []Tool{}
+	for _, tool := range GetToolSchemas() {
+		if tool.Name == "browser_evaluate" && h.disableEvaluate {
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// Call executes a tool by name with the given arguments. Calls on the same
+// browser session run one at a time; calls on different sessions run
+// concurrently. Cancelling ctx aborts waits and pending browser commands;
+// progress is reported to the function set with WithProgress.
+func (h *Handlers) Call(ctx context.Context, name string, args map[string]interface{}) (*ToolsCallResult, error) {
 	log.Debug("tool call", "name", name, "args", args)
 
+	if err := ctx.Err(); err != nil {
+		return nil, errs.ErrCancelled
+	}
+
+	// Tools that drive a browser wait for the session's other calls. The
+	// session tools lock what they close themselves, see closeSession.
+	sess, err := h.session(args)
+	if err == nil && !managesSessions(name) {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if ctx.Err() != nil {
+			return nil, errs.ErrCancelled
+		}
+
+		// Abort the browser command in flight when the call is cancelled
+		client := sess.client
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				client.Interrupt()
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			wg.Wait()
+		}()
+	}
+
+	start := time.Now()
+	result, err := h.dispatch(ctx, name, args)
+
+	// A navigation blocked during the call (e.g. by a click on a link)
+	// explains the failure better than the tool's own error
+	if sess != nil {
+		if violation := sess.takeViolation(); violation != nil {
+			return nil, violation
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.StructuredContent != nil {
+		result.StructuredContent["durationMs"] = time.Since(start).Milliseconds()
+	}
+	return result, nil
+}
+
+// managesSessions reports whether a tool opens, closes or lists sessions
+// rather than driving the browser of one.
+func managesSessions(name string) bool {
+	switch name {
+	case "browser_launch", "browser_quit", "browser_sessions_list":
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatch runs the handler of a tool.
+func (h *Handlers) dispatch(ctx context.Context, name string, args map[string]interface{}) (*ToolsCallResult, error) {
 	switch name {
 	case "browser_launch":
-		return h.browserLaunch(args)
+		return h.browserLaunch(ctx, args)
 	case "browser_navigate":
-		return h.browserNavigate(args)
+		return h.browserNavigate(ctx, args)
 	case "browser_click":
-		return h.browserClick(args)
+		return h.browserClick(ctx, args)
 	case "browser_type":
-		return h.browserType(args)
+		return h.browserType(ctx, args)
 	case "browser_screenshot":
-		return h.browserScreenshot(args)
+		return h.browserScreenshot(ctx, args)
 	case "browser_find":
-		return h.browserFind(args)
+		return h.browserFind(ctx, args)
+	case "browser_snapshot":
+		return h.browserSnapshot(ctx, args)
+	case "browser_get_content":
+		return h.browserGetContent(ctx, args)
+	case "browser_cookies_get":
+		return h.browserCookiesGet(ctx, args)
+	case "browser_cookies_set":
+		return h.browserCookiesSet(ctx, args)
+	case "browser_cookies_clear":
+		return h.browserCookiesClear(ctx, args)
+	case "browser_save_state":
+		return h.browserSaveState(ctx, args)
+	case "browser_load_state":
+		return h.browserLoadState(ctx, args)
+	case "browser_evaluate":
+		if h.disableEvaluate {
+			return nil, invalidArguments("browser_evaluate is disabled on this server")
+		}
+		return h.browserEvaluate(ctx, args)
+	case "browser_sessions_list":
+		return h.browserSessionsList(ctx, args)
 	case "browser_quit":
-		return h.browserQuit(args)
+		return h.browserQuit(ctx, args)
 	default:
-		return nil, fmt.Errorf("unknown tool: %s", name)
+		return nil, invalidArguments("unknown tool: %s", name)
 	}
 }
 
-// Close cleans up any active browser sessions.
+// invalidArguments returns an error for an unknown tool or bad arguments,
+// which tools/call reports as a JSON-RPC InvalidParams error rather than a
+// failed tool result.
+func invalidArguments(format string, a ...interface{}) error {
+	return &errs.InvalidArgumentError{Message: fmt.Sprintf(format, a...)}
+}
+
+// progressKey is the context key for the progress reporting function.
+type progressKey struct{}
+
+// WithProgress returns a context that reports tool progress messages to fn.
+func WithProgress(ctx context.Context, fn func(message string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress reports a progress message if ctx carries a progress function.
+func reportProgress(ctx context.Context, message string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(string)); ok && fn != nil {
+		fn(message)
+	}
+}
+
+// waitOptions returns the default wait options, cancelled by ctx and
+// reporting progress.
+func waitOptions(ctx context.Context) features.WaitOptions {
+	opts := features.DefaultWaitOptions()
+	opts.Done = ctx.Done()
+	opts.OnProgress = func(message string) {
+		reportProgress(ctx, message)
+	}
+	return opts
+}
+
+// Close closes all browser sessions.
 func (h *Handlers) Close() {
-	if h.conn != nil {
-		h.conn.Close()
-		h.conn = nil
+	h.sessionsMu.Lock()
+	ids := make([]string, 0, len(h.sessions))
+	for id := range h.sessions {
+		ids = append(ids, id)
 	}
-	if h.launchResult != nil {
-		h.launchResult.Close()
-		h.launchResult = nil
+	h.sessionsMu.Unlock()
+
+	for _, id := range ids {
+		h.closeSession(id)
 	}
-	h.client = nil
 }
 
-// browserLaunch launches a new browser session.
-func (h *Handlers) browserLaunch(args map[string]interface{}) (*ToolsCallResult, error) {
-	// Close any existing session
-	h.Close()
+// browserLaunch launches a browser session. Launching a session ID that is
+// already open replaces that session.
+func (h *Handlers) browserLaunch(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	id, _ := args["session"].(string)
+	if id == "" {
+		id = DefaultSessionID
+	}
+	if !sessionIDPattern.MatchString(id) {
+		return nil, invalidArguments("invalid session %q (use letters, digits, - and _)", id)
+	}
+
+	// Close any existing session with this ID
+	h.closeSession(id)
 
 	// Parse options
 	headless := false // Default: show browser for better first-time UX
@@ -77,129 +248,179 @@ func (h *Handlers) browserLaunch(args map[string]interface{}) (*ToolsCallResult,
 	}
 
 	// Launch browser
-	launchResult, err := browser.Launch(browser.LaunchOptions{Headless: headless})
+	reportProgress(ctx, "launching browser")
+	sess, err := launchSession(ctx, id, headless, h.emulation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to launch browser: %w", err)
+		return nil, err
 	}
 
-	// Connect to BiDi
-	conn, err := bidi.Connect(launchResult.WebSocketURL)
-	if err != nil {
-		launchResult.Close()
-		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	if err := h.watchPage(sess); err != nil {
+		sess.close()
+		return nil, fmt.Errorf("failed to subscribe to browser events: %w", err)
+	}
+	if h.policy != nil {
+		sess.stopPolicy, err = policy.Enforce(sess.client, h.policy, sess.addViolation)
+		if err != nil {
+			sess.close()
+			return nil, fmt.Errorf("failed to enforce URL policy: %w", err)
+		}
 	}
 
-	h.launchResult = launchResult
-	h.conn = conn
-	h.client = bidi.NewClient(conn)
+	// A concurrent launch of the same ID may have won; replace it
+	h.sessionsMu.Lock()
+	old := h.sessions[id]
+	h.sessions[id] = sess
+	h.current = id
+	h.sessionsMu.Unlock()
+	if old != nil {
+		old.lockedClose()
+	}
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: fmt.Sprintf("Browser launched (headless: %v)", headless),
+			Text: fmt.Sprintf("Browser launched (headless: %v, session: %s)", headless, id),
 		}},
+		StructuredContent: map[string]interface{}{
+			"session":  id,
+			"headless": headless,
+		},
 	}, nil
 }
 
 // browserNavigate navigates to a URL.
-func (h *Handlers) browserNavigate(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserNavigate(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	url, ok := args["url"].(string)
+	if !ok || url == "" {
+		return nil, invalidArguments("url is required")
+	}
+	if err := h.policy.Check(url); err != nil {
 		return nil, err
 	}
 
-	url, ok := args["url"].(string)
-	if !ok || url == "" {
-		return nil, fmt.Errorf("url is required")
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := h.client.Navigate("", url)
+	reportProgress(ctx, fmt.Sprintf("navigating to %s", url))
+	result, err := sess.client.Navigate("", url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to navigate: %w", err)
 	}
 
+	title, err := sess.pageTitle()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
 			Text: fmt.Sprintf("Navigated to %s", result.URL),
 		}},
+		StructuredContent: map[string]interface{}{
+			"url":   result.URL,
+			"title": title,
+		},
 	}, nil
 }
 
 // browserClick clicks an element.
-func (h *Handlers) browserClick(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserClick(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
 		return nil, err
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
+	selector, target, err := sess.targetSelector(args)
+	if err != nil {
+		return nil, err
 	}
 
 	// Wait for element to be actionable
-	opts := features.DefaultWaitOptions()
-	if err := features.WaitForClick(h.client, "", selector, opts); err != nil {
+	opts := waitOptions(ctx)
+	if err := features.WaitForClick(sess.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	// Describe the element before the click may change or remove it
+	element, err := sess.elementOutput(selector, args)
+	if err != nil {
 		return nil, err
 	}
 
 	// Click the element
-	if err := h.client.ClickElement("", selector); err != nil {
+	if err := sess.client.ClickElement("", selector); err != nil {
 		return nil, fmt.Errorf("failed to click: %w", err)
 	}
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: fmt.Sprintf("Clicked element: %s", selector),
+			Text: fmt.Sprintf("Clicked element: %s", target),
 		}},
+		StructuredContent: element,
 	}, nil
 }
 
 // browserType types text into an element.
-func (h *Handlers) browserType(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
-		return nil, err
+func (h *Handlers) browserType(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	text, ok := args["text"].(string)
+	if !ok {
+		return nil, invalidArguments("text is required")
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
 	}
 
-	text, ok := args["text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("text is required")
+	selector, target, err := sess.targetSelector(args)
+	if err != nil {
+		return nil, err
 	}
 
 	// Wait for element to be actionable
-	opts := features.DefaultWaitOptions()
-	if err := features.WaitForType(h.client, "", selector, opts); err != nil {
+	opts := waitOptions(ctx)
+	if err := features.WaitForType(sess.client, "", selector, opts); err != nil {
+		return nil, err
+	}
+
+	element, err := sess.elementOutput(selector, args)
+	if err != nil {
 		return nil, err
 	}
 
 	// Type into the element
-	if err := h.client.TypeIntoElement("", selector, text); err != nil {
+	if err := sess.client.TypeIntoElement("", selector, text); err != nil {
 		return nil, fmt.Errorf("failed to type: %w", err)
 	}
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: fmt.Sprintf("Typed into element: %s", selector),
+			Text: fmt.Sprintf("Typed into element: %s", target),
 		}},
+		StructuredContent: element,
 	}, nil
 }
 
 // browserScreenshot captures a screenshot.
-func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserScreenshot(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
 		return nil, err
 	}
 
-	base64Data, err := h.client.CaptureScreenshot("")
+	base64Data, err := sess.client.CaptureScreenshot("")
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture screenshot: %w", err)
 	}
+	output := map[string]interface{}{
+		"mimeType": "image/png",
+		"size":     base64.StdEncoding.DecodedLen(len(base64Data)),
+	}
 
 	// If filename provided, save to file (only if screenshotDir is configured)
 	if filename, ok := args["filename"].(string); ok && filename != "" {
@@ -223,11 +444,17 @@ func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallRes
 		if err := os.WriteFile(fullPath, pngData, 0644); err != nil {
 			return nil, fmt.Errorf("failed to save screenshot: %w", err)
 		}
+		h.resourceListChanged()
+		h.resourceUpdated(ScreenshotURI(safeName))
+
+		output["size"] = len(pngData)
+		output["path"] = fullPath
 		return &ToolsCallResult{
 			Content: []Content{{
 				Type: "text",
 				Text: fmt.Sprintf("Screenshot saved to %s", fullPath),
 			}},
+			StructuredContent: output,
 		}, nil
 	}
 
@@ -237,21 +464,28 @@ func (h *Handlers) browserScreenshot(args map[string]interface{}) (*ToolsCallRes
 			Data:     base64Data,
 			MimeType: "image/png",
 		}},
+		StructuredContent: output,
 	}, nil
 }
 
 // browserFind finds an element and returns its info.
-func (h *Handlers) browserFind(args map[string]interface{}) (*ToolsCallResult, error) {
-	if err := h.ensureBrowser(); err != nil {
+func (h *Handlers) browserFind(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
 		return nil, err
 	}
 
-	selector, ok := args["selector"].(string)
-	if !ok || selector == "" {
-		return nil, fmt.Errorf("selector is required")
+	selector, _, err := sess.targetSelector(args)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := sess.client.FindElement("", selector)
+	if err != nil {
+		return nil, err
 	}
 
-	info, err := h.client.FindElement("", selector)
+	element, err := sess.elementOutput(selector, args)
 	if err != nil {
 		return nil, err
 	}
@@ -262,34 +496,433 @@ func (h *Handlers) browserFind(args map[string]interface{}) (*ToolsCallResult, e
 			Text: fmt.Sprintf("tag=%s, text=\"%s\", box={x:%.0f, y:%.0f, w:%.0f, h:%.0f}",
 				info.Tag, info.Text, info.Box.X, info.Box.Y, info.Box.Width, info.Box.Height),
 		}},
+		StructuredContent: element,
+	}, nil
+}
+
+// browserSnapshot returns an accessibility snapshot of the page.
+func (h *Handlers) browserSnapshot(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := features.TakeSnapshot(sess.client, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: snapshot.String(),
+		}},
+		StructuredContent: map[string]interface{}{
+			"url":   snapshot.URL,
+			"title": snapshot.Title,
+			"nodes": snapshot.Nodes,
+		},
+	}, nil
+}
+
+// browserGetContent returns the page content as Markdown, text or HTML.
+func (h *Handlers) browserGetContent(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	formatName, _ := args["format"].(string)
+	format, err := features.ParseContentFormat(formatName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := features.ContentOptions{
+		Format:    format,
+		MaxLength: defaultContentMaxLength,
+	}
+	opts.Selector, _ = args["selector"].(string)
+	if val, ok := args["maxLength"].(float64); ok {
+		opts.MaxLength = int(val)
+	}
+
+	content, err := features.ExtractContent(sess.client, "", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	text := content.Content
+	if content.Truncated {
+		text += fmt.Sprintf("\n\n... (truncated at %d characters, use selector or a larger maxLength to read more)", opts.MaxLength)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: map[string]interface{}{
+			"url":       content.URL,
+			"title":     content.Title,
+			"format":    content.Format,
+			"content":   content.Content,
+			"truncated": content.Truncated,
+		},
+	}, nil
+}
+
+// targetSelector returns the CSS selector for the element given by the
+// "ref" (from browser_snapshot) or "selector" argument, and a description
+// of the target for messages.
+func (s *browserSession) targetSelector(args map[string]interface{}) (string, string, error) {
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		if !features.IsRef(ref) {
+			return "", "", invalidArguments("invalid ref %q (expected a ref like e12 from browser_snapshot)", ref)
+		}
+		selector := features.RefSelector(ref)
+		if _, err := s.client.FindElement("", selector); err != nil {
+			return "", "", fmt.Errorf("ref %s not found, the page may have changed. Call browser_snapshot again", ref)
+		}
+		return selector, "ref " + ref, nil
+	}
+
+	selector, ok := args["selector"].(string)
+	if !ok || selector == "" {
+		return "", "", invalidArguments("selector or ref is required")
+	}
+	return selector, selector, nil
+}
+
+// browserCookiesGet returns the cookies matching the optional name/domain filter.
+func (h *Handlers) browserCookiesGet(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cookies, err := sess.client.GetCookies(cookieFilterFromArgs(args))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cookies: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cookies: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: string(data),
+		}},
+		StructuredContent: map[string]interface{}{
+			"cookies": cookies,
+		},
+	}, nil
+}
+
+// browserCookiesSet sets a cookie. The domain defaults to the current page's host.
+func (h *Handlers) browserCookiesSet(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	var cookie bidi.Cookie
+	data, _ := json.Marshal(args)
+	if err := json.Unmarshal(data, &cookie); err != nil {
+		return nil, invalidArguments("invalid cookie: %v", err)
+	}
+	if cookie.Name == "" {
+		return nil, invalidArguments("name is required")
+	}
+	if _, ok := args["value"].(string); !ok {
+		return nil, invalidArguments("value is required")
+	}
+
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if cookie.Domain == "" {
+		currentURL, err := sess.client.GetCurrentURL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current URL: %w", err)
+		}
+		u, err := url.Parse(currentURL)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("domain is required when the current page has no host")
+		}
+		cookie.Domain = u.Hostname()
+	}
+
+	if err := sess.client.SetCookie(cookie); err != nil {
+		return nil, fmt.Errorf("failed to set cookie: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Cookie set: %s (domain: %s)", cookie.Name, cookie.Domain),
+		}},
+		StructuredContent: map[string]interface{}{
+			"name":   cookie.Name,
+			"domain": cookie.Domain,
+		},
+	}, nil
+}
+
+// browserCookiesClear deletes cookies matching the optional name/domain filter.
+func (h *Handlers) browserCookiesClear(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := cookieFilterFromArgs(args)
+	if err := sess.client.DeleteCookies(filter); err != nil {
+		return nil, fmt.Errorf("failed to clear cookies: %w", err)
+	}
+
+	output := map[string]interface{}{}
+	if filter.Name != "" {
+		output["name"] = filter.Name
+	}
+	if filter.Domain != "" {
+		output["domain"] = filter.Domain
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: "Cookies cleared",
+		}},
+		StructuredContent: output,
+	}, nil
+}
+
+// cookieFilterFromArgs builds a cookie filter from tool arguments.
+func cookieFilterFromArgs(args map[string]interface{}) bidi.CookieFilter {
+	name, _ := args["name"].(string)
+	domain, _ := args["domain"].(string)
+	return bidi.CookieFilter{Name: name, Domain: domain}
+}
+
+// statePath returns the path of the storage state file named by the path
+// argument, which must be a file name in the state directory.
+func (h *Handlers) statePath(args map[string]interface{}) (string, error) {
+	if h.stateDir == "" {
+		return "", fmt.Errorf("storage state files are disabled (use --state-dir to enable)")
+	}
+
+	name, _ := args["path"].(string)
+	if name == "" {
+		return "", invalidArguments("path is required")
+	}
+	if filepath.Base(name) != name || name == "." || name == ".." {
+		return "", invalidArguments("path must be a file name in the state directory, not %q", name)
+	}
+	return filepath.Join(h.stateDir, name), nil
+}
+
+// browserSaveState saves cookies, localStorage and sessionStorage to a file.
+func (h *Handlers) browserSaveState(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	path, err := h.statePath(args)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := features.SaveStorageState(sess.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save storage state: %w", err)
+	}
+	if err := os.MkdirAll(h.stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := state.WriteFile(path); err != nil {
+		return nil, err
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Storage state saved to %s (%d cookies, %d origins)", path, len(state.Cookies), len(state.Origins)),
+		}},
+		StructuredContent: map[string]interface{}{
+			"path":    path,
+			"cookies": len(state.Cookies),
+			"origins": len(state.Origins),
+		},
 	}, nil
 }
 
-// browserQuit closes the browser session.
-func (h *Handlers) browserQuit(args map[string]interface{}) (*ToolsCallResult, error) {
-	if h.launchResult == nil {
+// browserLoadState restores cookies, localStorage and sessionStorage from a file.
+func (h *Handlers) browserLoadState(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	path, err := h.statePath(args)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := features.ReadStorageState(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := features.ApplyStorageState(sess.client, state); err != nil {
+		return nil, fmt.Errorf("failed to load storage state: %w", err)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: fmt.Sprintf("Storage state loaded from %s (%d cookies, %d origins)", path, len(state.Cookies), len(state.Origins)),
+		}},
+		StructuredContent: map[string]interface{}{
+			"path":    path,
+			"cookies": len(state.Cookies),
+			"origins": len(state.Origins),
+		},
+	}, nil
+}
+
+// browserEvaluate runs a JavaScript expression or function in the page and
+// returns the result as JSON.
+func (h *Handlers) browserEvaluate(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	expression, _ := args["expression"].(string)
+	function, _ := args["function"].(string)
+	if (expression == "") == (function == "") {
+		return nil, invalidArguments("exactly one of expression or function is required")
+	}
+
+	sess, err := h.session(args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := bidi.ScriptOptions{AwaitPromise: true}
+	if val, ok := args["awaitPromise"].(bool); ok {
+		opts.AwaitPromise = val
+	}
+	context, _ := args["context"].(string)
+
+	var result interface{}
+	if expression != "" {
+		result, err = sess.client.EvaluateWithOptions(context, expression, opts)
+	} else {
+		fnArgs, _ := args["args"].([]interface{})
+		result, err = sess.client.CallFunctionWithOptions(context, function, fnArgs, opts)
+	}
+	if err != nil {
+		var scriptErr *errs.ScriptExceptionError
+		if errors.As(err, &scriptErr) {
+			// Already includes the JavaScript message and stack
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to evaluate: %w", err)
+	}
+
+	data, err := json.MarshalIndent(bidi.JSONValue(result), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	text := string(data)
+	output := map[string]interface{}{
+		"truncated": false,
+		"size":      len(data),
+	}
+	if len(data) > maxEvaluateResultSize {
+		// Cut the value rather than its JSON, so the result stays valid
+		truncated, _ := truncateValue(bidi.JSONValue(result), maxEvaluateResultSize)
+		shown, err := json.MarshalIndent(truncated, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+		text = fmt.Sprintf("%s\n... (truncated: the result is %d bytes, the limit is %d)", shown, len(data), maxEvaluateResultSize)
+		output["truncated"] = true
+		output["result"] = json.RawMessage(shown)
+	} else {
+		output["result"] = json.RawMessage(data)
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: output,
+	}, nil
+}
+
+// browserQuit closes a browser session.
+func (h *Handlers) browserQuit(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	sess, err := h.session(args)
+	if err != nil {
 		return &ToolsCallResult{
 			Content: []Content{{
 				Type: "text",
 				Text: "No browser session to close",
 			}},
+			StructuredContent: map[string]interface{}{
+				"closed": false,
+			},
 		}, nil
 	}
 
-	h.Close()
+	h.closeSession(sess.id)
 
 	return &ToolsCallResult{
 		Content: []Content{{
 			Type: "text",
-			Text: "Browser session closed",
+			Text: fmt.Sprintf("Browser session closed: %s", sess.id),
 		}},
+		StructuredContent: map[string]interface{}{
+			"session": sess.id,
+			"closed":  true,
+		},
 	}, nil
 }
 
-// ensureBrowser checks that a browser session is active.
-func (h *Handlers) ensureBrowser() error {
-	if h.client == nil {
-		return fmt.Errorf("no browser session. Call browser_launch first")
+// elementOutput returns the structured output describing the element
+// matched by selector: its tag, text, bounding box and how many elements
+// the selector matches. args supplies the snapshot ref, if any.
+func (s *browserSession) elementOutput(selector string, args map[string]interface{}) (map[string]interface{}, error) {
+	info, err := s.client.FindElement("", selector)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.client.CallFunction("", bidi.WithElementQuery("(selector) => queryElements(selector).length"), []interface{}{selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count matches: %w", err)
+	}
+	matchCount, _ := count.(float64)
+
+	output := map[string]interface{}{
+		"selector":   selector,
+		"tag":        info.Tag,
+		"text":       info.Text,
+		"box":        info.Box,
+		"matchCount": int(matchCount),
+	}
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		output["ref"] = ref
+	}
+	return output, nil
+}
+
+// pageTitle returns the title of the current page.
+func (s *browserSession) pageTitle() (string, error) {
+	title, err := s.client.Evaluate("", "document.title")
+	if err != nil {
+		return "", fmt.Errorf("failed to get title: %w", err)
 	}
-	return nil
+	str, _ := title.(string)
+	return str, nil
 }
@@ -0,0 +1,550 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/auth"
+	"github.com/vibium/clicker/internal/log"
+)
+
+// HTTPPath is the endpoint the Streamable HTTP transport is served on.
+const HTTPPath = "/mcp"
+
+// SessionIDHeader carries the MCP session ID on every request after initialize.
+const SessionIDHeader = "Mcp-Session-Id"
+
+// DefaultIdleTimeout is how long an HTTP session may be idle before it is
+// closed along with its browser.
+const DefaultIdleTimeout = 30 * time.Minute
+
+// maxRequestBodySize limits the size of a POSTed JSON-RPC message (10MB).
+const maxRequestBodySize = 10 * 1024 * 1024
+
+// HTTPOptions configures the Streamable HTTP transport.
+type HTTPOptions struct {
+	ServerOptions
+	IdleTimeout    time.Duration // Close sessions idle longer than this (0 = DefaultIdleTimeout)
+	Token          string        // Token clients must send as "Authorization: Bearer <token>" ("" = none)
+	AllowedOrigins []string      // Origins of web pages allowed to connect, "*" for any
+}
+
+// HTTPServer serves MCP over the Streamable HTTP transport: clients POST
+// JSON-RPC messages to HTTPPath and get the response as JSON or as an SSE
+// stream that also carries progress notifications. A GET request opens an
+// SSE stream for notifications not tied to a request, such as resource
+// updates. Each MCP session has its own Handlers and browser.
+type HTTPServer struct {
+	version    string
+	opts       HTTPOptions
+	httpServer *http.Server
+	listener   net.Listener
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+	done     chan struct{}
+}
+
+// httpSession is one MCP session created by an initialize request.
+// Its Server serializes tool calls, so requests may arrive concurrently.
+type httpSession struct {
+	id         string
+	server     *Server
+	lastActive time.Time // Guarded by HTTPServer.mu
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewHTTPServer creates a new Streamable HTTP MCP server.
+func NewHTTPServer(version string, opts HTTPOptions) *HTTPServer {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	return &HTTPServer{
+		version:  version,
+		opts:     opts,
+		sessions: make(map[string]*httpSession),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start listens on addr (e.g. "127.0.0.1:8931") and serves requests in the
+// background. Without a host (":8931"), it listens on 127.0.0.1 only.
+func (s *HTTPServer) Start(addr string) error {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(HTTPPath, s)
+
+	s.listener = listener
+	s.httpServer = &http.Server{Handler: mux}
+
+	go s.httpServer.Serve(listener)
+	go s.reapIdleSessions()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *HTTPServer) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop shuts down the HTTP server and closes all sessions and their browsers.
+func (s *HTTPServer) Stop(ctx context.Context) error {
+	close(s.done)
+
+	var err error
+	if s.httpServer != nil {
+		err = s.httpServer.Shutdown(ctx)
+	}
+
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*httpSession)
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+
+	return err
+}
+
+// ServeHTTP handles requests to the MCP endpoint. Requests from web pages
+// of other origins are rejected, so pages can't drive the browser through
+// localhost (e.g. with DNS rebinding), and so are requests without the token.
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !auth.OriginAllowed(r, s.opts.AllowedOrigins) {
+		log.Debug("mcp http request from disallowed origin", "origin", r.Header.Get("Origin"))
+		writeHTTPError(w, http.StatusForbidden, "Forbidden: origin not allowed")
+		return
+	}
+	if !auth.TokenMatches(auth.BearerToken(r), s.opts.Token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeHTTPError(w, http.StatusUnauthorized, "Unauthorized: missing or invalid token")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handlePost handles a JSON-RPC message or batch sent by the client.
+func (s *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeHTTPError(w, http.StatusUnsupportedMediaType, "Unsupported Media Type: Content-Type must be application/json")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if len(body) > maxRequestBodySize {
+		writeHTTPError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &Response{
+			JSONRPC: "2.0",
+			Error:   &Error{Code: ParseError, Message: "Parse error", Data: err.Error()},
+		})
+		return
+	}
+
+	var initialize, hasRequests bool
+	for _, msg := range messages {
+		var peek struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+		}
+		json.Unmarshal(msg, &peek)
+		if peek.Method == "initialize" {
+			initialize = true
+		}
+		if peek.ID != nil && peek.Method != "" {
+			hasRequests = true
+		}
+	}
+
+	var session *httpSession
+	if initialize {
+		if len(messages) > 1 {
+			writeHTTPError(w, http.StatusBadRequest, "Bad Request: initialize must not be batched")
+			return
+		}
+		session, err = s.newSession()
+		if err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set(SessionIDHeader, session.id)
+	} else {
+		session = s.lookupSession(w, r)
+		if session == nil {
+			return
+		}
+	}
+	s.touch(session)
+
+	// With an SSE response, progress notifications are streamed before the
+	// response. JSON responses can only carry the response itself.
+	var stream *eventStream
+	if hasRequests && acceptsEventStream(r) {
+		stream = &eventStream{w: w}
+		defer stream.close()
+	}
+	notify := func(n *Notification) {
+		if stream != nil {
+			stream.send(n)
+		}
+	}
+
+	var responses []*Response
+	for _, msg := range messages {
+		if isResponseMessage(msg) {
+			// Responses to server-initiated requests, which the server never sends
+			continue
+		}
+
+		req, errResp := parseRequest(msg)
+		if errResp != nil {
+			responses = append(responses, errResp)
+			continue
+		}
+
+		ctx, done := session.server.track(r.Context(), req)
+		response := session.server.handleRequest(ctx, req, notify)
+		done()
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+	s.touch(session)
+
+	if initialize {
+		if len(responses) == 1 && responses[0].Error != nil {
+			s.removeSession(session.id)
+		} else {
+			log.Debug("mcp http session created", "session", session.id)
+		}
+	}
+
+	if stream != nil {
+		for _, response := range responses {
+			stream.send(response)
+		}
+		// Late notifications must not start the stream under a JSON response
+		stream.close()
+		if stream.started {
+			return
+		}
+	}
+
+	if !hasRequests || len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var payload interface{} = responses[0]
+	if batch {
+		payload = responses
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+// handleGet opens an SSE stream that carries the session's unsolicited
+// notifications until the client disconnects or the session ends. A new
+// stream replaces the previous one.
+func (s *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		writeHTTPError(w, http.StatusNotAcceptable, "Not Acceptable: client must accept text/event-stream")
+		return
+	}
+
+	session := s.lookupSession(w, r)
+	if session == nil {
+		return
+	}
+
+	stream := &eventStream{w: w}
+	stream.start()
+	unset := session.server.setNotifier(func(n *Notification) {
+		stream.send(n)
+	})
+	defer stream.close()
+	defer unset()
+
+	log.Debug("mcp http notification stream opened", "session", session.id)
+	select {
+	case <-r.Context().Done():
+	case <-session.closed:
+	case <-s.done:
+	}
+	log.Debug("mcp http notification stream closed", "session", session.id)
+}
+
+// handleDelete terminates a session and closes its browser.
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session := s.lookupSession(w, r)
+	if session == nil {
+		return
+	}
+
+	s.removeSession(session.id)
+	log.Debug("mcp http session deleted", "session", session.id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// lookupSession returns the session named by the request's Mcp-Session-Id
+// header, or writes an error response and returns nil.
+func (s *HTTPServer) lookupSession(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		writeHTTPError(w, http.StatusBadRequest, "Bad Request: "+SessionIDHeader+" header is required")
+		return nil
+	}
+
+	s.mu.Lock()
+	session := s.sessions[id]
+	s.mu.Unlock()
+
+	if session == nil {
+		writeHTTPError(w, http.StatusNotFound, "Session not found")
+		return nil
+	}
+	return session
+}
+
+// newSession creates and registers a session with its own Handlers.
+func (s *HTTPServer) newSession() (*httpSession, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	session := &httpSession{
+		id:         hex.EncodeToString(idBytes),
+		server:     newServer(s.version, s.opts.ServerOptions),
+		lastActive: time.Now(),
+		closed:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	return session, nil
+}
+
+// removeSession unregisters a session and closes it.
+func (s *HTTPServer) removeSession(id string) {
+	s.mu.Lock()
+	session := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
+}
+
+// touch marks a session as active.
+func (s *HTTPServer) touch(session *httpSession) {
+	s.mu.Lock()
+	session.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// reapIdleSessions closes sessions that have been idle longer than the
+// idle timeout, until the server is stopped.
+func (s *HTTPServer) reapIdleSessions() {
+	interval := s.opts.IdleTimeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		var idle []string
+		s.mu.Lock()
+		for id, session := range s.sessions {
+			if time.Since(session.lastActive) > s.opts.IdleTimeout {
+				idle = append(idle, id)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, id := range idle {
+			log.Debug("mcp http session idle, closing", "session", id)
+			s.removeSession(id)
+		}
+	}
+}
+
+// close ends the session's notification stream, cancels its in-flight
+// requests and closes its browser.
+func (session *httpSession) close() {
+	session.closeOnce.Do(func() {
+		close(session.closed)
+	})
+	session.server.Close()
+}
+
+// splitBatch returns the messages in a JSON-RPC message or batch.
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var messages []json.RawMessage
+		if err := json.Unmarshal(trimmed, &messages); err != nil {
+			return nil, false, err
+		}
+		if len(messages) == 0 {
+			return nil, false, fmt.Errorf("empty batch")
+		}
+		return messages, true, nil
+	}
+
+	if !json.Valid(trimmed) {
+		return nil, false, fmt.Errorf("invalid JSON")
+	}
+	return []json.RawMessage{trimmed}, false, nil
+}
+
+// isResponseMessage returns true if msg is a JSON-RPC response (no method).
+func isResponseMessage(msg json.RawMessage) bool {
+	var peek struct {
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	json.Unmarshal(msg, &peek)
+	return peek.Method == "" && (peek.Result != nil || peek.Error != nil)
+}
+
+// acceptsEventStream returns true if the client accepts an SSE response.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// writeJSON writes a JSON response body.
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// eventStream writes JSON-RPC messages as SSE message events. The response
+// headers are written with the first message.
+type eventStream struct {
+	w       http.ResponseWriter
+	mu      sync.Mutex
+	started bool
+	closed  bool // The handler returned, so w must not be used
+}
+
+// start writes the response headers if they haven't been written yet.
+func (e *eventStream) start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.writeHeader()
+	e.flush()
+}
+
+// send writes a message event and flushes it to the client.
+func (e *eventStream) send(msg interface{}) {
+	data, _ := json.Marshal(msg)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.writeHeader()
+	fmt.Fprintf(e.w, "event: message\ndata: %s\n\n", data)
+	e.flush()
+}
+
+// close makes later sends no-ops. Call it before the handler returns when
+// other goroutines may still send.
+func (e *eventStream) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+}
+
+// writeHeader writes the SSE response headers once. Callers hold e.mu.
+func (e *eventStream) writeHeader() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.w.Header().Set("Content-Type", "text/event-stream")
+	e.w.Header().Set("Cache-Control", "no-cache")
+	e.w.WriteHeader(http.StatusOK)
+}
+
+// flush sends buffered data to the client.
+func (e *eventStream) flush() {
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeHTTPError writes a JSON-RPC error without an ID for transport-level
+// failures such as a missing or unknown session.
+func writeHTTPError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &Response{
+		JSONRPC: "2.0",
+		Error:   &Error{Code: -32000, Message: message},
+	})
+}
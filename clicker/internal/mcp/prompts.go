@@ -0,0 +1,258 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// PromptTemplate is a prompt served by prompts/list and prompts/get. The
+// template uses text/template syntax with the arguments as fields, e.g.
+// "Open {{.url}}". Arguments that aren't given are empty strings.
+//
+// Prompt files in the prompt directory are JSON encoded PromptTemplates:
+//
+//	{
+//	  "name": "checkout_smoke",
+//	  "description": "Smoke test the checkout flow",
+//	  "arguments": [{"name": "url", "description": "Store URL", "required": true}],
+//	  "template": ["Open {{.url}} and add any product to the cart.", "Then check out."]
+//	}
+//
+// The template is a string or a list of lines. A prompt file overrides the
+// built-in prompt with the same name.
+type PromptTemplate struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+	Template    promptText       `json:"template"`
+
+	tmpl *template.Template
+}
+
+// promptText is a template given as a string or a list of lines.
+type promptText string
+
+func (t *promptText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = promptText(strings.Join(lines, "\n"))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return errors.New("template must be a string or a list of strings")
+	}
+	*t = promptText(text)
+	return nil
+}
+
+// errPromptNotFound is returned by getPrompt for unknown prompt names.
+var errPromptNotFound = errors.New("prompt not found")
+
+// builtinPrompts are the prompts every server serves.
+var builtinPrompts = []*PromptTemplate{
+	{
+		Name:        "explore_site",
+		Description: "Explore a website and summarize what it offers",
+		Arguments: []PromptArgument{
+			{Name: "url", Description: "URL to start from", Required: true},
+			{Name: "goal", Description: "What to look for (optional)"},
+		},
+		Template: `Explore the website at {{.url}} with the browser tools{{if .goal}}, focusing on: {{.goal}}{{end}}.
+
+1. Launch a headless browser with browser_launch and open the site with browser_navigate.
+2. Use browser_snapshot to see the page structure and browser_get_content to read it.
+3. Follow the main navigation to visit the key pages of the site (about 10 at most).
+4. Close the browser with browser_quit.
+
+Then summarize what the site is for, its main sections and features, and anything that looks broken, such as error pages, dead links or console errors (see the vibium://console resource).`,
+	},
+	{
+		Name:        "fill_form",
+		Description: "Fill in and submit a form",
+		Arguments: []PromptArgument{
+			{Name: "url", Description: "URL of the page with the form", Required: true},
+			{Name: "goal", Description: "What to enter and what submitting should achieve", Required: true},
+		},
+		Template: `Fill in and submit the form at {{.url}}. Goal: {{.goal}}
+
+1. Launch a browser with browser_launch and open the page with browser_navigate.
+2. Use browser_snapshot to find the form fields and their refs.
+3. Fill in each field with browser_type, and use browser_click for checkboxes, radio buttons and options. Don't invent data that the goal doesn't provide; ask instead.
+4. Submit the form with browser_click and take a screenshot of the result with browser_screenshot.
+5. Close the browser with browser_quit.
+
+Report what was entered, whether the submission succeeded, and any validation errors the page showed.`,
+	},
+	{
+		Name:        "reproduce_bug",
+		Description: "Reproduce a bug and screenshot each step",
+		Arguments: []PromptArgument{
+			{Name: "url", Description: "URL where the bug occurs", Required: true},
+			{Name: "goal", Description: "The bug report: steps, expected and actual behavior", Required: true},
+		},
+		Template: `Reproduce this bug on {{.url}}:
+
+{{.goal}}
+
+1. Launch a browser with browser_launch and open the page with browser_navigate.
+2. Follow the steps of the bug report one at a time. After each step, take a screenshot with browser_screenshot, named step-01.png, step-02.png and so on.
+3. Check the vibium://console resource for errors after each step.
+4. Close the browser with browser_quit.
+
+Report whether the bug reproduced, listing each step with its screenshot, what happened compared to what was expected, and any console errors.`,
+	},
+}
+
+func init() {
+	for _, p := range builtinPrompts {
+		if err := p.parse(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// LoadPrompts reads the prompt files (*.json) in dir.
+func LoadPrompts(dir string) ([]*PromptTemplate, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var prompts []*PromptTemplate
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		}
+
+		var p PromptTemplate
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("invalid prompt file %s: %w", path, err)
+		}
+		if p.Name == "" {
+			p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if err := p.parse(); err != nil {
+			return nil, fmt.Errorf("invalid prompt file %s: %w", path, err)
+		}
+		prompts = append(prompts, &p)
+	}
+	return prompts, nil
+}
+
+// parse parses the template and checks that it only uses declared
+// arguments.
+func (p *PromptTemplate) parse() error {
+	if p.Template == "" {
+		return fmt.Errorf("prompt %q has no template", p.Name)
+	}
+	for _, arg := range p.Arguments {
+		if arg.Name == "" {
+			return fmt.Errorf("prompt %q has an argument without a name", p.Name)
+		}
+	}
+
+	tmpl, err := template.New(p.Name).Option("missingkey=error").Parse(string(p.Template))
+	if err != nil {
+		return err
+	}
+
+	sample := make(map[string]string, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		sample[arg.Name] = arg.Name
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return err
+	}
+
+	p.tmpl = tmpl
+	return nil
+}
+
+// render fills in the template with the arguments.
+func (p *PromptTemplate) render(args map[string]string) (string, error) {
+	data := make(map[string]string, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		value := args[arg.Name]
+		if value == "" && arg.Required {
+			return "", fmt.Errorf("missing required argument %q", arg.Name)
+		}
+		data[arg.Name] = value
+	}
+
+	var text strings.Builder
+	if err := p.tmpl.Execute(&text, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// prompts returns the built-in prompts and those in the prompt directory,
+// sorted by name. The directory is read on every call, so edits apply
+// without a restart.
+func (s *Server) prompts() ([]*PromptTemplate, error) {
+	byName := make(map[string]*PromptTemplate)
+	for _, p := range builtinPrompts {
+		byName[p.Name] = p
+	}
+
+	if s.promptDir != "" {
+		loaded, err := LoadPrompts(s.promptDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range loaded {
+			byName[p.Name] = p
+		}
+	}
+
+	prompts := make([]*PromptTemplate, 0, len(byName))
+	for _, p := range byName {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	return prompts, nil
+}
+
+// getPrompt renders the prompt with the given name.
+func (s *Server) getPrompt(name string, args map[string]string) (*PromptsGetResult, error) {
+	prompts, err := s.prompts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range prompts {
+		if p.Name != name {
+			continue
+		}
+
+		text, err := p.render(args)
+		if err != nil {
+			return nil, err
+		}
+		return &PromptsGetResult{
+			Description: p.Description,
+			Messages: []PromptMessage{{
+				Role:    "user",
+				Content: Content{Type: "text", Text: text},
+			}},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errPromptNotFound, name)
+}
@@ -0,0 +1,278 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/features"
+)
+
+// Resource URIs served by Handlers.
+const (
+	PageResourceURI     = "vibium://page"
+	SnapshotResourceURI = "vibium://page/snapshot"
+	ConsoleResourceURI  = "vibium://console"
+	ScreenshotURIPrefix = "vibium://screenshots/"
+)
+
+// maxConsoleEntries is the number of console messages kept per browser
+// session. Older messages are dropped.
+const maxConsoleEntries = 1000
+
+// errResourceNotFound is returned by ReadResource for unknown URIs.
+var errResourceNotFound = errors.New("resource not found")
+
+// ScreenshotURI returns the resource URI of a screenshot saved in the
+// screenshot directory.
+func ScreenshotURI(name string) string {
+	return ScreenshotURIPrefix + name
+}
+
+// Resources returns the resources this instance serves: the current page,
+// its snapshot, the console log and any screenshots in the screenshot dir.
+// Page and console resources are those of the current browser session.
+func (h *Handlers) Resources() []Resource {
+	resources := []Resource{
+		{
+			URI:         PageResourceURI,
+			Name:        "Current page",
+			Description: "URL and title of the current page",
+			MimeType:    "application/json",
+		},
+		{
+			URI:         SnapshotResourceURI,
+			Name:        "Page snapshot",
+			Description: "Accessibility snapshot of the current page, as returned by browser_snapshot",
+			MimeType:    "text/plain",
+		},
+		{
+			URI:         ConsoleResourceURI,
+			Name:        "Console log",
+			Description: fmt.Sprintf("Console messages and uncaught errors (last %d)", maxConsoleEntries),
+			MimeType:    "application/json",
+		},
+	}
+
+	for _, name := range h.screenshotNames() {
+		resources = append(resources, Resource{
+			URI:      ScreenshotURI(name),
+			Name:     name,
+			MimeType: "image/png",
+		})
+	}
+
+	return resources
+}
+
+// screenshotNames returns the PNG files in the screenshot dir, sorted.
+func (h *Handlers) screenshotNames() []string {
+	if h.screenshotDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(h.screenshotDir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isScreenshotName(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isScreenshotName returns true if name is a file name served as a
+// screenshot resource.
+func isScreenshotName(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".png")
+}
+
+// ReadResource returns the contents of a resource. Reads of the current
+// page wait for the session's tool calls, see Handlers.Call.
+func (h *Handlers) ReadResource(ctx context.Context, uri string) (*ResourcesReadResult, error) {
+	switch {
+	case uri == PageResourceURI:
+		return h.readPage(uri)
+	case uri == SnapshotResourceURI:
+		return h.readSnapshot(uri)
+	case uri == ConsoleResourceURI:
+		return h.readConsole(uri)
+	case strings.HasPrefix(uri, ScreenshotURIPrefix):
+		return h.readScreenshot(uri)
+	default:
+		return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+	}
+}
+
+// readPage returns the URL and title of the current page.
+func (h *Handlers) readPage(uri string) (*ResourcesReadResult, error) {
+	sess, unlock, err := h.lockSession(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	currentURL, err := sess.client.GetCurrentURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current URL: %w", err)
+	}
+	title, err := sess.pageTitle()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(map[string]string{
+		"url":   currentURL,
+		"title": title,
+	}, "", "  ")
+
+	return &ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "application/json",
+			Text:     string(data),
+		}},
+	}, nil
+}
+
+// readSnapshot returns the accessibility snapshot of the current page.
+func (h *Handlers) readSnapshot(uri string) (*ResourcesReadResult, error) {
+	sess, unlock, err := h.lockSession(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snapshot, err := features.TakeSnapshot(sess.client, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "text/plain",
+			Text:     snapshot.String(),
+		}},
+	}, nil
+}
+
+// readConsole returns the buffered console messages, oldest first.
+func (h *Handlers) readConsole(uri string) (*ResourcesReadResult, error) {
+	entries := []*bidi.LogEntry{}
+	if sess, err := h.session(nil); err == nil {
+		sess.consoleMu.Lock()
+		entries = append(entries, sess.console...)
+		sess.consoleMu.Unlock()
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode console log: %w", err)
+	}
+
+	return &ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "application/json",
+			Text:     string(data),
+		}},
+	}, nil
+}
+
+// readScreenshot returns a screenshot from the screenshot dir as a PNG blob.
+func (h *Handlers) readScreenshot(uri string) (*ResourcesReadResult, error) {
+	name := strings.TrimPrefix(uri, ScreenshotURIPrefix)
+	if h.screenshotDir == "" || filepath.Base(name) != name || !isScreenshotName(name) {
+		return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+	}
+
+	// Only regular files are listed, so don't follow links either
+	path := filepath.Join(h.screenshotDir, name)
+	if info, err := os.Lstat(path); err == nil && !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", errResourceNotFound, uri)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshot: %w", err)
+	}
+
+	return &ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      uri,
+			MimeType: "image/png",
+			Blob:     base64.StdEncoding.EncodeToString(data),
+		}},
+	}, nil
+}
+
+// SetResourceListener sets the functions called when a resource changes
+// (e.g. the page navigated) and when the resource list changes (e.g. a
+// screenshot was saved). They may be called from the browser's event loop.
+func (h *Handlers) SetResourceListener(updated func(uri string), listChanged func()) {
+	h.onResourceUpdated = updated
+	h.onResourceListChanged = listChanged
+}
+
+// resourceUpdated reports a changed resource to the listener.
+func (h *Handlers) resourceUpdated(uri string) {
+	if h.onResourceUpdated != nil {
+		h.onResourceUpdated(uri)
+	}
+}
+
+// resourceListChanged reports a change of the resource list to the listener.
+func (h *Handlers) resourceListChanged() {
+	if h.onResourceListChanged != nil {
+		h.onResourceListChanged()
+	}
+}
+
+// watchPage buffers a session's console messages and reports resource
+// updates when its page loads.
+func (h *Handlers) watchPage(sess *browserSession) error {
+	removeLog, err := sess.client.OnLogEntry(func(entry *bidi.LogEntry) {
+		sess.consoleMu.Lock()
+		sess.console = append(sess.console, entry)
+		if len(sess.console) > maxConsoleEntries {
+			sess.console = sess.console[len(sess.console)-maxConsoleEntries:]
+		}
+		sess.consoleMu.Unlock()
+
+		h.resourceUpdated(ConsoleResourceURI)
+	})
+	if err != nil {
+		return err
+	}
+
+	removeLoad, err := sess.client.OnLoad(func(context, url string) {
+		h.resourceUpdated(PageResourceURI)
+		h.resourceUpdated(SnapshotResourceURI)
+	})
+	if err != nil {
+		removeLog()
+		return err
+	}
+
+	sess.unwatchPage = func() {
+		removeLog()
+		removeLoad()
+	}
+	return nil
+}
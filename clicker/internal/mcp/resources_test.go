@@ -0,0 +1,35 @@
+package mcp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadScreenshot(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"shot.png": "png", "notes.txt": "secret"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "notes.txt"), filepath.Join(dir, "link.png")); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{screenshotDir: dir}
+
+	if names := h.screenshotNames(); len(names) != 1 || names[0] != "shot.png" {
+		t.Errorf("screenshotNames() = %v, want [shot.png]", names)
+	}
+
+	if _, err := h.readScreenshot(ScreenshotURIPrefix + "shot.png"); err != nil {
+		t.Errorf("readScreenshot(shot.png) = %v", err)
+	}
+	for _, name := range []string{"notes.txt", "link.png", "../shot.png", ""} {
+		_, err := h.readScreenshot(ScreenshotURIPrefix + name)
+		if !errors.Is(err, errResourceNotFound) {
+			t.Errorf("readScreenshot(%q) = %v, want not found", name, err)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package mcp
 
 // GetToolSchemas returns the list of available MCP tools with their schemas.
+// Annotations are hints for clients, e.g. to confirm destructive calls.
+// Every tool takes an optional session argument, see addSessionProperty.
 func GetToolSchemas() []Tool {
-	return []Tool{
+	tools := []Tool{
 		{
 			Name:        "browser_launch",
-			Description: "Launch a new browser session",
+			Description: "Launch a new browser session. Launch several named sessions to use isolated browsers in parallel (e.g., one per user role).",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -17,6 +19,11 @@ func GetToolSchemas() []Tool {
 				},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"session":  map[string]interface{}{"type": "string"},
+				"headless": map[string]interface{}{"type": "boolean"},
+			}, "session", "headless"),
+			Annotations: &ToolAnnotations{},
 		},
 		{
 			Name:        "browser_navigate",
@@ -32,10 +39,15 @@ func GetToolSchemas() []Tool {
 				"required":             []string{"url"},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"url":   map[string]interface{}{"type": "string", "description": "URL after navigation (and redirects)"},
+				"title": map[string]interface{}{"type": "string"},
+			}, "url", "title"),
+			Annotations: &ToolAnnotations{OpenWorldHint: true},
 		},
 		{
 			Name:        "browser_click",
-			Description: "Click an element by CSS selector. Waits for element to be visible, stable, and enabled.",
+			Description: "Click an element by CSS selector or snapshot ref. Waits for element to be visible, stable, and enabled.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -43,14 +55,19 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to click",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (e.g., e12), instead of selector",
+					},
 				},
-				"required":             []string{"selector"},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(elementProperties(), "selector", "tag", "box", "matchCount"),
+			Annotations:  &ToolAnnotations{DestructiveHint: true, OpenWorldHint: true},
 		},
 		{
 			Name:        "browser_type",
-			Description: "Type text into an element by CSS selector. Waits for element to be visible, stable, enabled, and editable.",
+			Description: "Type text into an element by CSS selector or snapshot ref. Waits for element to be visible, stable, enabled, and editable.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -58,14 +75,20 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to type into",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (e.g., e12), instead of selector",
+					},
 					"text": map[string]interface{}{
 						"type":        "string",
 						"description": "The text to type",
 					},
 				},
-				"required":             []string{"selector", "text"},
+				"required":             []string{"text"},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(elementProperties(), "selector", "tag", "box", "matchCount"),
+			Annotations:  &ToolAnnotations{OpenWorldHint: true},
 		},
 		{
 			Name:        "browser_screenshot",
@@ -80,10 +103,16 @@ func GetToolSchemas() []Tool {
 				},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"mimeType": map[string]interface{}{"type": "string"},
+				"size":     map[string]interface{}{"type": "integer", "description": "Size of the PNG in bytes"},
+				"path":     map[string]interface{}{"type": "string", "description": "Where the screenshot was saved, if a filename was given"},
+			}, "mimeType", "size"),
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
 		},
 		{
 			Name:        "browser_find",
-			Description: "Find an element by CSS selector and return its info (tag, text, bounding box)",
+			Description: "Find an element by CSS selector or snapshot ref and return its info (tag, text, bounding box)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -91,19 +120,340 @@ func GetToolSchemas() []Tool {
 						"type":        "string",
 						"description": "CSS selector for the element to find",
 					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Element ref from browser_snapshot (e.g., e12), instead of selector",
+					},
+				},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(elementProperties(), "selector", "tag", "box", "matchCount"),
+			Annotations:  &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_snapshot",
+			Description: "Get an accessibility snapshot of the page: roles, names, values and states of elements, each with a ref usable by browser_click and browser_type. Cheaper than a screenshot.",
+			InputSchema: map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"url":   map[string]interface{}{"type": "string"},
+				"title": map[string]interface{}{"type": "string"},
+				"nodes": map[string]interface{}{
+					"type":        "array",
+					"description": "Tree of nodes with ref, role, name, value, level, states and children",
+					"items":       map[string]interface{}{"type": "object"},
+				},
+			}, "url", "title", "nodes"),
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_get_content",
+			Description: "Read the page (its main content by default) as Markdown with headings, links, lists and tables, or as plain text or HTML",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"markdown", "text", "html"},
+						"description": "Output format",
+						"default":     "markdown",
+					},
+					"selector": map[string]interface{}{
+						"type":        "string",
+						"description": "CSS selector of the element to extract (default: main content)",
+					},
+					"maxLength": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum length in characters (0 for no limit)",
+						"default":     20000,
+					},
 				},
-				"required":             []string{"selector"},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"url":       map[string]interface{}{"type": "string"},
+				"title":     map[string]interface{}{"type": "string"},
+				"format":    map[string]interface{}{"type": "string", "enum": []string{"markdown", "text", "html"}},
+				"content":   map[string]interface{}{"type": "string"},
+				"truncated": map[string]interface{}{"type": "boolean"},
+			}, "url", "title", "format", "content", "truncated"),
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_cookies_get",
+			Description: "Get browser cookies, optionally filtered by name or domain",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return cookies with this name",
+					},
+					"domain": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return cookies for this domain",
+					},
+				},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"cookies": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "object"},
+				},
+			}, "cookies"),
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_cookies_set",
+			Description: "Set a browser cookie (e.g., to inject an auth session)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Cookie name",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "Cookie value",
+					},
+					"domain": map[string]interface{}{
+						"type":        "string",
+						"description": "Cookie domain (default: current page's host)",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Cookie path (default: /)",
+					},
+					"httpOnly": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Hide the cookie from page JavaScript",
+					},
+					"secure": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only send the cookie over HTTPS",
+					},
+					"sameSite": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"strict", "lax", "none"},
+						"description": "SameSite policy",
+					},
+					"expiry": map[string]interface{}{
+						"type":        "integer",
+						"description": "Expiry as Unix seconds (omit for a session cookie)",
+					},
+				},
+				"required":             []string{"name", "value"},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"name":   map[string]interface{}{"type": "string"},
+				"domain": map[string]interface{}{"type": "string"},
+			}, "name", "domain"),
+			Annotations: &ToolAnnotations{IdempotentHint: true},
+		},
+		{
+			Name:        "browser_cookies_clear",
+			Description: "Delete browser cookies, optionally filtered by name or domain (all cookies if no filter)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Only delete cookies with this name",
+					},
+					"domain": map[string]interface{}{
+						"type":        "string",
+						"description": "Only delete cookies for this domain",
+					},
+				},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"name":   map[string]interface{}{"type": "string"},
+				"domain": map[string]interface{}{"type": "string"},
+			}),
+			Annotations: &ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_save_state",
+			Description: "Save cookies, localStorage and sessionStorage to a JSON file in the server's state directory so a logged-in session can be reused",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File name in the state directory to write the storage state to (e.g., auth.json)",
+					},
+				},
+				"required":             []string{"path"},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(storageStateProperties(), "path", "cookies", "origins"),
+			Annotations:  &ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_load_state",
+			Description: "Restore cookies, localStorage and sessionStorage from a file saved with browser_save_state. Call before navigating.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File name of the saved storage state in the state directory",
+					},
+				},
+				"required":             []string{"path"},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(storageStateProperties(), "path", "cookies", "origins"),
+			Annotations:  &ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
+		},
+		{
+			Name:        "browser_evaluate",
+			Description: "Run JavaScript in the page and return the result as JSON. Pass either an expression or a function declaration with args. Large results are truncated.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"expression": map[string]interface{}{
+						"type":        "string",
+						"description": "JavaScript expression to evaluate (e.g., document.title)",
+					},
+					"function": map[string]interface{}{
+						"type":        "string",
+						"description": "JavaScript function declaration to call (e.g., (a, b) => a + b)",
+					},
+					"args": map[string]interface{}{
+						"type":        "array",
+						"description": "JSON arguments passed to function",
+					},
+					"awaitPromise": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Wait for a returned promise and use its value",
+						"default":     true,
+					},
+					"context": map[string]interface{}{
+						"type":        "string",
+						"description": "Browsing context ID of the tab or frame (default: first tab)",
+					},
+				},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"result":    map[string]interface{}{"description": "The JSON result. If truncated, long strings, arrays and objects are shortened"},
+				"truncated": map[string]interface{}{"type": "boolean"},
+				"size":      map[string]interface{}{"type": "integer", "description": "Size of the JSON result in bytes"},
+			}, "truncated", "size"),
+			Annotations: &ToolAnnotations{DestructiveHint: true, OpenWorldHint: true},
+		},
+		{
+			Name:        "browser_sessions_list",
+			Description: "List the open browser sessions with their current URLs",
+			InputSchema: map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{},
+				"additionalProperties": false,
+			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"sessions": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"session":  map[string]interface{}{"type": "string"},
+							"url":      map[string]interface{}{"type": "string"},
+							"headless": map[string]interface{}{"type": "boolean"},
+							"current":  map[string]interface{}{"type": "boolean", "description": "Used by tools that name no session"},
+						},
+					},
+				},
+			}, "sessions"),
+			Annotations: &ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
 		},
 		{
 			Name:        "browser_quit",
-			Description: "Close the browser session",
+			Description: "Close a browser session",
 			InputSchema: map[string]interface{}{
 				"type":                 "object",
 				"properties":           map[string]interface{}{},
 				"additionalProperties": false,
 			},
+			OutputSchema: outputSchema(map[string]interface{}{
+				"session": map[string]interface{}{"type": "string"},
+				"closed":  map[string]interface{}{"type": "boolean", "description": "False if there was no session"},
+			}, "closed"),
+			Annotations: &ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
 		},
 	}
+
+	for _, tool := range tools {
+		addSessionProperty(tool)
+	}
+	return tools
+}
+
+// addSessionProperty adds the optional session argument to a tool's input
+// schema. browser_sessions_list lists all sessions and takes none.
+func addSessionProperty(tool Tool) {
+	description := "Browser session to use (default: the most recently launched)"
+	switch tool.Name {
+	case "browser_sessions_list":
+		return
+	case "browser_launch":
+		description = "Name of the session to launch (default: \"default\"). Launching an open session replaces it. Sessions are isolated browsers with separate profiles."
+	}
+
+	properties := tool.InputSchema["properties"].(map[string]interface{})
+	properties["session"] = map[string]interface{}{
+		"type":        "string",
+		"description": description,
+	}
+}
+
+// outputSchema returns the schema of a tool's structuredContent: an object
+// with the given properties plus durationMs, which every tool reports.
+func outputSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	properties["durationMs"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Time the tool took in milliseconds",
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   append(required, "durationMs"),
+	}
+}
+
+// elementProperties describes the element a tool found or acted on.
+func elementProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"selector": map[string]interface{}{"type": "string", "description": "CSS selector used to find the element, or ref=<ref> for a snapshot ref"},
+		"ref":      map[string]interface{}{"type": "string", "description": "Snapshot ref, if one was given"},
+		"tag":      map[string]interface{}{"type": "string"},
+		"text":     map[string]interface{}{"type": "string", "description": "Text content (first 100 characters)"},
+		"box": map[string]interface{}{
+			"type":        "object",
+			"description": "Bounding box in CSS pixels",
+			"properties": map[string]interface{}{
+				"x":      map[string]interface{}{"type": "number"},
+				"y":      map[string]interface{}{"type": "number"},
+				"width":  map[string]interface{}{"type": "number"},
+				"height": map[string]interface{}{"type": "number"},
+			},
+		},
+		"matchCount": map[string]interface{}{"type": "integer", "description": "Number of elements matching the selector"},
+	}
+}
+
+// storageStateProperties describes a saved or loaded storage state.
+func storageStateProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"path":    map[string]interface{}{"type": "string"},
+		"cookies": map[string]interface{}{"type": "integer", "description": "Number of cookies"},
+		"origins": map[string]interface{}{"type": "integer", "description": "Number of origins with storage"},
+	}
 }
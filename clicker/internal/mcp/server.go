@@ -1,15 +1,21 @@
 // Package mcp implements the Model Context Protocol (MCP) server.
-// It provides a JSON-RPC 2.0 interface over stdio for LLM agents.
+// It provides a JSON-RPC 2.0 interface over stdio or HTTP for LLM agents.
 package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
+	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
 	"github.com/vibium/clicker/internal/log"
+	"github.com/vibium/clicker/internal/policy"
 )
 
 // JSON-RPC 2.0 request structure
@@ -42,6 +48,9 @@ const (
 	MethodNotFound = -32601
 	InvalidParams  = -32602
 	InternalError  = -32603
+
+	// MCP error codes
+	ResourceNotFound = -32002
 )
 
 // MCP-specific types
@@ -75,13 +84,24 @@ type InitializeResult struct {
 }
 
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
 }
 
 type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -92,19 +112,58 @@ type ToolsListResult struct {
 }
 
 type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"` // Schema of structuredContent
+	Annotations  *ToolAnnotations       `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations describe a tool's behavior to clients. They are hints,
+// not guarantees. All fields are sent since the MCP defaults for omitted
+// destructiveHint and openWorldHint are true.
+type ToolAnnotations struct {
+	ReadOnlyHint    bool `json:"readOnlyHint"`    // Doesn't change the page or browser state
+	DestructiveHint bool `json:"destructiveHint"` // May discard data or state (if not read-only)
+	IdempotentHint  bool `json:"idempotentHint"`  // Repeating the call has no further effect
+	OpenWorldHint   bool `json:"openWorldHint"`   // Reaches out to arbitrary websites
 }
 
 type ToolsCallParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the _meta field of a request.
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"` // String or number
+}
+
+// Notification is a JSON-RPC notification (a message without an ID).
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// ProgressParams are the params of notifications/progress.
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// CancelledParams are the params of notifications/cancelled.
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
 }
 
 type ToolsCallResult struct {
-	Content []Content `json:"content"`
-	IsError bool      `json:"isError,omitempty"`
+	Content           []Content              `json:"content"`
+	StructuredContent map[string]interface{} `json:"structuredContent,omitempty"` // Matches the tool's outputSchema
+	IsError           bool                   `json:"isError,omitempty"`
 }
 
 type Content struct {
@@ -114,31 +173,132 @@ type Content struct {
 	MimeType string `json:"mimeType,omitempty"` // For images
 }
 
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceParams are the params of resources/read, resources/subscribe,
+// resources/unsubscribe and notifications/resources/updated.
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ResourcesReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // For binary resources (base64)
+}
+
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptsGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type PromptsGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"` // "user" or "assistant"
+	Content Content `json:"content"`
+}
+
 // Server is the MCP server that handles JSON-RPC over stdio.
+// Requests are processed concurrently, so ping and cancellation are answered
+// while a tool runs. Tool calls and resource reads run one at a time since
+// they share a browser.
 type Server struct {
 	reader   *bufio.Reader
 	writer   io.Writer
 	handlers *Handlers
 	version  string
+
+	promptDir string // User-defined prompt templates, see PromptTemplate
+
+	writeMu sync.Mutex
+
+	inflightMu sync.Mutex
+	inflight   map[string]context.CancelFunc // Cancellable requests by ID
+	wg         sync.WaitGroup
+
+	// Unsolicited notifications such as resource updates go to notifier
+	notifyMu      sync.Mutex
+	notifier      func(*Notification)
+	notifierID    int
+	subscriptions map[string]bool // Subscribed resource URIs
 }
 
 // ServerOptions configures the MCP server.
 type ServerOptions struct {
-	ScreenshotDir string // Directory for saving screenshots (empty = disabled)
+	ScreenshotDir   string                 // Directory for saving screenshots (empty = disabled)
+	StateDir        string                 // Directory for storage state files (empty = disabled)
+	DisableEvaluate bool                   // Hide browser_evaluate and reject calls to it
+	Policy          *policy.Policy         // Restricts navigation (nil = allow all)
+	PromptDir       string                 // Directory of prompt templates (empty = built-in prompts only)
+	Emulation       *bidi.EmulationOptions // Emulation applied to every launched browser (nil = none)
 }
 
 // NewServer creates a new MCP server.
 func NewServer(version string, opts ServerOptions) *Server {
-	return &Server{
-		reader:   bufio.NewReader(os.Stdin),
-		writer:   os.Stdout,
-		handlers: NewHandlers(opts.ScreenshotDir),
-		version:  version,
+	s := newServer(version, opts)
+	s.reader = bufio.NewReader(os.Stdin)
+	s.writer = os.Stdout
+	return s
+}
+
+// newServer creates a server without a transport.
+func newServer(version string, opts ServerOptions) *Server {
+	s := &Server{
+		handlers:      NewHandlers(opts),
+		version:       version,
+		promptDir:     opts.PromptDir,
+		inflight:      make(map[string]context.CancelFunc),
+		subscriptions: make(map[string]bool),
 	}
+	s.handlers.SetResourceListener(s.resourceUpdated, s.resourceListChanged)
+	return s
 }
 
 // Run starts the server loop, reading requests from stdin and writing responses to stdout.
 func (s *Server) Run() error {
+	// Let in-flight tool calls write their responses before returning
+	defer s.wg.Wait()
+
+	s.setNotifier(func(n *Notification) {
+		if err := s.write(n); err != nil {
+			log.Debug("mcp notification write failed", "error", err)
+		}
+	})
+
 	for {
 		line, err := s.reader.ReadBytes('\n')
 		if err != nil {
@@ -153,20 +313,53 @@ func (s *Server) Run() error {
 			continue
 		}
 
-		response := s.handleRequest(line)
+		req, errResp := parseRequest(line)
+		if errResp != nil {
+			if err := s.write(errResp); err != nil {
+				return fmt.Errorf("write error: %w", err)
+			}
+			continue
+		}
+
+		notify := func(n *Notification) {
+			if err := s.write(n); err != nil {
+				log.Debug("mcp notification write failed", "error", err)
+			}
+		}
+
+		// Tool calls and resource reads use the browser and run in the
+		// background; everything else is answered inline
+		ctx, done := s.track(context.Background(), req)
+		if req.Method == "tools/call" || req.Method == "resources/read" {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer done()
+				if response := s.handleRequest(ctx, req, notify); response != nil {
+					if err := s.write(response); err != nil {
+						log.Debug("mcp response write failed", "error", err)
+					}
+				}
+			}()
+			continue
+		}
+
+		response := s.handleRequest(ctx, req, notify)
+		done()
 		if response != nil {
-			if err := s.writeResponse(response); err != nil {
+			if err := s.write(response); err != nil {
 				return fmt.Errorf("write error: %w", err)
 			}
 		}
 	}
 }
 
-// handleRequest parses and routes a JSON-RPC request.
-func (s *Server) handleRequest(data []byte) *Response {
+// parseRequest parses and validates a JSON-RPC request. If the request is
+// invalid, it returns the error response to send instead.
+func parseRequest(data []byte) (*Request, *Response) {
 	var req Request
 	if err := json.Unmarshal(data, &req); err != nil {
-		return &Response{
+		return nil, &Response{
 			JSONRPC: "2.0",
 			Error: &Error{
 				Code:    ParseError,
@@ -178,7 +371,7 @@ func (s *Server) handleRequest(data []byte) *Response {
 
 	// Validate JSON-RPC version
 	if req.JSONRPC != "2.0" {
-		return &Response{
+		return nil, &Response{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &Error{
@@ -189,14 +382,53 @@ func (s *Server) handleRequest(data []byte) *Response {
 		}
 	}
 
+	return &req, nil
+}
+
+// track registers a request so notifications/cancelled can cancel it.
+// Call done when the request has finished.
+func (s *Server) track(parent context.Context, req *Request) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(parent)
+	if req.ID == nil {
+		return ctx, cancel
+	}
+
+	key := requestKey(req.ID)
+	s.inflightMu.Lock()
+	s.inflight[key] = cancel
+	s.inflightMu.Unlock()
+
+	return ctx, func() {
+		s.inflightMu.Lock()
+		delete(s.inflight, key)
+		s.inflightMu.Unlock()
+		cancel()
+	}
+}
+
+// requestKey returns a map key for a request ID (string or number).
+func requestKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// handleRequest routes a request. notify sends notifications related to the
+// request, such as progress. Returns nil if no response should be sent:
+// for notifications and for requests the client cancelled.
+func (s *Server) handleRequest(ctx context.Context, req *Request, notify func(*Notification)) *Response {
 	// Route to handler
-	result, err := s.route(req)
+	result, err := s.route(ctx, *req, notify)
 
 	// Notifications (no ID) don't get a response (even on error)
 	if req.ID == nil {
 		return nil
 	}
 
+	// Cancelled requests don't get a response either
+	if ctx.Err() != nil {
+		log.Debug("mcp request cancelled", "id", req.ID)
+		return nil
+	}
+
 	if err != nil {
 		return &Response{
 			JSONRPC: "2.0",
@@ -213,7 +445,7 @@ func (s *Server) handleRequest(data []byte) *Response {
 }
 
 // route dispatches requests to the appropriate handler.
-func (s *Server) route(req Request) (interface{}, *Error) {
+func (s *Server) route(ctx context.Context, req Request, notify func(*Notification)) (interface{}, *Error) {
 	log.Debug("mcp request", "method", req.Method, "id", req.ID)
 
 	switch req.Method {
@@ -222,10 +454,27 @@ func (s *Server) route(req Request) (interface{}, *Error) {
 	case "initialized", "notifications/initialized":
 		// Notification, no response needed
 		return nil, nil
+	case "ping":
+		return struct{}{}, nil
+	case "notifications/cancelled":
+		s.handleCancelled(req.Params)
+		return nil, nil
 	case "tools/list":
 		return s.handleToolsList()
 	case "tools/call":
-		return s.handleToolsCall(req.Params)
+		return s.handleToolsCall(ctx, req.Params, notify)
+	case "resources/list":
+		return s.handleResourcesList()
+	case "resources/read":
+		return s.handleResourcesRead(ctx, req.Params)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(req.Params, true)
+	case "resources/unsubscribe":
+		return s.handleResourcesSubscribe(req.Params, false)
+	case "prompts/list":
+		return s.handlePromptsList()
+	case "prompts/get":
+		return s.handlePromptsGet(req.Params)
 	default:
 		return nil, &Error{
 			Code:    MethodNotFound,
@@ -249,9 +498,11 @@ func (s *Server) handleInitialize(params json.RawMessage) (interface{}, *Error)
 	}
 
 	return InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: negotiateProtocolVersion(p.ProtocolVersion),
 		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{},
+			Tools:     &ToolsCapability{},
+			Resources: &ResourcesCapability{Subscribe: true, ListChanged: true},
+			Prompts:   &PromptsCapability{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "vibium",
@@ -260,15 +511,50 @@ func (s *Server) handleInitialize(params json.RawMessage) (interface{}, *Error)
 	}, nil
 }
 
+// supportedProtocolVersions are the MCP protocol versions the server
+// speaks, newest first. Older clients ignore outputSchema, annotations and
+// structuredContent.
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// negotiateProtocolVersion returns the version requested by the client if
+// the server supports it, or else the latest supported version.
+func negotiateProtocolVersion(requested string) string {
+	for _, version := range supportedProtocolVersions {
+		if version == requested {
+			return version
+		}
+	}
+	return supportedProtocolVersions[0]
+}
+
+// handleCancelled cancels an in-flight request. Unknown or finished
+// requests are ignored.
+func (s *Server) handleCancelled(params json.RawMessage) {
+	var p CancelledParams
+	if err := json.Unmarshal(params, &p); err != nil || p.RequestID == nil {
+		return
+	}
+
+	s.inflightMu.Lock()
+	cancel := s.inflight[requestKey(p.RequestID)]
+	s.inflightMu.Unlock()
+
+	if cancel != nil {
+		log.Debug("mcp cancelling request", "id", p.RequestID, "reason", p.Reason)
+		cancel()
+	}
+}
+
 // handleToolsList returns the list of available tools.
 func (s *Server) handleToolsList() (interface{}, *Error) {
 	return ToolsListResult{
-		Tools: GetToolSchemas(),
+		Tools: s.handlers.Tools(),
 	}, nil
 }
 
 // handleToolsCall executes a tool and returns the result.
-func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
+// If the request has a progress token, progress is sent with notify.
+func (s *Server) handleToolsCall(ctx context.Context, params json.RawMessage, notify func(*Notification)) (interface{}, *Error) {
 	var p ToolsCallParams
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, &Error{
@@ -278,7 +564,31 @@ func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
 		}
 	}
 
-	result, err := s.handlers.Call(p.Name, p.Arguments)
+	if p.Meta != nil && p.Meta.ProgressToken != nil && notify != nil {
+		var progress float64
+		ctx = WithProgress(ctx, func(message string) {
+			progress++
+			notify(&Notification{
+				JSONRPC: "2.0",
+				Method:  "notifications/progress",
+				Params: ProgressParams{
+					ProgressToken: p.Meta.ProgressToken,
+					Progress:      progress,
+					Message:       message,
+				},
+			})
+		})
+	}
+
+	result, err := s.handlers.Call(ctx, p.Name, p.Arguments)
+	var invalid *errs.InvalidArgumentError
+	if errors.As(err, &invalid) {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: invalid.Message,
+			Data:    p.Name,
+		}
+	}
 	if err != nil {
 		return ToolsCallResult{
 			Content: []Content{{Type: "text", Text: err.Error()}},
@@ -289,17 +599,191 @@ func (s *Server) handleToolsCall(params json.RawMessage) (interface{}, *Error) {
 	return result, nil
 }
 
-// writeResponse writes a JSON-RPC response to stdout.
-func (s *Server) writeResponse(resp *Response) error {
-	data, err := json.Marshal(resp)
+// handleResourcesList returns the list of available resources.
+func (s *Server) handleResourcesList() (interface{}, *Error) {
+	return ResourcesListResult{
+		Resources: s.handlers.Resources(),
+	}, nil
+}
+
+// handleResourcesRead returns the contents of a resource.
+func (s *Server) handleResourcesRead(ctx context.Context, params json.RawMessage) (interface{}, *Error) {
+	p, rpcErr := parseResourceParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	result, err := s.handlers.ReadResource(ctx, p.URI)
+	if errors.Is(err, errResourceNotFound) {
+		return nil, &Error{
+			Code:    ResourceNotFound,
+			Message: "Resource not found",
+			Data:    p.URI,
+		}
+	}
+	if err != nil {
+		return nil, &Error{
+			Code:    InternalError,
+			Message: err.Error(),
+			Data:    p.URI,
+		}
+	}
+
+	return result, nil
+}
+
+// handleResourcesSubscribe subscribes to or unsubscribes from
+// notifications/resources/updated for a resource.
+func (s *Server) handleResourcesSubscribe(params json.RawMessage, subscribe bool) (interface{}, *Error) {
+	p, rpcErr := parseResourceParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	s.notifyMu.Lock()
+	if subscribe {
+		s.subscriptions[p.URI] = true
+	} else {
+		delete(s.subscriptions, p.URI)
+	}
+	s.notifyMu.Unlock()
+
+	return struct{}{}, nil
+}
+
+// handlePromptsList returns the list of available prompts.
+func (s *Server) handlePromptsList() (interface{}, *Error) {
+	templates, err := s.prompts()
+	if err != nil {
+		return nil, &Error{
+			Code:    InternalError,
+			Message: err.Error(),
+		}
+	}
+
+	prompts := make([]Prompt, len(templates))
+	for i, p := range templates {
+		prompts[i] = Prompt{
+			Name:        p.Name,
+			Description: p.Description,
+			Arguments:   p.Arguments,
+		}
+	}
+	return PromptsListResult{Prompts: prompts}, nil
+}
+
+// handlePromptsGet returns a prompt filled in with its arguments.
+func (s *Server) handlePromptsGet(params json.RawMessage) (interface{}, *Error) {
+	var p PromptsGetParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    err.Error(),
+		}
+	}
+
+	result, err := s.getPrompt(p.Name, p.Arguments)
+	if err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: err.Error(),
+			Data:    p.Name,
+		}
+	}
+	return result, nil
+}
+
+// parseResourceParams parses params with a required uri.
+func parseResourceParams(params json.RawMessage) (*ResourceParams, *Error) {
+	var p ResourceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    err.Error(),
+		}
+	}
+	if p.URI == "" {
+		return nil, &Error{
+			Code:    InvalidParams,
+			Message: "Invalid params",
+			Data:    "uri is required",
+		}
+	}
+	return &p, nil
+}
+
+// setNotifier sets where unsolicited notifications are sent and returns a
+// function that unsets it, unless another notifier was set since.
+func (s *Server) setNotifier(fn func(*Notification)) func() {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	s.notifierID++
+	id := s.notifierID
+	s.notifier = fn
+
+	return func() {
+		s.notifyMu.Lock()
+		defer s.notifyMu.Unlock()
+		if s.notifierID == id {
+			s.notifier = nil
+		}
+	}
+}
+
+// resourceUpdated sends notifications/resources/updated if the client
+// subscribed to the resource.
+func (s *Server) resourceUpdated(uri string) {
+	s.notifyMu.Lock()
+	subscribed := s.subscriptions[uri]
+	notifier := s.notifier
+	s.notifyMu.Unlock()
+
+	if subscribed && notifier != nil {
+		notifier(&Notification{
+			JSONRPC: "2.0",
+			Method:  "notifications/resources/updated",
+			Params:  ResourceParams{URI: uri},
+		})
+	}
+}
+
+// resourceListChanged sends notifications/resources/list_changed.
+func (s *Server) resourceListChanged() {
+	s.notifyMu.Lock()
+	notifier := s.notifier
+	s.notifyMu.Unlock()
+
+	if notifier != nil {
+		notifier(&Notification{
+			JSONRPC: "2.0",
+			Method:  "notifications/resources/list_changed",
+		})
+	}
+}
+
+// write writes a JSON-RPC message to stdout.
+func (s *Server) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	_, err = fmt.Fprintf(s.writer, "%s\n", data)
 	return err
 }
 
-// Close cleans up the server resources.
+// Close cancels in-flight requests and cleans up the server resources.
 func (s *Server) Close() {
+	s.inflightMu.Lock()
+	for _, cancel := range s.inflight {
+		cancel()
+	}
+	s.inflightMu.Unlock()
+
 	s.handlers.Close()
 }
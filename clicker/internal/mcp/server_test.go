@@ -0,0 +1,43 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToolsCallErrors(t *testing.T) {
+	s := newServer("test", ServerOptions{StateDir: t.TempDir()})
+
+	tests := []struct {
+		name        string
+		params      string
+		wantCode    int    // JSON-RPC error code, or 0 for a tool result
+		wantMessage string // in the error message or the isError result
+	}{
+		{"malformed params", `{"name": 1}`, InvalidParams, "Invalid params"},
+		{"unknown tool", `{"name": "browser_fly"}`, InvalidParams, "unknown tool: browser_fly"},
+		{"missing argument", `{"name": "browser_navigate", "arguments": {}}`, InvalidParams, "url is required"},
+		{"bad argument", `{"name": "browser_save_state", "arguments": {"path": "../state.json"}}`, InvalidParams, "path must be a file name"},
+		{"tool failure", `{"name": "browser_click", "arguments": {"selector": "#go"}}`, 0, "no browser session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := s.handleToolsCall(context.Background(), json.RawMessage(tt.params), nil)
+			if tt.wantCode != 0 {
+				if rpcErr == nil || rpcErr.Code != tt.wantCode || !strings.Contains(rpcErr.Message, tt.wantMessage) {
+					t.Fatalf("handleToolsCall() = %v, %+v, want error %d %q", result, rpcErr, tt.wantCode, tt.wantMessage)
+				}
+				return
+			}
+			if rpcErr != nil {
+				t.Fatalf("handleToolsCall() error = %+v, want a tool result", rpcErr)
+			}
+			res, ok := result.(ToolsCallResult)
+			if !ok || !res.IsError || len(res.Content) == 0 || !strings.Contains(res.Content[0].Text, tt.wantMessage) {
+				t.Errorf("handleToolsCall() = %+v, want isError %q", result, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/log"
+)
+
+// DefaultSessionID is the ID of the browser session launched by
+// browser_launch when no session is named.
+const DefaultSessionID = "default"
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// browserSession is a browser launched by browser_launch. Each session has
+// its own Chrome profile, so cookies and storage are isolated.
+type browserSession struct {
+	mu sync.Mutex // Serializes tool calls and resource reads, see Handlers.Call
+
+	id           string
+	headless     bool
+	launchedAt   time.Time
+	launchResult *browser.LaunchResult
+	conn         *bidi.Connection
+	client       *bidi.Client
+	userDataDir  string
+
+	// Console messages, see Handlers.watchPage
+	consoleMu   sync.Mutex
+	console     []*bidi.LogEntry
+	unwatchPage func()
+
+	// Navigations blocked by the URL policy, see Handlers.Call
+	violationMu sync.Mutex
+	violation   *errs.PolicyViolationError
+	stopPolicy  func()
+}
+
+// launchSession launches a browser with a new profile directory, connects
+// to it and applies the emulation.
+func launchSession(ctx context.Context, id string, headless bool, emulation *bidi.EmulationOptions) (*browserSession, error) {
+	userDataDir, err := os.MkdirTemp("", "vibium-mcp-"+id+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	launchResult, err := browser.Launch(browser.LaunchOptions{
+		Headless:    headless,
+		Emulation:   emulation,
+		UserDataDir: userDataDir,
+	})
+	if err != nil {
+		os.RemoveAll(userDataDir)
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+
+	sess := &browserSession{
+		id:           id,
+		headless:     headless,
+		launchedAt:   time.Now(),
+		launchResult: launchResult,
+		userDataDir:  userDataDir,
+	}
+
+	if ctx.Err() != nil {
+		sess.close()
+		return nil, errs.ErrCancelled
+	}
+
+	// Connect to BiDi
+	conn, err := bidi.Connect(launchResult.WebSocketURL)
+	if err != nil {
+		sess.close()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	sess.conn = conn
+	sess.client = bidi.NewClient(conn)
+
+	// Read in the background so in-flight commands can be interrupted
+	sess.client.StartEventLoop()
+
+	if err := sess.client.ApplyEmulation(launchResult.Emulation); err != nil {
+		sess.close()
+		return nil, fmt.Errorf("failed to apply emulation: %w", err)
+	}
+
+	return sess, nil
+}
+
+// addViolation records a navigation blocked by the URL policy. Only the
+// latest is kept.
+func (s *browserSession) addViolation(err *errs.PolicyViolationError) {
+	log.Debug("navigation blocked", "session", s.id, "url", err.URL, "reason", err.Reason)
+
+	s.violationMu.Lock()
+	s.violation = err
+	s.violationMu.Unlock()
+}
+
+// takeViolation returns and clears the last blocked navigation, if any.
+func (s *browserSession) takeViolation() *errs.PolicyViolationError {
+	s.violationMu.Lock()
+	defer s.violationMu.Unlock()
+
+	err := s.violation
+	s.violation = nil
+	return err
+}
+
+// close closes the browser and removes its profile directory.
+func (s *browserSession) close() {
+	if s.stopPolicy != nil {
+		s.stopPolicy()
+		s.stopPolicy = nil
+	}
+	if s.unwatchPage != nil {
+		s.unwatchPage()
+		s.unwatchPage = nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.launchResult != nil {
+		s.launchResult.Close()
+		s.launchResult = nil
+	}
+	if s.userDataDir != "" {
+		if err := os.RemoveAll(s.userDataDir); err != nil {
+			log.Debug("failed to remove profile directory", "dir", s.userDataDir, "error", err)
+		}
+		s.userDataDir = ""
+	}
+	s.client = nil
+}
+
+// session returns the browser session named by the "session" argument,
+// or the current session (the most recently launched) if none is named.
+func (h *Handlers) session(args map[string]interface{}) (*browserSession, error) {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	id, _ := args["session"].(string)
+	if id == "" {
+		if h.current == "" {
+			return nil, fmt.Errorf("no browser session. Call browser_launch first")
+		}
+		id = h.current
+	}
+
+	sess := h.sessions[id]
+	if sess == nil {
+		return nil, fmt.Errorf("no browser session %q. Call browser_launch with session %q first, or browser_sessions_list to see open sessions", id, id)
+	}
+	return sess, nil
+}
+
+// lockedClose closes the session once its tool call in progress, if any,
+// has finished.
+func (s *browserSession) lockedClose() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+}
+
+// closeSession closes a session. If it was the current session, the most
+// recently launched of the remaining sessions becomes current.
+func (h *Handlers) closeSession(id string) {
+	h.sessionsMu.Lock()
+	sess := h.sessions[id]
+	if sess == nil {
+		h.sessionsMu.Unlock()
+		return
+	}
+	delete(h.sessions, id)
+
+	if h.current == id {
+		h.current = ""
+		var latest time.Time
+		for other, s := range h.sessions {
+			if s.launchedAt.After(latest) {
+				h.current = other
+				latest = s.launchedAt
+			}
+		}
+	}
+	h.sessionsMu.Unlock()
+
+	sess.lockedClose()
+}
+
+// lockSession returns the session named by args (see session) once its
+// other tool calls have finished. Call unlock when done with its browser.
+func (h *Handlers) lockSession(args map[string]interface{}) (sess *browserSession, unlock func(), err error) {
+	sess, err = h.session(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	sess.mu.Lock()
+	return sess, sess.mu.Unlock, nil
+}
+
+// browserSessionsList lists the open browser sessions.
+func (h *Handlers) browserSessionsList(ctx context.Context, args map[string]interface{}) (*ToolsCallResult, error) {
+	h.sessionsMu.Lock()
+	open := make(map[string]*browserSession, len(h.sessions))
+	ids := make([]string, 0, len(h.sessions))
+	for id, sess := range h.sessions {
+		open[id] = sess
+		ids = append(ids, id)
+	}
+	current := h.current
+	h.sessionsMu.Unlock()
+	sort.Strings(ids)
+
+	text := "No browser sessions"
+	if len(ids) > 0 {
+		text = fmt.Sprintf("%d browser session(s):", len(ids))
+	}
+
+	sessions := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		sess := open[id]
+		currentURL, err := sess.client.GetCurrentURL()
+		if err != nil {
+			currentURL = ""
+		}
+
+		marker := ""
+		if id == current {
+			marker = " (current)"
+		}
+		text += fmt.Sprintf("\n- %s%s: %s", id, marker, currentURL)
+
+		sessions = append(sessions, map[string]interface{}{
+			"session":  id,
+			"url":      currentURL,
+			"headless": sess.headless,
+			"current":  id == current,
+		})
+	}
+
+	return &ToolsCallResult{
+		Content: []Content{{
+			Type: "text",
+			Text: text,
+		}},
+		StructuredContent: map[string]interface{}{
+			"sessions": sessions,
+		},
+	}, nil
+}
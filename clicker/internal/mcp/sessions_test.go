@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloseSessionWaitsForToolCall(t *testing.T) {
+	now := time.Now()
+	a := &browserSession{id: "a", launchedAt: now.Add(-time.Minute)}
+	b := &browserSession{id: "b", launchedAt: now}
+	c := &browserSession{id: "c", launchedAt: now.Add(-2 * time.Minute)}
+	h := &Handlers{sessions: map[string]*browserSession{"a": a, "b": b, "c": c}, current: "b"}
+
+	// A tool call on b is in progress
+	sess, unlock, err := h.lockSession(nil)
+	if err != nil || sess != b {
+		t.Fatalf("lockSession() = %v, %v, want b", sess, err)
+	}
+
+	// Other sessions stay usable meanwhile
+	if other, unlockOther, err := h.lockSession(map[string]interface{}{"session": "a"}); err != nil || other != a {
+		t.Fatalf("lockSession(a) = %v, %v", other, err)
+	} else {
+		unlockOther()
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		h.closeSession("b")
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("closeSession() returned during a tool call")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The session is gone right away, and the latest remaining one is current
+	if _, err := h.session(map[string]interface{}{"session": "b"}); err == nil {
+		t.Error("session(b) found a closing session")
+	}
+	if got, err := h.session(nil); err != nil || got != a {
+		t.Errorf("session() = %v, %v, want a", got, err)
+	}
+
+	unlock()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("closeSession() didn't return after the tool call")
+	}
+}
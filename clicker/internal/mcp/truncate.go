@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// truncateValue shrinks a JSON-marshalable value (see bidi.JSONValue) so it
+// encodes to about limit bytes. Long strings are cut on a rune boundary and
+// end with "…", arrays end with a "... (N more items)" string and objects
+// get a "..." key with the number of omitted keys. Returns the value and
+// whether anything was cut.
+func truncateValue(v interface{}, limit int) (interface{}, bool) {
+	t := &truncator{budget: limit}
+	return t.value(v), t.truncated
+}
+
+// truncator tracks the bytes left while walking a value.
+type truncator struct {
+	budget    int
+	truncated bool
+}
+
+func (t *truncator) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		size := len(val) + 2 // Quotes
+		if size <= t.budget {
+			t.budget -= size
+			return val
+		}
+		t.truncated = true
+		keep := t.budget - 2
+		t.budget = 0
+		return truncateString(val, keep) + "…"
+
+	case []interface{}:
+		t.budget -= 2 // Brackets
+		out := make([]interface{}, 0, len(val))
+		for i, item := range val {
+			if t.budget <= 0 {
+				t.truncated = true
+				out = append(out, fmt.Sprintf("... (%d more items)", len(val)-i))
+				break
+			}
+			out = append(out, t.value(item))
+			t.budget-- // Comma
+		}
+		return out
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		t.budget -= 2 // Braces
+		out := make(map[string]interface{}, len(val))
+		for i, k := range keys {
+			if t.budget <= len(k)+4 {
+				t.truncated = true
+				out["..."] = fmt.Sprintf("%d more keys", len(keys)-i)
+				break
+			}
+			t.budget -= len(k) + 4 // Quotes, colon and comma
+			out[k] = t.value(val[k])
+		}
+		return out
+
+	default:
+		// Numbers, booleans, null and references are kept whole
+		data, _ := json.Marshal(val)
+		t.budget -= len(data)
+		return val
+	}
+}
+
+// truncateString returns at most n bytes of s without splitting a rune.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,52 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateValue(t *testing.T) {
+	many := make([]interface{}, 100)
+	for i := range many {
+		many[i] = float64(i)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		keys[k] = strings.Repeat(k, 10)
+	}
+
+	tests := []struct {
+		name          string
+		value         interface{}
+		limit         int
+		wantTruncated bool
+		want          string
+	}{
+		{"fits", map[string]interface{}{"a": 1.0}, 100, false, `{"a":1}`},
+		{"string", "abcdefghij", 7, true, `"abcde…"`},
+		{"string on rune boundary", "ééééé", 7, true, `"éé…"`},
+		{"array", many, 20, true, `[0,1,2,3,4,5,6,7,8,"... (91 more items)"]`},
+		{"object", keys, 40, true, `{"...":"4 more keys","a":"aaaaaaaaaa","b":"bbbbbbbbbb"}`},
+		{"nested", []interface{}{map[string]interface{}{"s": strings.Repeat("x", 50)}}, 20, true, `[{"s":"xxxxxxxxx…"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateValue(tt.value, tt.limit)
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("json.Marshal() = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("truncateValue() = %s, want %s", data, tt.want)
+			}
+			if !utf8.Valid(data) {
+				t.Errorf("truncateValue() = %q is not valid UTF-8", data)
+			}
+		})
+	}
+}
@@ -157,6 +157,16 @@ func GetPlatformString() string {
 	return getPlatformString()
 }
 
+// GetStateDir returns the default directory for storage state files saved
+// by the MCP server, in the cache directory.
+func GetStateDir() (string, error) {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "state"), nil
+}
+
 // GetScreenshotDir returns the platform-specific default directory for screenshots.
 // macOS: ~/Pictures/Vibium/
 // Linux: ~/Pictures/Vibium/
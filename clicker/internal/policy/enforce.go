@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/log"
+)
+
+// Enforcer applies a policy to the requests paused by a network intercept
+// and to navigations, for Enforce and for the proxy, which shares the
+// browser connection with its client.
+type Enforcer struct {
+	Policy    *Policy
+	Intercept string // Intercept pausing every request (see bidi.Client.AddIntercept)
+
+	// Send sends a BiDi command and waits for the result
+	Send func(method string, params map[string]interface{}) error
+	// OnViolation is called from a background goroutine for each blocked navigation
+	OnViolation func(context string, violation *errs.PolicyViolationError)
+}
+
+// HandleRequest resolves a request paused by the intercept in the
+// background: a document request to a blocked URL fails, and any other
+// request continues unless other intercepts paused it too, in which case
+// their owners continue it. Returns true if the enforcer resolves the
+// request, so nobody else may.
+func (e *Enforcer) HandleRequest(params *bidi.BeforeRequestSentParams) bool {
+	if !params.BlockedBy(e.Intercept) {
+		return false
+	}
+
+	var violation *errs.PolicyViolationError
+	if params.Navigation != nil {
+		errors.As(e.Policy.Check(params.Request.URL), &violation)
+	}
+	if violation == nil && len(params.Intercepts) > 1 {
+		return false
+	}
+
+	// Resolve the request off the caller's goroutine, which reads the
+	// responses
+	go func() {
+		method := "network.continueRequest"
+		if violation != nil {
+			method = "network.failRequest"
+		}
+		if err := e.Send(method, map[string]interface{}{"request": params.Request.Request}); err != nil {
+			log.Debug("failed to resolve intercepted request", "request", params.Request.Request, "error", err)
+		}
+		if violation != nil {
+			e.OnViolation(params.Context, violation)
+		}
+	}()
+	return true
+}
+
+// HandleNavigation sends a context to about:blank in the background if it
+// started a blocked navigation that makes no network request (e.g.
+// file://). Network navigations are handled by HandleRequest.
+func (e *Enforcer) HandleNavigation(params *bidi.NavigationStartedParams) {
+	if u, err := url.Parse(params.URL); err == nil && IsNetworkURL(u) {
+		return
+	}
+
+	var violation *errs.PolicyViolationError
+	if !errors.As(e.Policy.Check(params.URL), &violation) {
+		return
+	}
+	go func() {
+		err := e.Send("browsingContext.navigate", map[string]interface{}{
+			"context": params.Context,
+			"url":     "about:blank",
+			"wait":    "complete",
+		})
+		if err != nil {
+			log.Debug("failed to leave blocked page", "url", params.URL, "error", err)
+		}
+		e.OnViolation(params.Context, violation)
+	}()
+}
+
+// Enforce applies the policy to navigations in every browsing context of
+// client, including ones started by the page (links, scripts, redirects).
+// Document requests to blocked URLs fail, and blocked navigations that make
+// no network request (e.g. file://) are sent to about:blank. onViolation is
+// called from a background goroutine for each blocked navigation.
+//
+// The client must run its event loop (see bidi.Client.StartEventLoop).
+// Returns a function that stops enforcing.
+func Enforce(client *bidi.Client, p *Policy, onViolation func(err *errs.PolicyViolationError)) (func(), error) {
+	intercept, err := client.AddIntercept([]string{"beforeRequestSent"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	enforcer := &Enforcer{
+		Policy:    p,
+		Intercept: intercept,
+		Send: func(method string, params map[string]interface{}) error {
+			return retryCancelled(func() error {
+				_, err := client.SendCommand(method, params)
+				return err
+			})
+		},
+		OnViolation: func(context string, violation *errs.PolicyViolationError) {
+			onViolation(violation)
+		},
+	}
+
+	removeRequests, err := client.OnBeforeRequestSent(func(params *bidi.BeforeRequestSentParams) {
+		enforcer.HandleRequest(params)
+	})
+	if err != nil {
+		client.RemoveIntercept(intercept)
+		return nil, err
+	}
+
+	removeNavigations, err := client.OnNavigationStarted(enforcer.HandleNavigation)
+	if err != nil {
+		removeRequests()
+		client.RemoveIntercept(intercept)
+		return nil, err
+	}
+
+	return func() {
+		removeRequests()
+		removeNavigations()
+		client.RemoveIntercept(intercept)
+	}, nil
+}
+
+// retryCancelled runs fn again if bidi.Client.Interrupt cancelled it, so a
+// cancelled tool call doesn't leave a request paused forever.
+func retryCancelled(fn func() error) error {
+	err := fn()
+	if errors.Is(err, errs.ErrCancelled) {
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// sent is a command sent by an Enforcer.
+type sent struct {
+	method string
+	params map[string]interface{}
+}
+
+// newTestEnforcer returns an enforcer allowing example.com and a channel of
+// the commands it sends and the contexts it reports violations for.
+func newTestEnforcer(t *testing.T) (*Enforcer, chan sent, chan string) {
+	t.Helper()
+	p, err := New([]string{"example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := make(chan sent, 10)
+	violations := make(chan string, 10)
+	return &Enforcer{
+		Policy:    p,
+		Intercept: "policy",
+		Send: func(method string, params map[string]interface{}) error {
+			commands <- sent{method, params}
+			return nil
+		},
+		OnViolation: func(context string, violation *errs.PolicyViolationError) {
+			violations <- context
+		},
+	}, commands, violations
+}
+
+func request(url string, navigation bool, intercepts ...string) *bidi.BeforeRequestSentParams {
+	params := &bidi.BeforeRequestSentParams{Context: "ctx", IsBlocked: len(intercepts) > 0, Intercepts: intercepts}
+	params.Request.Request = "req-1"
+	params.Request.URL = url
+	if navigation {
+		nav := "nav-1"
+		params.Navigation = &nav
+	}
+	return params
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+		panic("unreachable")
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  *bidi.BeforeRequestSentParams
+		handled bool
+		method  string
+	}{
+		{"allowed navigation", request("https://example.com/", true, "policy"), true, "network.continueRequest"},
+		{"blocked navigation", request("https://evil.example/", true, "policy"), true, "network.failRequest"},
+		{"subresource", request("https://cdn.example/app.js", false, "policy"), true, "network.continueRequest"},
+		{"blocked with other intercept", request("https://evil.example/", true, "policy", "client"), true, "network.failRequest"},
+		{"allowed with other intercept", request("https://example.com/", true, "policy", "client"), false, ""},
+		{"other intercept only", request("https://evil.example/", true, "client"), false, ""},
+		{"not paused", request("https://evil.example/", true), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, commands, violations := newTestEnforcer(t)
+			if got := e.HandleRequest(tt.params); got != tt.handled {
+				t.Fatalf("HandleRequest() = %v, want %v", got, tt.handled)
+			}
+			if !tt.handled {
+				return
+			}
+			cmd := receive(t, commands)
+			if cmd.method != tt.method || cmd.params["request"] != "req-1" {
+				t.Errorf("sent %s %v, want %s for req-1", cmd.method, cmd.params, tt.method)
+			}
+			if tt.method == "network.failRequest" {
+				if context := receive(t, violations); context != "ctx" {
+					t.Errorf("violation in %q, want ctx", context)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleNavigation(t *testing.T) {
+	e, commands, violations := newTestEnforcer(t)
+
+	// Network navigations are left to HandleRequest
+	e.HandleNavigation(&bidi.NavigationStartedParams{Context: "ctx", URL: "https://evil.example/"})
+	e.HandleNavigation(&bidi.NavigationStartedParams{Context: "ctx", URL: "about:blank"})
+
+	e.HandleNavigation(&bidi.NavigationStartedParams{Context: "ctx", URL: "file:///etc/passwd"})
+	cmd := receive(t, commands)
+	if cmd.method != "browsingContext.navigate" || cmd.params["url"] != "about:blank" || cmd.params["context"] != "ctx" {
+		t.Errorf("sent %s %v, want navigate ctx to about:blank", cmd.method, cmd.params)
+	}
+	receive(t, violations)
+
+	select {
+	case cmd := <-commands:
+		t.Errorf("unexpected %s %v", cmd.method, cmd.params)
+	default:
+	}
+}
@@ -0,0 +1,236 @@
+// Package policy implements the URL policy that restricts where a browser
+// may navigate, e.g. to keep an agent on a staging site.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// Policy decides which URLs a browser may navigate to. Patterns match
+// origins, and a lone * matches any http or https URL:
+//
+//	staging.example.com          http or https on any port
+//	*.example.com                example.com and its subdomains
+//	https://staging.example.com  only https
+//	localhost:3000               only port 3000
+//	file://*                     local files
+//
+// A URL matching a blocked pattern is never allowed. If there are allowed
+// patterns, a URL must match one of them. URLs other than http and https
+// (e.g. file:// and chrome://) must be allowed explicitly by scheme.
+// about:blank is always allowed.
+//
+// A nil *Policy allows everything.
+type Policy struct {
+	allow []pattern
+	block []pattern
+}
+
+// File is the JSON format of a policy file.
+type File struct {
+	Allow []string `json:"allow,omitempty"`
+	Block []string `json:"block,omitempty"`
+}
+
+// pattern is a parsed origin pattern.
+type pattern struct {
+	raw    string
+	scheme string // "" matches http and https
+	host   string // "*" matches any host; "*.example.com" matches subdomains too
+	port   string // "" matches any port
+}
+
+// New creates a policy from allowed and blocked origin patterns. It returns
+// nil if both are empty.
+func New(allow, block []string) (*Policy, error) {
+	if len(allow) == 0 && len(block) == 0 {
+		return nil, nil
+	}
+
+	p := &Policy{}
+	for _, s := range allow {
+		pat, err := parsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		p.allow = append(p.allow, pat)
+	}
+	for _, s := range block {
+		pat, err := parsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		p.block = append(p.block, pat)
+	}
+	return p, nil
+}
+
+// ReadFile reads the allowed and blocked patterns of a JSON policy file.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// parsePattern parses an origin pattern such as "https://*.example.com:8443".
+func parsePattern(s string) (pattern, error) {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return pattern{}, fmt.Errorf("empty URL pattern")
+	}
+
+	pat := pattern{raw: raw}
+	rest := raw
+	if i := strings.Index(rest, "://"); i >= 0 {
+		pat.scheme = strings.ToLower(rest[:i])
+		rest = rest[i+len("://"):]
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	if strings.ContainsAny(rest, "/?#") {
+		return pattern{}, fmt.Errorf("invalid URL pattern %q: patterns match origins and can't contain a path", raw)
+	}
+
+	pat.host = rest
+	if host, port, err := net.SplitHostPort(rest); err == nil {
+		pat.host = host
+		pat.port = port
+	}
+	pat.host = strings.ToLower(strings.Trim(pat.host, "[]"))
+
+	if pat.host == "" {
+		pat.host = "*"
+	}
+	if strings.Contains(strings.TrimPrefix(pat.host, "*."), "*") && pat.host != "*" {
+		return pattern{}, fmt.Errorf("invalid URL pattern %q: * is only allowed as the whole host or a leading *.", raw)
+	}
+
+	return pat, nil
+}
+
+// matches returns true if the pattern matches the URL.
+func (pat pattern) matches(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	if pat.scheme == "" {
+		if scheme != "http" && scheme != "https" {
+			return false
+		}
+	} else if pat.scheme != scheme {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case pat.host == "*":
+	case strings.HasPrefix(pat.host, "*."):
+		domain := pat.host[len("*."):]
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	case pat.host != host:
+		return false
+	}
+
+	if pat.port != "" && pat.port != portOf(u) {
+		return false
+	}
+	return true
+}
+
+// portOf returns the URL's port, or the default port of its scheme.
+func portOf(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+// Check returns a *errors.PolicyViolationError if the policy doesn't allow
+// navigating to rawURL.
+func (p *Policy) Check(rawURL string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &errs.PolicyViolationError{URL: rawURL, Reason: "invalid URL"}
+	}
+	if strings.EqualFold(u.Scheme, "about") && (u.Opaque == "blank" || u.Opaque == "srcdoc") {
+		return nil
+	}
+
+	for _, pat := range p.block {
+		if pat.matches(u) {
+			return &errs.PolicyViolationError{URL: rawURL, Reason: fmt.Sprintf("matches blocked pattern %q", pat.raw)}
+		}
+	}
+
+	if len(p.allow) == 0 {
+		if !IsNetworkURL(u) {
+			return &errs.PolicyViolationError{URL: rawURL, Reason: fmt.Sprintf("%s: URLs are not allowed", u.Scheme)}
+		}
+		return nil
+	}
+
+	for _, pat := range p.allow {
+		if pat.matches(u) {
+			return nil
+		}
+	}
+	if !IsNetworkURL(u) {
+		return &errs.PolicyViolationError{URL: rawURL, Reason: fmt.Sprintf("%s: URLs are not allowed", u.Scheme)}
+	}
+	return &errs.PolicyViolationError{URL: rawURL, Reason: "not in the allowed origins " + p.allowedList()}
+}
+
+// Patterns returns the policy's patterns in the format of a policy file, or
+// nil for a nil policy.
+func (p *Policy) Patterns() *File {
+	if p == nil {
+		return nil
+	}
+	file := &File{}
+	for _, pat := range p.allow {
+		file.Allow = append(file.Allow, pat.raw)
+	}
+	for _, pat := range p.block {
+		file.Block = append(file.Block, pat.raw)
+	}
+	return file
+}
+
+// allowedList returns the allowed patterns for error messages.
+func (p *Policy) allowedList() string {
+	raws := make([]string, len(p.allow))
+	for i, pat := range p.allow {
+		raws[i] = pat.raw
+	}
+	return "(" + strings.Join(raws, ", ") + ")"
+}
+
+// IsNetworkURL returns true for http and https URLs, which are loaded with
+// network requests that can be intercepted.
+func IsNetworkURL(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
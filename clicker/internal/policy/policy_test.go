@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		block []string
+		url   string
+		want  bool // allowed
+	}{
+		{"nil policy", nil, nil, "file:///etc/passwd", true},
+		{"allowed host", []string{"staging.example.com"}, nil, "https://staging.example.com/login", true},
+		{"other host", []string{"staging.example.com"}, nil, "https://example.com/", false},
+		{"any port", []string{"staging.example.com"}, nil, "http://staging.example.com:8080/", true},
+		{"host case", []string{"Staging.Example.com"}, nil, "https://STAGING.example.com/", true},
+		{"subdomain", []string{"*.example.com"}, nil, "https://a.b.example.com/", true},
+		{"bare domain", []string{"*.example.com"}, nil, "https://example.com/", true},
+		{"suffix only", []string{"*.example.com"}, nil, "https://badexample.com/", false},
+		{"scheme", []string{"https://example.com"}, nil, "http://example.com/", false},
+		{"port", []string{"localhost:3000"}, nil, "http://localhost:3000/", true},
+		{"other port", []string{"localhost:3000"}, nil, "http://localhost:3001/", false},
+		{"default port", []string{"example.com:443"}, nil, "https://example.com/", true},
+		{"ipv6", []string{"[::1]:8080"}, nil, "http://[::1]:8080/", true},
+		{"star", []string{"*"}, nil, "https://anything.example/", true},
+		{"star not file", []string{"*"}, nil, "file:///etc/passwd", false},
+		{"file allowed", []string{"file://*"}, nil, "file:///tmp/page.html", true},
+		{"blocked", nil, []string{"*.evil.example"}, "https://www.evil.example/", false},
+		{"not blocked", nil, []string{"*.evil.example"}, "https://example.com/", true},
+		{"block only file", nil, []string{"evil.example"}, "file:///etc/passwd", false},
+		{"block wins", []string{"*"}, []string{"admin.example.com"}, "https://admin.example.com/", false},
+		{"about:blank", []string{"example.com"}, nil, "about:blank", true},
+		{"about:srcdoc", []string{"example.com"}, nil, "about:srcdoc", true},
+		{"chrome", []string{"example.com"}, nil, "chrome://settings", false},
+		{"data", nil, []string{"evil.example"}, "data:text/html,hi", false},
+		{"invalid", []string{"example.com"}, nil, "http://[::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.allow, tt.block)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			err = p.Check(tt.url)
+			if got := err == nil; got != tt.want {
+				t.Errorf("Check(%q) = %v, want allowed %v", tt.url, err, tt.want)
+			}
+			var violation *errs.PolicyViolationError
+			if err != nil && !errors.As(err, &violation) {
+				t.Errorf("Check(%q) = %T, want *PolicyViolationError", tt.url, err)
+			}
+		})
+	}
+}
+
+func TestNewInvalidPattern(t *testing.T) {
+	for _, raw := range []string{"", "  ", "example.com/path", "https://example.com?q", "a.*.example.com", "ex*ample.com"} {
+		if _, err := New([]string{raw}, nil); err == nil {
+			t.Errorf("New(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	p, err := New(nil, nil)
+	if p != nil || err != nil {
+		t.Errorf("New(nil, nil) = %v, %v, want nil, nil", p, err)
+	}
+}
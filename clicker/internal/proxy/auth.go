@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/vibium/clicker/internal/auth"
+)
+
+// authorized returns true if the request carries the server's token, as an
+// "Authorization: Bearer <token>" header or a "token" query parameter.
+// Browsers can't set headers on WebSocket connections, hence the parameter.
+func (s *Server) authorized(r *http.Request) bool {
+	token := auth.BearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return auth.TokenMatches(token, s.token)
+}
+
+// requireToken answers requests without the token with 401.
+func (s *Server) requireToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin allows requests without an Origin header, which come from
+// programs rather than web pages, and requests from allowed origins.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	return auth.OriginAllowed(r, s.allowedOrigins)
+}
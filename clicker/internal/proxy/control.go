@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// urlTimeout bounds how long /sessions waits for a browser to report its
+// current URL.
+const urlTimeout = 2 * time.Second
+
+// SessionInfo describes an active browser session in the /sessions
+// endpoint.
+type SessionInfo struct {
+	ClientID      uint64    `json:"clientId"`
+	StartedAt     time.Time `json:"startedAt"`
+	UptimeSeconds float64   `json:"uptimeSeconds"`
+	IdleSeconds   float64   `json:"idleSeconds"` // Since the client's last command
+	BrowserPID    int       `json:"browserPid,omitempty"`
+	URL           string    `json:"url,omitempty"`
+	UserContext   string    `json:"userContext,omitempty"` // Set in shared browsers
+	Detached      bool      `json:"detached,omitempty"`    // Client disconnected, see WithKeepAlive
+	Resumable     bool      `json:"resumable,omitempty"`   // Has a resume token, see WithKeepAlive
+	Observers     int       `json:"observers,omitempty"`   // Clients observing the session
+}
+
+// ControlHandler returns the HTTP control plane of the router:
+//
+//	GET    /healthz        200 while the server runs
+//	GET    /readyz         200 if a new client would get a session now, 503 if not
+//	GET    /sessions       active sessions as a JSON array of SessionInfo
+//	DELETE /sessions/{id}  ends the session of a client
+//	GET    /metrics        metrics in the Prometheus text format
+func (r *Router) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		if !allowMethod(w, req, http.MethodGet) {
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		if !allowMethod(w, req, http.MethodGet) {
+			return
+		}
+		if err := r.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, req *http.Request) {
+		if !allowMethod(w, req, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Sessions())
+	})
+	mux.HandleFunc("/sessions/", func(w http.ResponseWriter, req *http.Request) {
+		if !allowMethod(w, req, http.MethodDelete) {
+			return
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(req.URL.Path, "/sessions/"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid client id", http.StatusBadRequest)
+			return
+		}
+		if !r.EndSession(id) {
+			http.Error(w, "no session for client", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		if !allowMethod(w, req, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.metrics.write(w, r.gauges())
+	})
+	return mux
+}
+
+// allowMethod answers requests with another method than method with 405.
+// Returns true if the request has the method.
+func allowMethod(w http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// ready returns an error if a new client would not get a session right away.
+func (r *Router) ready() error {
+	active, _, closed := r.pool.stats()
+	if closed {
+		return fmt.Errorf("server is shutting down")
+	}
+	if max := r.poolOpts.MaxSessions; max > 0 && active >= max {
+		return fmt.Errorf("all %d browser sessions are in use", max)
+	}
+	return nil
+}
+
+// gauges reads the current metrics gauges.
+func (r *Router) gauges() gauges {
+	_, idle, _ := r.pool.stats()
+	g := gauges{
+		idleBrowsers: idle,
+		maxSessions:  r.poolOpts.MaxSessions,
+	}
+	r.sessions.Range(func(key, value interface{}) bool {
+		g.activeSessions++
+		return true
+	})
+
+	r.sharedMu.Lock()
+	g.sharedBrowsers = len(r.shared)
+	r.sharedMu.Unlock()
+	return g
+}
+
+// Sessions describes the active browser sessions, ordered by client ID.
+func (r *Router) Sessions() []SessionInfo {
+	var sessions []*BrowserSession
+	r.sessions.Range(func(key, value interface{}) bool {
+		sessions = append(sessions, value.(*BrowserSession))
+		return true
+	})
+
+	infos := make([]SessionInfo, len(sessions))
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		launchResult := session.LaunchResult
+		if session.shared != nil {
+			launchResult = session.shared.launchResult
+		}
+
+		infos[i] = SessionInfo{
+			ClientID:      session.client().ID,
+			StartedAt:     session.started,
+			UptimeSeconds: time.Since(session.started).Seconds(),
+			IdleSeconds:   time.Since(session.lastActive()).Seconds(),
+			UserContext:   session.userContext,
+			Detached:      session.isDetached(),
+			Resumable:     session.resumeToken != "",
+			Observers:     session.observerCount(),
+		}
+		if launchResult != nil {
+			infos[i].BrowserPID = launchResult.BrowserPID()
+		}
+
+		// Ask the browsers in parallel, so a hung one doesn't delay the rest
+		wg.Add(1)
+		go func(info *SessionInfo, session *BrowserSession) {
+			defer wg.Done()
+			info.URL = r.currentURL(session)
+		}(&infos[i], session)
+	}
+	wg.Wait()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ClientID < infos[j].ClientID
+	})
+	return infos
+}
+
+// currentURL returns the URL of the session's first tab, or "" if the
+// browser doesn't answer within urlTimeout.
+func (r *Router) currentURL(session *BrowserSession) string {
+	ch := make(chan string, 1)
+	go func() {
+		resp, err := r.sendInternalCommand(session, "browsingContext.getTree", map[string]interface{}{
+			"maxDepth": 0,
+		})
+		if err != nil {
+			ch <- ""
+			return
+		}
+
+		var result struct {
+			Result struct {
+				Contexts []struct {
+					URL string `json:"url"`
+				} `json:"contexts"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(resp, &result) != nil || len(result.Result.Contexts) == 0 {
+			ch <- ""
+			return
+		}
+		ch <- result.Result.Contexts[0].URL
+	}()
+
+	select {
+	case url := <-ch:
+		return url
+	case <-time.After(urlTimeout):
+		return ""
+	}
+}
+
+// EndSession closes the browser session of a client and disconnects it.
+// Returns false if the client has no session.
+func (r *Router) EndSession(clientID uint64) bool {
+	sessionVal, ok := r.sessions.Load(clientID)
+	if !ok {
+		return false
+	}
+
+	fmt.Printf("[router] Ending browser session for client %d on request\n", clientID)
+	r.endSession(sessionVal.(*BrowserSession))
+	return true
+}
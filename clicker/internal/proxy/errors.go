@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// bidiError is an error response (follows WebDriver BiDi spec). ID is nil
+// for errors that aren't about a command.
+type bidiError struct {
+	ID         *int   `json:"id"`
+	Type       string `json:"type"` // Always "error"
+	Error      string `json:"error"`
+	Message    string `json:"message"`
+	Stacktrace string `json:"stacktrace"`
+	Check      string `json:"vibium:check,omitempty"` // Failed actionability check, for "element not interactable"
+}
+
+// newBidiError builds the error response for err.
+func newBidiError(id *int, err error) bidiError {
+	resp := bidiError{
+		ID:      id,
+		Type:    "error",
+		Error:   errorCode(err),
+		Message: err.Error(),
+	}
+
+	var rejected *commandError
+	var protocolErr *errs.ProtocolError
+	var scriptErr *errs.ScriptExceptionError
+	var interactableErr *errs.ElementNotInteractableError
+	switch {
+	case errors.As(err, &rejected):
+		resp.Message = rejected.message
+	case errors.As(err, &protocolErr):
+		resp.Message = protocolErr.Message
+		resp.Stacktrace = protocolErr.Stacktrace
+	case errors.As(err, &scriptErr):
+		resp.Stacktrace = scriptErr.Stack
+	case errors.As(err, &interactableErr):
+		resp.Check = interactableErr.Check
+	}
+	return resp
+}
+
+// marshal returns the response as a message for the client.
+func (e bidiError) marshal() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// errorCode returns the WebDriver BiDi error code for err. Errors from the
+// browser keep their code; "element not interactable" is vibium's own.
+func errorCode(err error) string {
+	var (
+		rejected        *commandError
+		protocolErr     *errs.ProtocolError
+		notFound        *errs.ElementNotFoundError
+		notInteractable *errs.ElementNotInteractableError
+		invalidArg      *errs.InvalidArgumentError
+		violation       *errs.PolicyViolationError
+		unknownCmd      *errs.UnknownCommandError
+		unsupported     *errs.UnsupportedOperationError
+		timeout         *errs.TimeoutError
+	)
+	switch {
+	case errors.As(err, &rejected):
+		return rejected.code
+	case errors.As(err, &protocolErr):
+		return protocolErr.Code
+	case errors.As(err, &notFound):
+		return "no such element"
+	case errors.As(err, &notInteractable):
+		return "element not interactable"
+	case errors.As(err, &invalidArg), errors.As(err, &violation):
+		return "invalid argument"
+	case errors.As(err, &unknownCmd):
+		return "unknown command"
+	case errors.As(err, &unsupported):
+		return "unsupported operation"
+	case errors.As(err, &timeout):
+		return "timeout"
+	default:
+		return "unknown error"
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+func TestNewBidiError(t *testing.T) {
+	id := 7
+	tests := []struct {
+		name       string
+		err        error
+		want       string // error code
+		message    string
+		stacktrace string
+		check      string
+	}{
+		{
+			name:    "rejected command",
+			err:     &commandError{code: "invalid argument", message: "url is required"},
+			want:    "invalid argument",
+			message: "url is required",
+		},
+		{
+			name:       "browser error",
+			err:        &errs.ProtocolError{Code: "no such frame", Message: "Context not found", Stacktrace: "at frame"},
+			want:       "no such frame",
+			message:    "Context not found",
+			stacktrace: "at frame",
+		},
+		{
+			name:    "wrapped browser error",
+			err:     fmt.Errorf("navigate: %w", &errs.ProtocolError{Code: "unknown error", Message: "net::ERR_NAME_NOT_RESOLVED"}),
+			want:    "unknown error",
+			message: "net::ERR_NAME_NOT_RESOLVED",
+		},
+		{
+			name:       "script exception",
+			err:        &errs.ScriptExceptionError{Message: "boom", Stack: "at f (x.js:1:1)"},
+			want:       "unknown error",
+			message:    "script exception: boom\nat f (x.js:1:1)",
+			stacktrace: "at f (x.js:1:1)",
+		},
+		{
+			name:    "not interactable",
+			err:     &errs.ElementNotInteractableError{Selector: "#go", Check: "Visible", Reason: "zero size", Timeout: time.Second},
+			want:    "element not interactable",
+			message: "element not interactable after 1s: '#go' failed check 'Visible': zero size",
+			check:   "Visible",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newBidiError(&id, tt.err)
+			if got.ID == nil || *got.ID != id || got.Type != "error" {
+				t.Errorf("newBidiError() id/type = %v/%q", got.ID, got.Type)
+			}
+			if got.Error != tt.want {
+				t.Errorf("Error = %q, want %q", got.Error, tt.want)
+			}
+			if got.Message != tt.message {
+				t.Errorf("Message = %q, want %q", got.Message, tt.message)
+			}
+			if got.Stacktrace != tt.stacktrace {
+				t.Errorf("Stacktrace = %q, want %q", got.Stacktrace, tt.stacktrace)
+			}
+			if got.Check != tt.check {
+				t.Errorf("Check = %q, want %q", got.Check, tt.check)
+			}
+		})
+	}
+}
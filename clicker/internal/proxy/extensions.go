@@ -0,0 +1,355 @@
+package proxy
+
+import (
+	"encoding/json"
+	"sort"
+
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// selectorEngines are the selector syntaxes vibium: commands accept.
+var selectorEngines = []string{"css"}
+
+// extensionEvents are the vibium: events the proxy may send.
+var extensionEvents = []string{"vibium:sessionReady", "vibium:navigationBlocked", "vibium:observing"}
+
+// Extension describes a vibium: extension command for vibium:describe, with
+// JSON schemas of its params and result.
+type Extension struct {
+	Method       string                 `json:"method"`
+	Description  string                 `json:"description"`
+	ParamsSchema map[string]interface{} `json:"params"`
+	ResultSchema map[string]interface{} `json:"result"`
+}
+
+// WithVersion sets the clicker version advertised to clients in the
+// vibium:capabilities of session.new and session.status.
+func WithVersion(version string) RouterOption {
+	return func(r *Router) {
+		r.version = version
+	}
+}
+
+// Extensions returns the vibium: extension commands the proxy supports,
+// ordered by method.
+func Extensions() []Extension {
+	extensions := []Extension{
+		{
+			Method:      "vibium:find",
+			Description: "Wait for an element matching the selector to exist and describe it",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"selector": selectorSchema(),
+				"context":  contextSchema(),
+				"timeout":  timeoutSchema(),
+			}, "selector"),
+			ResultSchema: objectSchema(elementSchema(), "tag", "text", "box"),
+		},
+		{
+			Method:      "vibium:click",
+			Description: "Wait for an element to be visible, stable, enabled and not obscured, then click its center",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"selector": selectorSchema(),
+				"context":  contextSchema(),
+				"timeout":  timeoutSchema(),
+			}, "selector"),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"clicked": map[string]interface{}{"type": "boolean"},
+			}, "clicked"),
+		},
+		{
+			Method:      "vibium:type",
+			Description: "Wait for an element to be editable, click it and type the text",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"selector": selectorSchema(),
+				"text":     map[string]interface{}{"type": "string", "description": "Text to type"},
+				"context":  contextSchema(),
+				"timeout":  timeoutSchema(),
+			}, "selector", "text"),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"typed": map[string]interface{}{"type": "boolean"},
+			}, "typed"),
+		},
+		{
+			Method:      "vibium:content",
+			Description: "Extract the page's main content, or an element's, as Markdown, text or HTML",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"selector":  map[string]interface{}{"type": "string", "description": "CSS selector of the element to extract (default: main content)"},
+				"format":    map[string]interface{}{"type": "string", "enum": []string{"md", "text", "html"}, "default": "md"},
+				"maxLength": map[string]interface{}{"type": "integer", "minimum": 0, "description": "Maximum length in characters (0 = unlimited)"},
+				"context":   contextSchema(),
+			}),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"url":       map[string]interface{}{"type": "string"},
+				"title":     map[string]interface{}{"type": "string"},
+				"format":    map[string]interface{}{"type": "string", "enum": []string{"md", "text", "html"}},
+				"content":   map[string]interface{}{"type": "string"},
+				"truncated": map[string]interface{}{"type": "boolean"},
+			}, "url", "title", "format", "content"),
+		},
+		{
+			Method:      "vibium:emulate",
+			Description: "Override geolocation, timezone, locale, color scheme or reduced motion",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"geolocation": objectSchema(map[string]interface{}{
+					"latitude":  map[string]interface{}{"type": "number", "minimum": -90, "maximum": 90},
+					"longitude": map[string]interface{}{"type": "number", "minimum": -180, "maximum": 180},
+					"accuracy":  map[string]interface{}{"type": "number", "minimum": 0, "description": "Meters, defaults to 1"},
+				}, "latitude", "longitude"),
+				"timezone":      map[string]interface{}{"type": "string", "description": "IANA timezone ID, e.g. Europe/Berlin"},
+				"locale":        map[string]interface{}{"type": "string", "description": "BCP 47 language tag, e.g. de-DE"},
+				"colorScheme":   map[string]interface{}{"type": "string", "enum": []string{"light", "dark"}},
+				"reducedMotion": map[string]interface{}{"type": "string", "enum": []string{"reduce", "no-preference"}},
+				"context":       map[string]interface{}{"type": "string", "description": "Browsing context to emulate in (default: all)"},
+			}),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"emulated": map[string]interface{}{"type": "boolean"},
+			}, "emulated"),
+		},
+		{
+			Method:      "vibium:addInitScript",
+			Description: "Run a script in every new document before any page script",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"script": map[string]interface{}{"type": "string", "description": "JavaScript source"},
+				"contexts": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Browsing contexts to run in (default: all)",
+				},
+			}, "script"),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"script": map[string]interface{}{"type": "string", "description": "Preload script ID"},
+			}, "script"),
+		},
+		{
+			Method:       "vibium:cookies.get",
+			Description:  "Get the cookies matching the filter",
+			ParamsSchema: cookieFilterSchema(),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"cookies": map[string]interface{}{"type": "array", "items": cookieSchema()},
+			}, "cookies"),
+		},
+		{
+			Method:       "vibium:cookies.set",
+			Description:  "Set a cookie, for its domain or the host of url",
+			ParamsSchema: cookieSchema(),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"set": map[string]interface{}{"type": "boolean"},
+			}, "set"),
+		},
+		{
+			Method:       "vibium:cookies.delete",
+			Description:  "Delete the cookies matching the filter, or all cookies without one",
+			ParamsSchema: cookieFilterSchema(),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"deleted": map[string]interface{}{"type": "boolean"},
+			}, "deleted"),
+		},
+		{
+			Method:      "vibium:describe",
+			Description: "Describe the vibium: extension commands with JSON schemas of their params and results",
+			ParamsSchema: objectSchema(map[string]interface{}{
+				"methods": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Methods to describe (default: all)",
+				},
+			}),
+			ResultSchema: objectSchema(map[string]interface{}{
+				"version": map[string]interface{}{"type": "string"},
+				"extensions": map[string]interface{}{
+					"type": "array",
+					"items": objectSchema(map[string]interface{}{
+						"method":      map[string]interface{}{"type": "string"},
+						"description": map[string]interface{}{"type": "string"},
+						"params":      map[string]interface{}{"type": "object", "description": "JSON schema of the params"},
+						"result":      map[string]interface{}{"type": "object", "description": "JSON schema of the result"},
+					}, "method", "description", "params", "result"),
+				},
+			}, "version", "extensions"),
+		},
+	}
+
+	sort.Slice(extensions, func(i, j int) bool {
+		return extensions[i].Method < extensions[j].Method
+	})
+	return extensions
+}
+
+// capabilities returns the vibium:capabilities block.
+func (r *Router) capabilities() map[string]interface{} {
+	extensions := Extensions()
+	methods := make([]string, len(extensions))
+	for i, ext := range extensions {
+		methods[i] = ext.Method
+	}
+	return map[string]interface{}{
+		"version":         r.version,
+		"extensions":      methods,
+		"selectorEngines": selectorEngines,
+		"events":          extensionEvents,
+	}
+}
+
+// handleSessionNew answers session.new with the session the proxy already
+// created for the client, and its vibium:capabilities. The browser's own
+// session can't be replaced through the proxy.
+func (r *Router) handleSessionNew(session *BrowserSession, cmd bidiCommand) {
+	launchResult := session.LaunchResult
+	if session.shared != nil {
+		launchResult = session.shared.launchResult
+	}
+
+	capabilities := make(map[string]interface{})
+	for key, value := range launchResult.Capabilities {
+		// The browser's own endpoint would bypass the proxy
+		if key != "webSocketUrl" {
+			capabilities[key] = value
+		}
+	}
+	capabilities["vibium:capabilities"] = r.capabilities()
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{
+		"sessionId":    launchResult.SessionID,
+		"capabilities": capabilities,
+	})
+}
+
+// handleSessionStatus answers session.status with the browser's status and
+// the vibium:capabilities.
+func (r *Router) handleSessionStatus(session *BrowserSession, cmd bidiCommand) {
+	status, err := r.sessionStatus(session)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+	r.sendSuccess(session, cmd.ID, status)
+}
+
+// sessionStatus returns the browser's status with the vibium:capabilities.
+func (r *Router) sessionStatus(session *BrowserSession) (map[string]interface{}, error) {
+	resp, err := r.sendInternalCommand(session, "session.status", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	result, err := internalResult(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var status map[string]interface{}
+	if err := json.Unmarshal(result, &status); err != nil || status == nil {
+		status = map[string]interface{}{}
+	}
+	status["vibium:capabilities"] = r.capabilities()
+	return status, nil
+}
+
+// handleVibiumDescribe handles the vibium:describe command.
+func (r *Router) handleVibiumDescribe(session *BrowserSession, cmd bidiCommand) {
+	result, err := r.describeExtensions(cmd.Params)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+	r.sendSuccess(session, cmd.ID, result)
+}
+
+// describeExtensions returns the result of vibium:describe, limited to the
+// extensions in the optional methods param.
+func (r *Router) describeExtensions(params map[string]interface{}) (map[string]interface{}, error) {
+	extensions := Extensions()
+
+	if methods, ok := params["methods"].([]interface{}); ok {
+		byMethod := make(map[string]Extension, len(extensions))
+		for _, ext := range extensions {
+			byMethod[ext.Method] = ext
+		}
+		extensions = extensions[:0]
+		for _, m := range methods {
+			method, _ := m.(string)
+			ext, ok := byMethod[method]
+			if !ok {
+				return nil, &errs.UnknownCommandError{Method: method}
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+
+	return map[string]interface{}{
+		"version":    r.version,
+		"extensions": extensions,
+	}, nil
+}
+
+// isExtension returns true if the proxy supports the vibium: command.
+func isExtension(method string) bool {
+	for _, ext := range Extensions() {
+		if ext.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// objectSchema returns the JSON schema of an object with the properties.
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func selectorSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": "CSS selector"}
+}
+
+func contextSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": "Browsing context ID (default: the first tab)"}
+}
+
+func timeoutSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "number", "minimum": 0, "description": "Milliseconds to wait (default 30000)"}
+}
+
+// elementSchema describes the element vibium:find found.
+func elementSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"tag":  map[string]interface{}{"type": "string"},
+		"text": map[string]interface{}{"type": "string", "description": "Text content (first 100 characters)"},
+		"box": objectSchema(map[string]interface{}{
+			"x":      map[string]interface{}{"type": "number"},
+			"y":      map[string]interface{}{"type": "number"},
+			"width":  map[string]interface{}{"type": "number"},
+			"height": map[string]interface{}{"type": "number"},
+		}, "x", "y", "width", "height"),
+	}
+}
+
+// cookieSchema describes a cookie as vibium:cookies.* read and write it.
+func cookieSchema() map[string]interface{} {
+	return objectSchema(map[string]interface{}{
+		"name":     map[string]interface{}{"type": "string"},
+		"value":    map[string]interface{}{"type": "string"},
+		"domain":   map[string]interface{}{"type": "string"},
+		"url":      map[string]interface{}{"type": "string", "description": "Sets domain to its host, if domain is missing"},
+		"path":     map[string]interface{}{"type": "string"},
+		"httpOnly": map[string]interface{}{"type": "boolean"},
+		"secure":   map[string]interface{}{"type": "boolean"},
+		"sameSite": map[string]interface{}{"type": "string", "enum": []string{"strict", "lax", "none"}},
+		"expiry":   map[string]interface{}{"type": "integer", "description": "Unix seconds, 0 for a session cookie"},
+	}, "name", "value")
+}
+
+// cookieFilterSchema describes the cookie filter of vibium:cookies.get and
+// vibium:cookies.delete.
+func cookieFilterSchema() map[string]interface{} {
+	return objectSchema(map[string]interface{}{
+		"name":   map[string]interface{}{"type": "string"},
+		"domain": map[string]interface{}{"type": "string"},
+		"path":   map[string]interface{}{"type": "string"},
+	})
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// limitCheckInterval is how often sessions are checked against their limits.
+const limitCheckInterval = time.Second
+
+// memoryCheckInterval is how often the memory of a session's browser is
+// read. Walking the process tree reads /proc for every process, so this is
+// less often than limitCheckInterval.
+const memoryCheckInterval = 10 * time.Second
+
+// SessionLimits bounds the time and memory of each client session. The
+// number of concurrent sessions is limited with WithPool.
+type SessionLimits struct {
+	IdleTimeout time.Duration // Close sessions whose client sent no command for this long (0 = never)
+	MaxLifetime time.Duration // Close sessions this long after they started (0 = never)
+	MaxMemory   uint64        // Close sessions whose browser uses more resident memory, in bytes (0 = unlimited)
+}
+
+// enabled returns true if any limit is set.
+func (l SessionLimits) enabled() bool {
+	return l.IdleTimeout > 0 || l.MaxLifetime > 0 || l.MaxMemory > 0
+}
+
+// WithSessionLimits closes sessions that exceed the limits. The client gets
+// an error message saying which limit was hit, then a close frame with the
+// same reason. MaxMemory counts chromedriver, the browser and its helper
+// processes; it only works on Linux and doesn't apply to sessions in a
+// shared browser, whose memory belongs to all its clients.
+func WithSessionLimits(limits SessionLimits) RouterOption {
+	return func(r *Router) {
+		r.limits = limits
+	}
+}
+
+// watchSession closes the session when it exceeds a limit. It returns
+// when the session closes.
+func (r *Router) watchSession(session *BrowserSession) {
+	ticker := time.NewTicker(limitCheckInterval)
+	defer ticker.Stop()
+
+	var memoryChecked time.Time
+	for {
+		select {
+		case <-session.stopChan:
+			return
+		case now := <-ticker.C:
+			kind, message := r.checkLimits(session, now, &memoryChecked)
+			if kind != "" {
+				r.limitSession(session, kind, message)
+				return
+			}
+		}
+	}
+}
+
+// checkLimits returns the kind of limit the session exceeds and a message
+// for its client, or "" if it's within its limits.
+func (r *Router) checkLimits(session *BrowserSession, now time.Time, memoryChecked *time.Time) (kind, message string) {
+	if max := r.limits.MaxLifetime; max > 0 && now.Sub(session.started) >= max {
+		return "lifetime", fmt.Sprintf("session closed after reaching its maximum lifetime of %s", max)
+	}
+
+	// A detached session is closed by the keep-alive instead
+	if idle := r.limits.IdleTimeout; idle > 0 && !session.isDetached() && now.Sub(session.lastActive()) >= idle {
+		return "idle", fmt.Sprintf("session closed after %s without commands", idle)
+	}
+
+	if max := r.limits.MaxMemory; max > 0 && session.shared == nil && now.Sub(*memoryChecked) >= memoryCheckInterval {
+		*memoryChecked = now
+		used, err := session.LaunchResult.MemoryUsage()
+		if err != nil {
+			// The browser is exiting, which closes the session anyway
+			return "", ""
+		}
+		if used > max {
+			return "memory", fmt.Sprintf("session closed because its browser uses %d MB, more than the limit of %d MB", used>>20, max>>20)
+		}
+	}
+	return "", ""
+}
+
+// limitSession closes a session that exceeded a limit. Its client gets an
+// error message without a command ID, as the BiDi spec sends for errors
+// that aren't about a command, and a policy violation close frame.
+func (r *Router) limitSession(session *BrowserSession, kind, message string) {
+	client := session.client()
+	fmt.Printf("[router] Closing browser session for client %d: %s\n", client.ID, message)
+	r.metrics.sessionLimited(kind)
+
+	session.send(newBidiError(nil, errors.New(message)).marshal())
+
+	r.sessions.CompareAndDelete(client.ID, session)
+	r.closeSession(session)
+	client.CloseWithReason(websocket.ClosePolicyViolation, message)
+}
+
+// touch records client activity for the idle timeout.
+func (session *BrowserSession) touch() {
+	session.mu.Lock()
+	session.lastActivity = time.Now()
+	session.mu.Unlock()
+}
+
+// lastActive returns when the client last sent a command, or when the
+// session started or was resumed.
+func (session *BrowserSession) lastActive() time.Time {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.lastActivity
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vibium/clicker/internal/browser"
+)
+
+func TestCheckLimitsTime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		limits   SessionLimits
+		started  time.Duration // Before now
+		active   time.Duration // Before now
+		detached bool
+		want     string
+	}{
+		{"no limits", SessionLimits{}, time.Hour, time.Hour, false, ""},
+		{"within lifetime", SessionLimits{MaxLifetime: time.Hour}, time.Minute, 0, false, ""},
+		{"lifetime", SessionLimits{MaxLifetime: time.Hour}, time.Hour, 0, false, "lifetime"},
+		{"within idle", SessionLimits{IdleTimeout: time.Minute}, time.Hour, time.Second, false, ""},
+		{"idle", SessionLimits{IdleTimeout: time.Minute}, time.Hour, time.Minute, false, "idle"},
+		{"idle while detached", SessionLimits{IdleTimeout: time.Minute}, time.Hour, time.Hour, true, ""},
+		{"lifetime while detached", SessionLimits{MaxLifetime: time.Hour}, time.Hour, 0, true, "lifetime"},
+		{"lifetime first", SessionLimits{IdleTimeout: time.Minute, MaxLifetime: time.Hour}, time.Hour, time.Hour, false, "lifetime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Router{limits: tt.limits}
+			session := &BrowserSession{
+				started:      now.Add(-tt.started),
+				lastActivity: now.Add(-tt.active),
+				detached:     tt.detached,
+			}
+			var memoryChecked time.Time
+			kind, message := r.checkLimits(session, now, &memoryChecked)
+			if kind != tt.want {
+				t.Fatalf("checkLimits() = %q (%s), want %q", kind, message, tt.want)
+			}
+			if kind != "" && message == "" {
+				t.Error("checkLimits() gave no message")
+			}
+		})
+	}
+}
+
+func TestCheckLimitsMemory(t *testing.T) {
+	if !browser.MemoryUsageSupported {
+		t.Skip("memory usage isn't supported on this platform")
+	}
+
+	// A process standing in for chromedriver, with a child of its own
+	cmd := exec.Command("sh", "-c", "sleep 30 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	session := &BrowserSession{
+		started:      time.Now(),
+		lastActivity: time.Now(),
+		LaunchResult: &browser.LaunchResult{ChromedriverCmd: cmd},
+	}
+
+	now := time.Now()
+	r := &Router{limits: SessionLimits{MaxMemory: 1 << 40}}
+	var memoryChecked time.Time
+	if kind, message := r.checkLimits(session, now, &memoryChecked); kind != "" {
+		t.Fatalf("checkLimits() = %q (%s), want within limits", kind, message)
+	}
+	if !memoryChecked.Equal(now) {
+		t.Error("checkLimits() didn't record the memory check")
+	}
+
+	// Memory is only read every memoryCheckInterval
+	r.limits.MaxMemory = 1
+	if kind, _ := r.checkLimits(session, now.Add(limitCheckInterval), &memoryChecked); kind != "" {
+		t.Fatalf("checkLimits() = %q before memoryCheckInterval, want within limits", kind)
+	}
+	kind, message := r.checkLimits(session, now.Add(memoryCheckInterval), &memoryChecked)
+	if kind != "memory" {
+		t.Fatalf("checkLimits() = %q (%s), want memory", kind, message)
+	}
+
+	// Sessions in a shared browser aren't limited
+	session.shared = &sharedBrowser{}
+	memoryChecked = time.Time{}
+	if kind, _ := r.checkLimits(session, now, &memoryChecked); kind != "" {
+		t.Fatalf("checkLimits() = %q in a shared browser, want within limits", kind)
+	}
+}
+
+func TestCutCloseReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+	}{
+		{"short", "session closed"},
+		{"ascii", strings.Repeat("a", 200)},
+		{"multibyte", strings.Repeat("é", 100)},
+		{"boundary", strings.Repeat("a", maxCloseReason-1) + "€"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cutCloseReason(tt.reason)
+			if len(got) > maxCloseReason {
+				t.Errorf("len = %d, want at most %d", len(got), maxCloseReason)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("%q isn't valid UTF-8", got)
+			}
+			if !strings.HasPrefix(tt.reason, got) {
+				t.Errorf("%q isn't a prefix of the reason", got)
+			}
+			if len(tt.reason) <= maxCloseReason && got != tt.reason {
+				t.Errorf("cut %q, which fits", tt.reason)
+			}
+			if len(tt.reason) > maxCloseReason && len(got) <= maxCloseReason-utf8.UTFMax {
+				t.Errorf("cut to %d bytes, more than a rune short of %d", len(got), maxCloseReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// launchBuckets are the upper bounds, in seconds, of the browser launch
+// latency histogram.
+var launchBuckets = []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32}
+
+// maxMethodLabels caps the distinct methods counted by name, so a client
+// sending made-up methods can't grow the metrics without bound. Further
+// methods are counted as "other".
+const maxMethodLabels = 200
+
+// metrics counts what the router does, for the /metrics endpoint.
+type metrics struct {
+	mu               sync.Mutex
+	sessionsStarted  uint64
+	sessionsRejected uint64
+	launchFailures   uint64
+	launchCounts     []uint64 // Per bucket of launchBuckets, not cumulative
+	launchCount      uint64
+	launchSum        float64
+	commands         map[string]uint64 // Client commands by method
+	vibiumErrors     map[string]uint64 // vibium: commands answered with an error, by method
+	sessionsLimited  map[string]uint64 // Sessions closed for exceeding a limit, by limit
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		launchCounts:    make([]uint64, len(launchBuckets)),
+		commands:        make(map[string]uint64),
+		vibiumErrors:    make(map[string]uint64),
+		sessionsLimited: make(map[string]uint64),
+	}
+}
+
+// sessionStarted counts a client that got a browser session.
+func (m *metrics) sessionStarted() {
+	m.mu.Lock()
+	m.sessionsStarted++
+	m.mu.Unlock()
+}
+
+// sessionRejected counts a client that couldn't get a browser session.
+func (m *metrics) sessionRejected() {
+	m.mu.Lock()
+	m.sessionsRejected++
+	m.mu.Unlock()
+}
+
+// sessionLimited counts a session closed for exceeding a limit of
+// WithSessionLimits.
+func (m *metrics) sessionLimited(limit string) {
+	m.mu.Lock()
+	m.sessionsLimited[limit]++
+	m.mu.Unlock()
+}
+
+// browserLaunched records how long launching and connecting to a browser
+// took.
+func (m *metrics) browserLaunched(d time.Duration) {
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, bound := range launchBuckets {
+		if seconds <= bound {
+			m.launchCounts[i]++
+			break
+		}
+	}
+	m.launchCount++
+	m.launchSum += seconds
+}
+
+// launchFailed counts a browser that failed to launch.
+func (m *metrics) launchFailed() {
+	m.mu.Lock()
+	m.launchFailures++
+	m.mu.Unlock()
+}
+
+// command counts a client command.
+func (m *metrics) command(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[methodLabel(m.commands, method)]++
+}
+
+// vibiumError counts a vibium: command answered with an error.
+func (m *metrics) vibiumError(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vibiumErrors[methodLabel(m.vibiumErrors, method)]++
+}
+
+// methodLabel returns the label to count method under in counts.
+func methodLabel(counts map[string]uint64, method string) string {
+	if _, ok := counts[method]; ok || len(counts) < maxMethodLabels {
+		return method
+	}
+	return "other"
+}
+
+// gauges are the metrics read from the router's state when scraped.
+type gauges struct {
+	activeSessions int
+	idleBrowsers   int
+	sharedBrowsers int
+	maxSessions    int
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer, g gauges) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetric(w, "clicker_sessions_active", "gauge", "Client sessions with a browser.", float64(g.activeSessions))
+	writeMetric(w, "clicker_sessions_max", "gauge", "Maximum concurrent client sessions (0 = unlimited).", float64(g.maxSessions))
+	writeMetric(w, "clicker_pool_idle_browsers", "gauge", "Launched browsers waiting for a client.", float64(g.idleBrowsers))
+	writeMetric(w, "clicker_shared_browsers", "gauge", "Running browsers shared between clients.", float64(g.sharedBrowsers))
+	writeMetric(w, "clicker_sessions_started_total", "counter", "Client sessions started.", float64(m.sessionsStarted))
+	writeMetric(w, "clicker_sessions_rejected_total", "counter", "Clients that couldn't get a browser session.", float64(m.sessionsRejected))
+	writeMetric(w, "clicker_browser_launch_failures_total", "counter", "Browsers that failed to launch.", float64(m.launchFailures))
+
+	fmt.Fprintln(w, "# HELP clicker_browser_launch_seconds Time to launch and connect to a browser.")
+	fmt.Fprintln(w, "# TYPE clicker_browser_launch_seconds histogram")
+	var cumulative uint64
+	for i, bound := range launchBuckets {
+		cumulative += m.launchCounts[i]
+		fmt.Fprintf(w, "clicker_browser_launch_seconds_bucket{le=\"%g\"} %d\n", bound, cumulative)
+	}
+	fmt.Fprintf(w, "clicker_browser_launch_seconds_bucket{le=\"+Inf\"} %d\n", m.launchCount)
+	fmt.Fprintf(w, "clicker_browser_launch_seconds_sum %g\n", m.launchSum)
+	fmt.Fprintf(w, "clicker_browser_launch_seconds_count %d\n", m.launchCount)
+
+	writeCounts(w, "clicker_commands_total", "method", "Client commands by method.", m.commands)
+	writeCounts(w, "clicker_vibium_errors_total", "method", "vibium: extension commands that returned an error, by method.", m.vibiumErrors)
+	writeCounts(w, "clicker_sessions_limited_total", "limit", "Client sessions closed for exceeding a limit, by limit.", m.sessionsLimited)
+}
+
+// writeMetric writes a metric without labels.
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
+
+// writeCounts writes a counter with one label, sorted by label value.
+func writeCounts(w io.Writer, name, label, help string, counts map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, escapeLabel(value), counts[value])
+	}
+}
+
+// escapeLabel escapes a label value for the text exposition format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	errs "github.com/vibium/clicker/internal/errors"
+)
+
+// observerCommands are the commands an observer may send. They only read
+// the browser's state, so they can't disturb the controlling client.
+var observerCommands = map[string]bool{
+	"session.status":                    true,
+	"session.subscribe":                 true,
+	"session.unsubscribe":               true,
+	"browsingContext.getTree":           true,
+	"browsingContext.captureScreenshot": true,
+	"browsingContext.locateNodes":       true,
+	"script.getRealms":                  true,
+	"storage.getCookies":                true,
+	"browser.getUserContexts":           true,
+	"browser.getClientWindows":          true,
+	"vibium:describe":                   true,
+}
+
+// observerQueueSize bounds the messages waiting to be written to an
+// observer. An observer that falls further behind is disconnected, so a slow
+// observer can't hold up the controlling client.
+const observerQueueSize = 1024
+
+// observer is a client watching another client's session read-only.
+// Messages are written by its own goroutine from a bounded queue.
+type observer struct {
+	client   *ClientConn
+	events   map[string]bool // Events the observer subscribed to itself
+	queue    chan string
+	done     chan struct{} // Closed by stop
+	stopOnce sync.Once
+}
+
+// newObserver starts writing queued messages to an observing client.
+func newObserver(client *ClientConn) *observer {
+	o := &observer{
+		client: client,
+		events: make(map[string]bool),
+		queue:  make(chan string, observerQueueSize),
+		done:   make(chan struct{}),
+	}
+	go o.write()
+	return o
+}
+
+// write sends queued messages to the client until stop is called or
+// sending fails.
+func (o *observer) write() {
+	for {
+		select {
+		case msg := <-o.queue:
+			if err := o.client.Send(msg); err != nil {
+				return
+			}
+		case <-o.done:
+			return
+		}
+	}
+}
+
+// send queues a message for the observer without blocking. An observer
+// whose queue is full is disconnected.
+func (o *observer) send(msg string) {
+	select {
+	case o.queue <- msg:
+	case <-o.done:
+	default:
+		o.stop()
+		fmt.Printf("[router] Disconnecting observer %d: it fell %d messages behind\n", o.client.ID, observerQueueSize)
+		go o.client.CloseWithReason(websocket.ClosePolicyViolation, "observer fell too far behind")
+	}
+}
+
+// stop stops writing to the observer.
+func (o *observer) stop() {
+	o.stopOnce.Do(func() { close(o.done) })
+}
+
+// observeSession attaches a client connecting with ?observe=<client id> to
+// that client's session as an observer. Observers get every message the
+// controlling client gets, plus the events they subscribe to, and may send
+// the read-only observerCommands. Their commands get internal IDs, so
+// responses only go to them.
+func (r *Router) observeSession(client *ClientConn) {
+	id, err := strconv.ParseUint(client.Observe, 10, 64)
+	if err != nil {
+		r.rejectObserver(client, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid client id %q to observe", client.Observe)})
+		return
+	}
+	sessionVal, ok := r.sessions.Load(id)
+	if !ok {
+		r.rejectObserver(client, &errs.InvalidArgumentError{Message: fmt.Sprintf("no session for client %d to observe", id)})
+		return
+	}
+	session := sessionVal.(*BrowserSession)
+
+	session.mu.Lock()
+	closed := session.closed
+	session.mu.Unlock()
+	if closed {
+		r.rejectObserver(client, &errs.InvalidArgumentError{Message: fmt.Sprintf("no session for client %d to observe", id)})
+		return
+	}
+
+	commands := make([]string, 0, len(observerCommands))
+	for method := range observerCommands {
+		commands = append(commands, method)
+	}
+	sort.Strings(commands)
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":   "event",
+		"method": "vibium:observing",
+		"params": map[string]interface{}{
+			"clientId": id,
+			"commands": commands,
+		},
+	})
+	client.Send(string(data))
+
+	// Registered after the vibium:observing event, so it comes first
+	session.clientMu.Lock()
+	if session.observers == nil {
+		session.observers = make(map[uint64]*observer)
+	}
+	session.observers[client.ID] = newObserver(client)
+	session.clientMu.Unlock()
+	r.observed.Store(client.ID, session)
+
+	fmt.Printf("[router] Client %d is observing the browser session of client %d\n", client.ID, id)
+}
+
+// rejectObserver tells a client it can't observe and disconnects it.
+func (r *Router) rejectObserver(client *ClientConn, err error) {
+	fmt.Printf("[router] Client %d can't observe: %v\n", client.ID, err)
+	resp := newBidiError(nil, err)
+	resp.Message = "failed to observe browser session: " + resp.Message
+	client.Send(resp.marshal())
+	client.Close()
+}
+
+// observerMessage handles a command from an observer. Returns false if the
+// client isn't an observer.
+func (r *Router) observerMessage(client *ClientConn, msg string) bool {
+	sessionVal, ok := r.observed.Load(client.ID)
+	if !ok {
+		return false
+	}
+	session := sessionVal.(*BrowserSession)
+
+	var cmd bidiCommand
+	if err := json.Unmarshal([]byte(msg), &cmd); err != nil {
+		fmt.Printf("[router] Dropping unparseable message from observer %d: %v\n", client.ID, err)
+		return true
+	}
+	if cmd.Params == nil {
+		cmd.Params = map[string]interface{}{}
+	}
+
+	result, err := r.observerCommand(session, client, cmd)
+	if err != nil {
+		client.Send(newBidiError(&cmd.ID, err).marshal())
+		return true
+	}
+	data, _ := json.Marshal(bidiResponse{ID: cmd.ID, Type: "success", Result: result})
+	client.Send(string(data))
+	return true
+}
+
+// observerCommand runs a command of an observer and returns its result.
+func (r *Router) observerCommand(session *BrowserSession, client *ClientConn, cmd bidiCommand) (interface{}, error) {
+	if !observerCommands[cmd.Method] {
+		if strings.HasPrefix(cmd.Method, "vibium:") && !isExtension(cmd.Method) {
+			return nil, &errs.UnknownCommandError{Method: cmd.Method}
+		}
+		return nil, &errs.UnsupportedOperationError{Method: cmd.Method, Reason: "observers can only send read-only commands"}
+	}
+
+	switch cmd.Method {
+	case "session.status":
+		return r.sessionStatus(session)
+	case "vibium:describe":
+		return r.describeExtensions(cmd.Params)
+	case "session.unsubscribe":
+		session.untrackObserverSubscription(client, cmd)
+		return map[string]interface{}{}, nil
+	case "session.subscribe":
+		// The browser sends the events to the proxy, which filters them
+		// per client
+		session.trackObserverSubscription(client, cmd)
+	}
+
+	// Observers of a shared browser see only the session's user context
+	if session.shared != nil {
+		if err := session.shared.scope(session, cmd.Method, cmd.Params); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := r.sendInternalCommand(session, cmd.Method, cmd.Params)
+	if err != nil {
+		return nil, err
+	}
+	return internalResult(resp)
+}
+
+// trackObserverSubscription records the events an observer subscribes to.
+func (session *BrowserSession) trackObserverSubscription(client *ClientConn, cmd bidiCommand) {
+	events, _ := cmd.Params["events"].([]interface{})
+
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	o := session.observers[client.ID]
+	if o == nil {
+		return
+	}
+	for _, event := range events {
+		if name, ok := event.(string); ok {
+			o.events[name] = true
+		}
+	}
+}
+
+// untrackObserverSubscription forgets events an observer unsubscribed from.
+// The browser stays subscribed, as the controlling client may need them.
+func (session *BrowserSession) untrackObserverSubscription(client *ClientConn, cmd bidiCommand) {
+	events, _ := cmd.Params["events"].([]interface{})
+
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	o := session.observers[client.ID]
+	if o == nil {
+		return
+	}
+	for _, event := range events {
+		if name, ok := event.(string); ok {
+			delete(o.events, name)
+		}
+	}
+}
+
+// subscribed returns true if the observer subscribed to the event or its
+// module.
+func (o *observer) subscribed(method string) bool {
+	module, _, _ := strings.Cut(method, ".")
+	return o.events[method] || o.events[module]
+}
+
+// observerOnlyEvent returns true if only observers subscribed to the event,
+// so the controlling client must not get it.
+func (session *BrowserSession) observerOnlyEvent(method string) bool {
+	if session.subscribedByClient(method) {
+		return false
+	}
+
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	for _, o := range session.observers {
+		if o.subscribed(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendToObservers sends an event only the observers subscribed to.
+func (session *BrowserSession) sendToObservers(msg, method string) {
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	for _, o := range session.observers {
+		if o.subscribed(method) {
+			o.send(msg)
+		}
+	}
+}
+
+// sendObserverOnlyEvent sends an event from the browser to the observers if
+// only they subscribed to it. Returns true if the event was theirs.
+func (session *BrowserSession) sendObserverOnlyEvent(msg string) bool {
+	if session.observerCount() == 0 {
+		return false
+	}
+	method := eventMethod(msg)
+	if method == "" || !session.observerOnlyEvent(method) {
+		return false
+	}
+	session.sendToObservers(msg, method)
+	return true
+}
+
+// mirror sends a message for the controlling client to the observers. The
+// caller holds clientMu.
+func (session *BrowserSession) mirror(msg string) {
+	for _, o := range session.observers {
+		o.send(msg)
+	}
+}
+
+// eventMethod returns the method of an event message, or "" for other
+// messages.
+func eventMethod(msg string) string {
+	var event struct {
+		Type   string `json:"type"`
+		Method string `json:"method"`
+	}
+	if json.Unmarshal([]byte(msg), &event) != nil || event.Type != "event" {
+		return ""
+	}
+	return event.Method
+}
+
+// observerDisconnected detaches an observer from the session it watched.
+// Returns false if the client wasn't an observer.
+func (r *Router) observerDisconnected(client *ClientConn) bool {
+	sessionVal, ok := r.observed.LoadAndDelete(client.ID)
+	if !ok {
+		return false
+	}
+	session := sessionVal.(*BrowserSession)
+
+	session.clientMu.Lock()
+	if o := session.observers[client.ID]; o != nil {
+		o.stop()
+		delete(session.observers, client.ID)
+	}
+	session.clientMu.Unlock()
+	fmt.Printf("[router] Client %d stopped observing\n", client.ID)
+	return true
+}
+
+// closeObservers disconnects the observers of a closed session.
+func (r *Router) closeObservers(session *BrowserSession) {
+	session.clientMu.Lock()
+	observers := session.observers
+	session.observers = nil
+	session.clientMu.Unlock()
+
+	for id, o := range observers {
+		r.observed.Delete(id)
+		o.stop()
+		o.client.CloseWithReason(websocket.CloseNormalClosure, "observed browser session ended")
+	}
+}
+
+// observerCount returns the number of observers of the session.
+func (session *BrowserSession) observerCount() int {
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	return len(session.observers)
+}
@@ -0,0 +1,289 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vibium/clicker/internal/bidi"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/policy"
+)
+
+// policyEvents are the events the router subscribes to to enforce the URL
+// policy. They are only forwarded to clients that subscribed to them too.
+var policyEvents = []string{"network.beforeRequestSent", "browsingContext.navigationStarted"}
+
+// WithPolicy restricts the URLs browsers may navigate to. Navigation
+// commands to blocked URLs fail, and navigations started by the page are
+// stopped and reported to the client with a vibium:navigationBlocked event.
+func WithPolicy(p *policy.Policy) RouterOption {
+	return func(r *Router) {
+		r.policy = p
+	}
+}
+
+// enforcePolicy subscribes to the policy events and pauses every request,
+// so document requests can be checked in handlePolicyEvent. It must run
+// before routing starts. Returns the intercept ID.
+func (r *Router) enforcePolicy(client *bidi.Client) (string, error) {
+	if err := client.Subscribe(policyEvents, nil); err != nil {
+		return "", err
+	}
+	return client.AddIntercept([]string{"beforeRequestSent"}, nil)
+}
+
+// checkNavigate answers a browsingContext.navigate command with an error if
+// the policy blocks its URL. Returns true if the command was answered.
+func (r *Router) checkNavigate(session *BrowserSession, cmd bidiCommand) bool {
+	rawURL, _ := cmd.Params["url"].(string)
+	err := r.policy.Check(rawURL)
+	if err == nil {
+		return false
+	}
+
+	fmt.Printf("[router] Blocked navigation to %s for client %d\n", rawURL, session.client().ID)
+	r.sendError(session, cmd.ID, err)
+	return true
+}
+
+// trackSubscription records the events a client subscribes to, so policy
+// events are forwarded to it.
+func (session *BrowserSession) trackSubscription(cmd bidiCommand) {
+	events, _ := cmd.Params["events"].([]interface{})
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.clientEvents == nil {
+		session.clientEvents = make(map[string]bool)
+	}
+	for _, event := range events {
+		if name, ok := event.(string); ok {
+			session.clientEvents[name] = true
+		}
+	}
+}
+
+// untrackSubscription forgets events a client unsubscribed from.
+func (session *BrowserSession) untrackSubscription(cmd bidiCommand) {
+	events, _ := cmd.Params["events"].([]interface{})
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for _, event := range events {
+		if name, ok := event.(string); ok {
+			delete(session.clientEvents, name)
+		}
+	}
+}
+
+// subscribedByClient returns true if the client subscribed to the event or
+// its module.
+func (session *BrowserSession) subscribedByClient(method string) bool {
+	module, _, _ := strings.Cut(method, ".")
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.clientEvents[method] || session.clientEvents[module]
+}
+
+// policyModules are the modules of policyEvents. Events of these modules are
+// only forwarded to clients that subscribed to them, see handlePolicyEvent.
+var policyModules = map[string]bool{"network": true, "browsingContext": true}
+
+// policyRequestCommands are the commands that resolve a paused request.
+// Clients may not send them for requests the policy resolves.
+var policyRequestCommands = map[string]bool{
+	"network.continueRequest":  true,
+	"network.continueResponse": true,
+	"network.continueWithAuth": true,
+	"network.failRequest":      true,
+	"network.provideResponse":  true,
+}
+
+// enforcer returns the session's policy enforcer, creating it on first use.
+func (r *Router) enforcer(session *BrowserSession) *policy.Enforcer {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.policyEnforcer == nil {
+		session.policyEnforcer = &policy.Enforcer{
+			Policy:    r.policy,
+			Intercept: session.policyIntercept,
+			Send: func(method string, params map[string]interface{}) error {
+				resp, err := r.sendInternalCommand(session, method, params)
+				if err == nil {
+					err = responseError(resp)
+				}
+				// Once resolved, the browser rejects commands for the request
+				if request, ok := params["request"].(string); ok {
+					session.releasePolicyRequest(request)
+				}
+				return err
+			},
+			OnViolation: func(context string, violation *errs.PolicyViolationError) {
+				r.reportViolation(session, context, violation)
+			},
+		}
+	}
+	return session.policyEnforcer
+}
+
+// handlePolicyEvent enforces the policy on a message from the browser.
+// Returns true if the message must not be forwarded to the client.
+func (r *Router) handlePolicyEvent(session *BrowserSession, msg string) bool {
+	var event struct {
+		Type   string          `json:"type"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(msg), &event); err != nil || event.Type != "event" {
+		return false
+	}
+
+	switch event.Method {
+	case "network.beforeRequestSent":
+		var params bidi.BeforeRequestSentParams
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return false
+		}
+		// Hold the request before the enforcer may resolve it
+		request := params.Request.Request
+		session.holdPolicyRequest(request)
+		if !r.enforcer(session).HandleRequest(&params) {
+			session.releasePolicyRequest(request)
+		}
+
+	case "browsingContext.navigationStarted":
+		var params bidi.NavigationStartedParams
+		if err := json.Unmarshal(event.Params, &params); err != nil {
+			return false
+		}
+		r.enforcer(session).HandleNavigation(&params)
+	}
+
+	// The router's subscriptions outlive the client's, see handleUnsubscribe.
+	// Events for observers only are forwarded by the caller.
+	module, _, _ := strings.Cut(event.Method, ".")
+	if !policyModules[module] || session.subscribedByClient(event.Method) {
+		return false
+	}
+	return !session.observerOnlyEvent(event.Method)
+}
+
+// holdPolicyRequest records that the policy resolves a paused request.
+func (session *BrowserSession) holdPolicyRequest(request string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.policyRequests == nil {
+		session.policyRequests = make(map[string]bool)
+	}
+	session.policyRequests[request] = true
+}
+
+// releasePolicyRequest forgets a request recorded by holdPolicyRequest.
+func (session *BrowserSession) releasePolicyRequest(request string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	delete(session.policyRequests, request)
+}
+
+// checkPolicyCommand rejects client commands that would undo the policy:
+// resolving a request the policy resolves, or removing its intercept.
+func (session *BrowserSession) checkPolicyCommand(cmd bidiCommand) error {
+	if cmd.Method == "network.removeIntercept" {
+		if intercept, _ := cmd.Params["intercept"].(string); intercept == session.policyIntercept {
+			return &commandError{"no such intercept", fmt.Sprintf("intercept %s not found", intercept)}
+		}
+		return nil
+	}
+	if !policyRequestCommands[cmd.Method] {
+		return nil
+	}
+
+	request, _ := cmd.Params["request"].(string)
+	session.mu.Lock()
+	held := session.policyRequests[request]
+	session.mu.Unlock()
+	if held {
+		return &commandError{"no such request", fmt.Sprintf("request %s is handled by the URL policy", request)}
+	}
+	return nil
+}
+
+// handleUnsubscribe answers a session.unsubscribe command of a client with
+// its own browser. The router's subscriptions to policyEvents are in the
+// same BiDi session, so unsubscribing from them or their modules in the
+// browser would stop the policy checks and leave requests paused. Those are
+// only forgotten (events are then no longer forwarded, see
+// handlePolicyEvent), and the rest is sent to the browser. Returns true if
+// the command was answered.
+func (r *Router) handleUnsubscribe(session *BrowserSession, cmd bidiCommand) bool {
+	events, _ := cmd.Params["events"].([]interface{})
+
+	var local bool
+	var rest []interface{}
+	for _, event := range events {
+		name, _ := event.(string)
+		if policyModules[name] || isPolicyEvent(name) {
+			local = true
+		} else {
+			rest = append(rest, event)
+		}
+	}
+	if !local {
+		return false
+	}
+
+	session.untrackSubscription(cmd)
+	if len(rest) == 0 {
+		r.sendSuccess(session, cmd.ID, map[string]interface{}{})
+		return true
+	}
+
+	params := make(map[string]interface{}, len(cmd.Params))
+	for k, v := range cmd.Params {
+		params[k] = v
+	}
+	params["events"] = rest
+	go func() {
+		resp, err := r.sendInternalCommand(session, "session.unsubscribe", params)
+		if err == nil {
+			err = responseError(resp)
+		}
+		if err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		r.sendSuccess(session, cmd.ID, map[string]interface{}{})
+	}()
+	return true
+}
+
+// isPolicyEvent returns true if name is one of policyEvents.
+func isPolicyEvent(name string) bool {
+	for _, event := range policyEvents {
+		if event == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reportViolation sends a vibium:navigationBlocked event to the client.
+func (r *Router) reportViolation(session *BrowserSession, context string, violation *errs.PolicyViolationError) {
+	fmt.Printf("[router] Blocked navigation to %s for client %d: %s\n", violation.URL, session.client().ID, violation.Reason)
+
+	event := map[string]interface{}{
+		"type":   "event",
+		"method": "vibium:navigationBlocked",
+		"params": map[string]interface{}{
+			"context": context,
+			"url":     violation.URL,
+			"reason":  violation.Reason,
+			"message": violation.Error(),
+		},
+	}
+	data, _ := json.Marshal(event)
+	session.send(string(data))
+}
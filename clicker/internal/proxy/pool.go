@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/browser"
+)
+
+// PoolOptions configures the browser pool of a Router.
+type PoolOptions struct {
+	Size        int           // Browsers kept launched and ready for new clients (0 = launch on connect)
+	MaxSessions int           // Maximum concurrent client sessions (0 = unlimited)
+	WaitTimeout time.Duration // How long a client waits for a session at capacity (0 = fail immediately)
+}
+
+// WithPool keeps browsers launched and set up ahead of time, so connecting
+// clients don't wait for Chrome to start, and limits concurrent sessions.
+// Clients connecting at capacity wait for a session to end.
+func WithPool(opts PoolOptions) RouterOption {
+	return func(r *Router) {
+		r.poolOpts = opts
+	}
+}
+
+// warmBrowser is a launched browser, set up for a client (init scripts,
+// storage state, URL policy) but not yet handed to one.
+type warmBrowser struct {
+	launchResult    *browser.LaunchResult
+	bidiConn        *bidi.Connection
+	bidiClient      *bidi.Client
+	policyIntercept string
+}
+
+// close closes the connection and the browser.
+func (b *warmBrowser) close() {
+	b.bidiConn.Close()
+	b.launchResult.Close()
+}
+
+// browserPool hands out warm browsers and limits concurrent sessions.
+// Browsers are never reused: pages may have left cookies, storage or cache
+// in the profile, so a browser is closed when its session ends and a fresh
+// one is launched in its place.
+type browserPool struct {
+	opts   PoolOptions
+	launch func() (*warmBrowser, error)
+	slots  chan struct{} // One per active session, nil if unlimited
+
+	mu      sync.Mutex
+	idle    []*warmBrowser
+	warming int // Browsers being launched for idle
+	active  int // Sessions between acquire and release
+	closed  bool
+}
+
+// newBrowserPool creates a pool and starts warming browsers.
+func newBrowserPool(opts PoolOptions, launch func() (*warmBrowser, error)) *browserPool {
+	p := &browserPool{
+		opts:   opts,
+		launch: launch,
+	}
+	if opts.MaxSessions > 0 {
+		p.slots = make(chan struct{}, opts.MaxSessions)
+	}
+	p.refill()
+	return p
+}
+
+// acquire returns a browser for a new session, waiting for a free slot if
+// the pool is at capacity. Every successful acquire must be followed by a
+// release when the session ends.
+func (p *browserPool) acquire() (*warmBrowser, error) {
+	if err := p.reserve(); err != nil {
+		return nil, err
+	}
+
+	b := p.takeIdle()
+	p.refill()
+
+	if b == nil {
+		var err error
+		if b, err = p.launch(); err != nil {
+			p.release()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// reserve starts a session without a browser from the pool, e.g. one in a
+// shared browser, waiting for a free slot if the pool is at capacity. Every
+// successful reserve must be followed by a release when the session ends.
+func (p *browserPool) reserve() error {
+	if err := p.takeSlot(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.freeSlot()
+		return fmt.Errorf("server is shutting down")
+	}
+	p.active++
+	return nil
+}
+
+// release ends a session started with acquire or reserve. The caller closes
+// its browser.
+func (p *browserPool) release() {
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+
+	p.freeSlot()
+	p.refill()
+}
+
+// stats returns the number of active sessions and idle browsers, and
+// whether the pool is closed.
+func (p *browserPool) stats() (active, idle int, closed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active, len(p.idle), p.closed
+}
+
+// takeSlot reserves a session slot, waiting up to WaitTimeout at capacity.
+func (p *browserPool) takeSlot() error {
+	if p.slots == nil {
+		return nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if p.opts.WaitTimeout <= 0 {
+		return fmt.Errorf("all %d browser sessions are in use", p.opts.MaxSessions)
+	}
+
+	fmt.Printf("[pool] All %d browser sessions in use, waiting up to %s...\n", p.opts.MaxSessions, p.opts.WaitTimeout)
+	timer := time.NewTimer(p.opts.WaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("all %d browser sessions are in use and none ended within %s", p.opts.MaxSessions, p.opts.WaitTimeout)
+	}
+}
+
+// freeSlot frees a slot reserved with takeSlot.
+func (p *browserPool) freeSlot() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// takeIdle returns the oldest idle browser that is still alive, or nil.
+func (p *browserPool) takeIdle() *warmBrowser {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		b := p.idle[0]
+		p.idle = p.idle[1:]
+		p.mu.Unlock()
+
+		if _, err := b.bidiClient.SessionStatus(); err != nil {
+			fmt.Printf("[pool] Discarding dead browser: %v\n", err)
+			go b.close()
+			continue
+		}
+		return b
+	}
+}
+
+// refill launches browsers in the background until Size are idle, without
+// exceeding MaxSessions browsers in total.
+func (p *browserPool) refill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && len(p.idle)+p.warming < p.opts.Size {
+		if p.opts.MaxSessions > 0 && p.active+len(p.idle)+p.warming >= p.opts.MaxSessions {
+			return
+		}
+		p.warming++
+		go p.warm()
+	}
+}
+
+// warm launches a browser for the idle list.
+func (p *browserPool) warm() {
+	b, err := p.launch()
+
+	p.mu.Lock()
+	p.warming--
+	if err != nil {
+		p.mu.Unlock()
+		// Not retried until the next acquire or release, so a broken
+		// install doesn't launch browsers in a loop
+		fmt.Printf("[pool] Failed to warm browser: %v\n", err)
+		return
+	}
+	if p.closed {
+		p.mu.Unlock()
+		b.close()
+		return
+	}
+	p.idle = append(p.idle, b)
+	idle := len(p.idle)
+	p.mu.Unlock()
+
+	fmt.Printf("[pool] Browser ready (%d idle)\n", idle)
+}
+
+// close closes the idle browsers and stops warming new ones. Browsers
+// being launched are closed when ready.
+func (p *browserPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, b := range idle {
+		b.close()
+	}
+}
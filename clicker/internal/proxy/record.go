@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/features"
+	"github.com/vibium/clicker/internal/policy"
+)
+
+// Directions of recorded messages.
+const (
+	ClientToProxy  = "client->proxy"
+	ProxyToBrowser = "proxy->browser"
+	BrowserToProxy = "browser->proxy"
+	ProxyToClient  = "proxy->client"
+)
+
+// RecordHeader is the direction of the first line of a recording, which
+// holds the serve options instead of a message.
+const RecordHeader = "header"
+
+// RecordEntry is a line of a session recording.
+type RecordEntry struct {
+	Time      time.Time      `json:"time"`
+	Direction string         `json:"direction"`         // e.g. ClientToProxy
+	Message   string         `json:"message,omitempty"` // As sent on the wire
+	Options   *RecordOptions `json:"options,omitempty"` // Set for RecordHeader
+}
+
+// RecordOptions are the router options a session was recorded with that
+// change what the browser does, so Replay can use them too.
+type RecordOptions struct {
+	InitScripts    []string               `json:"initScripts,omitempty"`
+	StorageState   *features.StorageState `json:"storageState,omitempty"` // Contents, the file may be gone at replay
+	Emulation      *bidi.EmulationOptions `json:"emulation,omitempty"`
+	Policy         *policy.File           `json:"policy,omitempty"`
+	SharedBrowsers int                    `json:"sharedBrowsers,omitempty"`
+}
+
+// WithRecording writes every message of every session to a JSONL file of
+// RecordEntry lines in dir: a RecordHeader with the router's options,
+// commands from the client, commands the proxy sends to the browser (the
+// client's and its own), messages from the browser, and messages to the
+// client. Replay a recording with Replay.
+func WithRecording(dir string) RouterOption {
+	return func(r *Router) {
+		r.recordDir = dir
+	}
+}
+
+// recorder writes the messages of a session to its recording.
+type recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	path string
+	err  error // First write error, after which recording stops
+}
+
+// newRecorder creates the recording of a client's session in dir.
+func newRecorder(dir string, clientID uint64) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	name := fmt.Sprintf("session-%s-client-%d.jsonl", time.Now().Format("20060102-150405"), clientID)
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	return &recorder{file: file, enc: json.NewEncoder(file), path: path}, nil
+}
+
+// record appends a message to the recording. A nil recorder records
+// nothing, so sessions without recording need no checks.
+func (rec *recorder) record(direction, msg string) {
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil || rec.file == nil {
+		return
+	}
+	if err := rec.enc.Encode(RecordEntry{Time: time.Now(), Direction: direction, Message: msg}); err != nil {
+		rec.err = err
+		fmt.Printf("[record] Stopped recording %s: %v\n", rec.path, err)
+	}
+}
+
+// header writes the RecordHeader line, before any message.
+func (rec *recorder) header(options *RecordOptions) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := rec.enc.Encode(RecordEntry{Time: time.Now(), Direction: RecordHeader, Options: options}); err != nil {
+		rec.err = err
+		fmt.Printf("[record] Stopped recording %s: %v\n", rec.path, err)
+	}
+}
+
+// close closes the recording file.
+func (rec *recorder) close() {
+	if rec == nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file != nil {
+		rec.file.Close()
+		rec.file = nil
+	}
+}
+
+// startRecording starts the recording of a new session, if enabled.
+func (r *Router) startRecording(session *BrowserSession) {
+	if r.recordDir == "" {
+		return
+	}
+
+	rec, err := newRecorder(r.recordDir, session.Client.ID)
+	if err != nil {
+		fmt.Printf("[router] Not recording session of client %d: %v\n", session.Client.ID, err)
+		return
+	}
+	rec.header(r.recordOptions())
+	session.recorder = rec
+	fmt.Printf("[router] Recording session of client %d to %s\n", session.Client.ID, rec.path)
+}
+
+// recordOptions returns the options for the header of a recording.
+func (r *Router) recordOptions() *RecordOptions {
+	options := &RecordOptions{
+		InitScripts:    r.initScripts,
+		Policy:         r.policy.Patterns(),
+		SharedBrowsers: r.sharedCount,
+	}
+	if !r.emulation.IsEmpty() {
+		options.Emulation = r.emulation
+	}
+	if r.storageState != "" {
+		state, err := features.ReadStorageState(r.storageState)
+		if err != nil {
+			fmt.Printf("[router] Not recording the storage state: %v\n", err)
+		} else {
+			options.StorageState = state
+		}
+	}
+	return options
+}
+
+// sendToBrowser sends a message on the session's own browser connection.
+func (session *BrowserSession) sendToBrowser(msg string) error {
+	session.recorder.record(ProxyToBrowser, msg)
+	return session.BidiConn.Send(msg)
+}
+
+// ReadRecording reads the entries of a recording file.
+func ReadRecording(path string) ([]RecordEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []RecordEntry
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var entry RecordEntry
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid recording %s: entry %d: %w", path, len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
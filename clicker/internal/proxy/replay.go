@@ -0,0 +1,490 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vibium/clicker/internal/auth"
+	"github.com/vibium/clicker/internal/policy"
+)
+
+// defaultReplayTimeout bounds how long Replay waits for each response.
+const defaultReplayTimeout = 60 * time.Second
+
+// idKeys are the fields holding IDs the browser assigns, which differ
+// between runs. Replay maps the recorded IDs to the new ones.
+var idKeys = map[string]bool{
+	"context":        true,
+	"contexts":       true,
+	"parent":         true,
+	"originalOpener": true,
+	"clientWindow":   true,
+	"userContext":    true,
+	"userContexts":   true,
+	"realm":          true,
+	"sharedId":       true,
+	"handle":         true,
+	"navigation":     true,
+	"request":        true,
+	"intercept":      true,
+	"script":         true,
+	"subscription":   true,
+}
+
+// volatileKeys are fields whose values differ between runs, e.g. times,
+// screenshots and browser stack traces. Replay doesn't compare them.
+var volatileKeys = map[string]bool{
+	"timestamp":  true,
+	"data":       true,
+	"stacktrace": true,
+}
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	Headless bool
+	Timeout  time.Duration // How long to wait for each response (0 = 60s)
+}
+
+// ReplayDiff is a command whose response differs from the recording.
+type ReplayDiff struct {
+	ID          int
+	Method      string
+	Differences []string // e.g. `result.clicked: true, replay has false`
+}
+
+// ReplayResult is the outcome of a replay.
+type ReplayResult struct {
+	Commands int // Client commands sent
+	Compared int // Responses compared with the recording
+	Diffs    []ReplayDiff
+}
+
+// replayCommand is a client command of a recording and its response.
+type replayCommand struct {
+	msg      string
+	id       int
+	hasID    bool
+	method   string
+	response map[string]interface{} // Recorded response, nil if none
+}
+
+// Replay sends the client commands of a recording to a fresh browser behind
+// a new proxy with the recorded options (see RecordOptions), one at a time,
+// and compares each response with the recorded one. IDs the browser assigns
+// (browsing contexts, realms, ...) are mapped from the recording to the
+// replay as they appear in responses and events, and rewritten in later
+// commands.
+func Replay(path string, opts ReplayOptions) (*ReplayResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultReplayTimeout
+	}
+
+	entries, err := ReadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	commands := replayCommands(entries)
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("no client commands in %s", path)
+	}
+	events := recordedEvents(entries)
+
+	routerOpts, cleanup, err := replayRouterOptions(entries)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	router := NewRouter(opts.Headless, routerOpts...)
+	defer router.CloseAll()
+	server := NewServer(
+		WithPort(0),
+		WithToken(token),
+		WithOnConnect(router.OnClientConnect),
+		WithOnMessage(router.OnClientMessage),
+		WithOnClose(router.OnClientDisconnect),
+	)
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Stop(ctx)
+	}()
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial(server.URL(), header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageSize)
+
+	responses := newReplayResponses()
+	go responses.read(conn)
+
+	result := &ReplayResult{}
+	ids := make(map[string]string) // recorded -> replayed
+	for _, cmd := range commands {
+		events.learn(responses.takeEvents(), ids)
+
+		msg := cmd.msg
+		var parsed interface{}
+		if json.Unmarshal([]byte(msg), &parsed) == nil {
+			data, _ := json.Marshal(rewriteIDs(parsed, ids))
+			msg = string(data)
+		}
+
+		var ch chan map[string]interface{}
+		if cmd.hasID {
+			ch = responses.expect(cmd.id)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return result, fmt.Errorf("failed to send command %d: %w", cmd.id, err)
+		}
+		result.Commands++
+
+		// Without a recorded response there's nothing to compare, or wait for
+		if cmd.response == nil {
+			continue
+		}
+		result.Compared++
+
+		var differences []string
+		select {
+		case resp := <-ch:
+			events.learn(responses.takeEvents(), ids)
+			delete(cmd.response, "id")
+			delete(resp, "id")
+			diffValues("", "", cmd.response, resp, ids, &differences)
+		case <-time.After(opts.Timeout):
+			differences = []string{fmt.Sprintf("no response within %s", opts.Timeout)}
+		case <-responses.done:
+			return result, fmt.Errorf("proxy closed the connection: %v", responses.err)
+		}
+		if len(differences) > 0 {
+			result.Diffs = append(result.Diffs, ReplayDiff{ID: cmd.id, Method: cmd.method, Differences: differences})
+		}
+	}
+	return result, nil
+}
+
+// replayRouterOptions returns the router options of a recording's header.
+// cleanup removes the files they need.
+func replayRouterOptions(entries []RecordEntry) (opts []RouterOption, cleanup func(), err error) {
+	cleanup = func() {}
+	if len(entries) == 0 || entries[0].Direction != RecordHeader || entries[0].Options == nil {
+		// Recorded before headers, or by hand
+		return nil, cleanup, nil
+	}
+	options := entries[0].Options
+
+	opts = append(opts,
+		WithInitScripts(options.InitScripts),
+		WithEmulation(options.Emulation),
+		WithSharedBrowsers(options.SharedBrowsers),
+	)
+	if options.Policy != nil {
+		p, err := policy.New(options.Policy.Allow, options.Policy.Block)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("invalid policy in recording: %w", err)
+		}
+		opts = append(opts, WithPolicy(p))
+	}
+	if options.StorageState != nil {
+		// Launching reads the storage state from a file
+		f, err := os.CreateTemp("", "clicker-replay-*.json")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { os.Remove(f.Name()) }
+		err = json.NewEncoder(f).Encode(options.StorageState)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("failed to write storage state: %w", err)
+		}
+		opts = append(opts, WithStorageState(f.Name()))
+	}
+	return opts, cleanup, nil
+}
+
+// replayEvents are the recorded events the client got, by method, in order.
+// The n-th replayed event of a method is matched with the n-th recorded
+// one to learn IDs only events carry, e.g. of tabs the page opened.
+type replayEvents map[string][]interface{}
+
+// recordedEvents returns the events of a recording the client got.
+func recordedEvents(entries []RecordEntry) replayEvents {
+	events := make(replayEvents)
+	for _, entry := range entries {
+		if entry.Direction != ProxyToClient {
+			continue
+		}
+		var event struct {
+			Type   string      `json:"type"`
+			Method string      `json:"method"`
+			Params interface{} `json:"params"`
+		}
+		if json.Unmarshal([]byte(entry.Message), &event) == nil && event.Type == "event" {
+			events[event.Method] = append(events[event.Method], event.Params)
+		}
+	}
+	return events
+}
+
+// learn maps the IDs of replayed events to the recorded ones, matching
+// each with the next recorded event of its method.
+func (events replayEvents) learn(replayed []map[string]interface{}, ids map[string]string) {
+	for _, event := range replayed {
+		method, _ := event["method"].(string)
+		recorded := events[method]
+		if len(recorded) == 0 {
+			continue
+		}
+		events[method] = recorded[1:]
+
+		// Differences between events aren't reported, only commands' responses
+		var ignored []string
+		diffValues("", "", recorded[0], event["params"], ids, &ignored)
+	}
+}
+
+// replayCommands returns the client commands of a recording, each with
+// the response the client got.
+func replayCommands(entries []RecordEntry) []*replayCommand {
+	var commands []*replayCommand
+	pending := make(map[int]*replayCommand)
+
+	for _, entry := range entries {
+		switch entry.Direction {
+		case ClientToProxy:
+			cmd := &replayCommand{msg: entry.Message}
+			var parsed struct {
+				ID     *int   `json:"id"`
+				Method string `json:"method"`
+			}
+			if json.Unmarshal([]byte(entry.Message), &parsed) == nil && parsed.ID != nil {
+				cmd.id, cmd.hasID, cmd.method = *parsed.ID, true, parsed.Method
+				pending[cmd.id] = cmd
+			}
+			commands = append(commands, cmd)
+
+		case ProxyToClient:
+			var resp map[string]interface{}
+			if json.Unmarshal([]byte(entry.Message), &resp) != nil {
+				continue
+			}
+			id, ok := resp["id"].(float64)
+			if !ok || resp["type"] == "event" {
+				continue
+			}
+			if cmd := pending[int(id)]; cmd != nil {
+				cmd.response = resp
+				delete(pending, int(id))
+			}
+		}
+	}
+	return commands
+}
+
+// replayResponses hands the proxy's responses to the commands waiting for
+// them, and keeps events for replayEvents.learn.
+type replayResponses struct {
+	mu      sync.Mutex
+	waiting map[int]chan map[string]interface{}
+	events  []map[string]interface{}
+	done    chan struct{}
+	err     error // Why the connection closed, set before done is closed
+}
+
+func newReplayResponses() *replayResponses {
+	return &replayResponses{
+		waiting: make(map[int]chan map[string]interface{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// expect registers a command ID before the command is sent.
+func (rr *replayResponses) expect(id int) chan map[string]interface{} {
+	ch := make(chan map[string]interface{}, 1)
+	rr.mu.Lock()
+	rr.waiting[id] = ch
+	rr.mu.Unlock()
+	return ch
+}
+
+// takeEvents returns the events received since the last call.
+func (rr *replayResponses) takeEvents() []map[string]interface{} {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	events := rr.events
+	rr.events = nil
+	return events
+}
+
+// read reads messages until the connection closes.
+func (rr *replayResponses) read(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			rr.err = err
+			close(rr.done)
+			return
+		}
+
+		var resp map[string]interface{}
+		if json.Unmarshal(data, &resp) != nil {
+			continue
+		}
+		if resp["type"] == "event" {
+			rr.mu.Lock()
+			rr.events = append(rr.events, resp)
+			rr.mu.Unlock()
+			continue
+		}
+		id, ok := resp["id"].(float64)
+		if !ok {
+			continue
+		}
+
+		rr.mu.Lock()
+		ch := rr.waiting[int(id)]
+		delete(rr.waiting, int(id))
+		rr.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+}
+
+// rewriteIDs replaces recorded IDs in a command with the replay's.
+func rewriteIDs(value interface{}, ids map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = rewriteIDs(item, ids)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rewriteIDs(item, ids)
+		}
+	case string:
+		if replayed, ok := ids[v]; ok {
+			return replayed
+		}
+	}
+	return value
+}
+
+// diffValues appends the differences between a recorded and a replayed
+// value to diffs, and learns the mapping of IDs under idKeys. key is the
+// field holding the values.
+func diffValues(path, key string, recorded, replayed interface{}, ids map[string]string, diffs *[]string) {
+	if volatileKeys[key] {
+		return
+	}
+
+	switch rec := recorded.(type) {
+	case map[string]interface{}:
+		rep, ok := replayed.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(rec)+len(rep))
+		for k := range rec {
+			keys = append(keys, k)
+		}
+		for k := range rep {
+			if _, ok := rec[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			recValue, inRec := rec[k]
+			repValue, inRep := rep[k]
+			switch {
+			case volatileKeys[k]:
+			case !inRep:
+				*diffs = append(*diffs, fmt.Sprintf("%s: missing in replay", joinPath(path, k)))
+			case !inRec:
+				*diffs = append(*diffs, fmt.Sprintf("%s: not in recording", joinPath(path, k)))
+			default:
+				diffValues(joinPath(path, k), k, recValue, repValue, ids, diffs)
+			}
+		}
+		return
+
+	case []interface{}:
+		rep, ok := replayed.([]interface{})
+		if !ok {
+			break
+		}
+		if len(rec) != len(rep) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d items, replay has %d", pathOrRoot(path), len(rec), len(rep)))
+		}
+		for i := 0; i < len(rec) && i < len(rep); i++ {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), key, rec[i], rep[i], ids, diffs)
+		}
+		return
+
+	case string:
+		rep, ok := replayed.(string)
+		if ok && idKeys[key] {
+			if mapped, known := ids[rec]; !known {
+				ids[rec] = rep
+				return
+			} else if mapped == rep {
+				return
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(recorded, replayed) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s, replay has %s", pathOrRoot(path), shortJSON(recorded), shortJSON(replayed)))
+	}
+}
+
+// joinPath appends a field to a JSON path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pathOrRoot names the whole response for the empty path.
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "response"
+	}
+	return path
+}
+
+// shortJSON formats a value for a diff, cut to 80 characters.
+func shortJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	s := string(data)
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return strings.ReplaceAll(s, "\n", " ")
+}
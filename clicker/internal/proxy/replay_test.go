@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vibium/clicker/internal/features"
+	"github.com/vibium/clicker/internal/policy"
+)
+
+// parseJSON decodes a JSON literal of a test.
+func parseJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		recorded string
+		replayed string
+		ids      map[string]string // Known before the diff
+		diffs    []string
+		learned  map[string]string // Known after the diff
+	}{
+		{
+			name:     "equal",
+			recorded: `{"type":"success","result":{"clicked":true}}`,
+			replayed: `{"type":"success","result":{"clicked":true}}`,
+		},
+		{
+			name:     "changed value",
+			recorded: `{"result":{"clicked":true}}`,
+			replayed: `{"result":{"clicked":false}}`,
+			diffs:    []string{"result.clicked: true, replay has false"},
+		},
+		{
+			name:     "missing and extra keys",
+			recorded: `{"result":{"a":1}}`,
+			replayed: `{"result":{"b":1}}`,
+			diffs:    []string{"result.a: missing in replay", "result.b: not in recording"},
+		},
+		{
+			name:     "array length",
+			recorded: `{"result":{"nodes":[1,2]}}`,
+			replayed: `{"result":{"nodes":[1]}}`,
+			diffs:    []string{"result.nodes: 2 items, replay has 1"},
+		},
+		{
+			name:     "type",
+			recorded: `{"result":{"value":"1"}}`,
+			replayed: `{"result":{"value":1}}`,
+			diffs:    []string{`result.value: "1", replay has 1`},
+		},
+		{
+			name:     "volatile keys",
+			recorded: `{"result":{"data":"abc","timestamp":1}}`,
+			replayed: `{"result":{"data":"xyz","timestamp":2}}`,
+		},
+		{
+			name:     "learns IDs",
+			recorded: `{"result":{"context":"rec-ctx","contexts":[{"context":"rec-child","parent":"rec-ctx"}]}}`,
+			replayed: `{"result":{"context":"new-ctx","contexts":[{"context":"new-child","parent":"new-ctx"}]}}`,
+			learned:  map[string]string{"rec-ctx": "new-ctx", "rec-child": "new-child"},
+		},
+		{
+			name:     "known ID matches",
+			recorded: `{"result":{"context":"rec-ctx"}}`,
+			replayed: `{"result":{"context":"new-ctx"}}`,
+			ids:      map[string]string{"rec-ctx": "new-ctx"},
+		},
+		{
+			name:     "known ID differs",
+			recorded: `{"result":{"context":"rec-ctx"}}`,
+			replayed: `{"result":{"context":"other"}}`,
+			ids:      map[string]string{"rec-ctx": "new-ctx"},
+			diffs:    []string{`result.context: "rec-ctx", replay has "other"`},
+		},
+		{
+			name:     "only ID keys are mapped",
+			recorded: `{"result":{"title":"rec"}}`,
+			replayed: `{"result":{"title":"new"}}`,
+			diffs:    []string{`result.title: "rec", replay has "new"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make(map[string]string)
+			for k, v := range tt.ids {
+				ids[k] = v
+			}
+			var diffs []string
+			diffValues("", "", parseJSON(t, tt.recorded), parseJSON(t, tt.replayed), ids, &diffs)
+
+			if !reflect.DeepEqual(diffs, tt.diffs) {
+				t.Errorf("diffs = %q, want %q", diffs, tt.diffs)
+			}
+			for k, v := range tt.learned {
+				if ids[k] != v {
+					t.Errorf("ids[%q] = %q, want %q", k, ids[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestShortJSON(t *testing.T) {
+	got := shortJSON(strings.Repeat("a", 100))
+	if len(got) != 80 || !strings.HasSuffix(got, "...") {
+		t.Errorf("shortJSON() = %q, want 80 characters ending in ...", got)
+	}
+}
+
+func TestRewriteIDs(t *testing.T) {
+	ids := map[string]string{"rec-ctx": "new-ctx", "rec-realm": "new-realm"}
+	cmd := parseJSON(t, `{"id":1,"method":"script.evaluate","params":{"target":{"context":"rec-ctx"},"contexts":["rec-ctx","other"],"expression":"rec-realm"}}`)
+	want := parseJSON(t, `{"id":1,"method":"script.evaluate","params":{"target":{"context":"new-ctx"},"contexts":["new-ctx","other"],"expression":"new-realm"}}`)
+
+	if got := rewriteIDs(cmd, ids); !reflect.DeepEqual(got, want) {
+		t.Errorf("rewriteIDs() = %v, want %v", got, want)
+	}
+}
+
+func TestReplayEventsLearn(t *testing.T) {
+	event := func(method, params string) string {
+		return `{"type":"event","method":"` + method + `","params":` + params + `}`
+	}
+	entries := []RecordEntry{
+		{Direction: ProxyToClient, Message: event("browsingContext.contextCreated", `{"context":"rec-1","url":"about:blank"}`)},
+		{Direction: ProxyToBrowser, Message: event("browsingContext.contextCreated", `{"context":"ignored"}`)},
+		{Direction: ProxyToClient, Message: `{"id":1,"type":"success","result":{}}`},
+		{Direction: ProxyToClient, Message: event("browsingContext.contextCreated", `{"context":"rec-2","url":"about:blank"}`)},
+	}
+	events := recordedEvents(entries)
+	if n := len(events["browsingContext.contextCreated"]); n != 2 {
+		t.Fatalf("recorded %d events, want 2", n)
+	}
+
+	ids := make(map[string]string)
+	replayed := []map[string]interface{}{
+		parseJSON(t, event("browsingContext.contextCreated", `{"context":"new-1","url":"about:blank"}`)).(map[string]interface{}),
+		parseJSON(t, event("log.entryAdded", `{"source":{"context":"x"}}`)).(map[string]interface{}),
+		parseJSON(t, event("browsingContext.contextCreated", `{"context":"new-2","url":"about:blank"}`)).(map[string]interface{}),
+		parseJSON(t, event("browsingContext.contextCreated", `{"context":"new-3","url":"about:blank"}`)).(map[string]interface{}),
+	}
+	events.learn(replayed, ids)
+
+	want := map[string]string{"rec-1": "new-1", "rec-2": "new-2"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestReplayRouterOptions(t *testing.T) {
+	opts, cleanup, err := replayRouterOptions([]RecordEntry{{Direction: ClientToProxy, Message: "{}"}})
+	defer cleanup()
+	if err != nil || opts != nil {
+		t.Fatalf("replayRouterOptions() without header = %v, %v, want none", opts, err)
+	}
+
+	header := RecordEntry{Direction: RecordHeader, Options: &RecordOptions{
+		InitScripts:    []string{"window.x = 1"},
+		SharedBrowsers: 1,
+	}}
+	header.Options.Policy = &policy.File{Allow: []string{"example.com"}}
+	opts, cleanup, err = replayRouterOptions([]RecordEntry{header})
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(true, opts...)
+	if !reflect.DeepEqual(r.initScripts, []string{"window.x = 1"}) || r.sharedCount != 1 {
+		t.Errorf("router has init scripts %q and %d shared browsers", r.initScripts, r.sharedCount)
+	}
+	if r.policy.Check("https://example.com/") != nil || r.policy.Check("https://other.example/") == nil {
+		t.Error("router doesn't have the recorded policy")
+	}
+
+	// The storage state is written to a file for the launcher
+	header.Options = &RecordOptions{StorageState: &features.StorageState{
+		Origins: []features.OriginState{{Origin: "https://example.com", LocalStorage: map[string]string{"k": "v"}}},
+	}}
+	opts, cleanup, err = replayRouterOptions([]RecordEntry{header})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = NewRouter(true, opts...)
+	state, err := features.ReadStorageState(r.storageState)
+	if err != nil || state.Origins[0].LocalStorage["k"] != "v" {
+		t.Errorf("storage state file has %+v, %v", state, err)
+	}
+	cleanup()
+	if _, err := os.Stat(r.storageState); !os.IsNotExist(err) {
+		t.Errorf("cleanup left %s", r.storageState)
+	}
+}
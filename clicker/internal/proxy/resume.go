@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vibium/clicker/internal/auth"
+)
+
+// maxMissedBytes caps the size of the messages buffered for a disconnected
+// client. Older messages are dropped first.
+const maxMissedBytes = 16 << 20
+
+// WithKeepAlive keeps the browser session of a client that disconnects for
+// d, so the client can reconnect and continue where it left off. Each
+// session announces a resume token with a vibium:sessionReady event; a
+// client connecting with ?resume=<token> takes the session over, from a
+// disconnected client or a connected one (e.g. a debugger attaching
+// mid-run), and receives the messages the browser sent in between.
+func WithKeepAlive(d time.Duration) RouterOption {
+	return func(r *Router) {
+		r.keepAlive = d
+	}
+}
+
+// startSession makes a new session available to its client.
+func (r *Router) startSession(session *BrowserSession) {
+	if r.keepAlive > 0 {
+		token, err := auth.GenerateToken()
+		if err != nil {
+			fmt.Printf("[router] Client %d can't resume its session: %v\n", session.Client.ID, err)
+		} else {
+			session.resumeToken = token
+			r.resumable.Store(token, session)
+		}
+	}
+
+	r.startRecording(session)
+	r.sessions.Store(session.Client.ID, session)
+	r.metrics.sessionStarted()
+	r.announceSession(session, false, 0)
+
+	session.touch()
+	if r.limits.enabled() {
+		go r.watchSession(session)
+	}
+}
+
+// announceSession sends the vibium:sessionReady event with the resume
+// token, if the session has one.
+func (r *Router) announceSession(session *BrowserSession, resumed bool, dropped int) {
+	if session.resumeToken == "" {
+		return
+	}
+	session.send(r.sessionReadyEvent(session, resumed, dropped))
+}
+
+// sessionReadyEvent returns the vibium:sessionReady event of a session.
+func (r *Router) sessionReadyEvent(session *BrowserSession, resumed bool, dropped int) string {
+	event := map[string]interface{}{
+		"type":   "event",
+		"method": "vibium:sessionReady",
+		"params": map[string]interface{}{
+			"resumeToken":      session.resumeToken,
+			"keepAliveSeconds": r.keepAlive.Seconds(),
+			"resumed":          resumed,
+			"droppedMessages":  dropped,
+		},
+	}
+	data, _ := json.Marshal(event)
+	return string(data)
+}
+
+// resumeSession hands the session with the client's resume token to the
+// client and replays the messages it missed.
+func (r *Router) resumeSession(client *ClientConn) {
+	sessionVal, ok := r.resumable.Load(client.ResumeToken)
+	if !ok {
+		r.rejectClient(client, fmt.Errorf("unknown or expired resume token"))
+		return
+	}
+	session := sessionVal.(*BrowserSession)
+
+	session.clientMu.Lock()
+	session.mu.Lock()
+	closed := session.closed
+	session.mu.Unlock()
+	if closed || session.expired {
+		session.clientMu.Unlock()
+		r.rejectClient(client, fmt.Errorf("unknown or expired resume token"))
+		return
+	}
+
+	previous := session.Client
+	wasDetached := session.detached
+	if session.expiry != nil {
+		session.expiry.Stop()
+		session.expiry = nil
+	}
+	session.Client = client
+	session.detached = false
+	session.touch()
+	missed := session.missed
+
+	// Replay before releasing the lock, so newer messages come after
+	client.Send(r.sessionReadyEvent(session, true, session.dropped))
+	for _, msg := range missed {
+		client.Send(msg)
+	}
+	session.missed, session.missedBytes, session.dropped = nil, 0, 0
+
+	// Move the session to the new client before the previous one's
+	// disconnect is handled, so that doesn't close it
+	r.sessions.Store(client.ID, session)
+	r.sessions.Delete(previous.ID)
+	session.clientMu.Unlock()
+
+	if wasDetached {
+		fmt.Printf("[router] Client %d resumed the browser session of client %d (%d missed messages)\n", client.ID, previous.ID, len(missed))
+	} else {
+		fmt.Printf("[router] Client %d took over the browser session of client %d\n", client.ID, previous.ID)
+		previous.Close()
+	}
+}
+
+// keepSession detaches a disconnected client from its session and closes
+// the session if the client doesn't resume it within the keep-alive.
+// Returns false if the session must be closed now.
+func (r *Router) keepSession(session *BrowserSession, client *ClientConn) bool {
+	if session.resumeToken == "" {
+		return false
+	}
+
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	if session.Client != client {
+		// Another client took the session over
+		return true
+	}
+
+	session.detached = true
+	session.expiry = time.AfterFunc(r.keepAlive, func() {
+		r.expireSession(session)
+	})
+	fmt.Printf("[router] Keeping browser session of client %d for %s\n", client.ID, r.keepAlive)
+	return true
+}
+
+// expireSession closes a session whose client didn't resume it in time.
+func (r *Router) expireSession(session *BrowserSession) {
+	session.clientMu.Lock()
+	if !session.detached || session.expired {
+		session.clientMu.Unlock()
+		return
+	}
+	session.expired = true
+	client := session.Client
+	session.clientMu.Unlock()
+
+	fmt.Printf("[router] Client %d didn't resume its browser session within %s\n", client.ID, r.keepAlive)
+	r.sessions.CompareAndDelete(client.ID, session)
+	r.closeSession(session)
+}
+
+// endSession closes a session and disconnects its client, e.g. when its
+// browser is gone. The session can't be resumed.
+func (r *Router) endSession(session *BrowserSession) {
+	client := session.client()
+	r.sessions.CompareAndDelete(client.ID, session)
+	r.closeSession(session)
+	client.Close()
+}
+
+// client returns the client the session belongs to.
+func (session *BrowserSession) client() *ClientConn {
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	return session.Client
+}
+
+// isDetached returns true if the session's client disconnected and the
+// session waits to be resumed.
+func (session *BrowserSession) isDetached() bool {
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	return session.detached
+}
+
+// send sends a message to the session's client. If the client is gone and
+// the session can be resumed, the message is kept for the next client.
+func (session *BrowserSession) send(msg string) error {
+	session.recorder.record(ProxyToClient, msg)
+
+	session.clientMu.Lock()
+	defer session.clientMu.Unlock()
+	session.mirror(msg)
+
+	if !session.detached {
+		err := session.Client.Send(msg)
+		if err == nil || session.resumeToken == "" {
+			return err
+		}
+		// Disconnected, but the disconnect isn't handled yet
+	}
+
+	if len(msg) > maxMissedBytes {
+		session.dropped++
+		return nil
+	}
+	for session.missedBytes+len(msg) > maxMissedBytes {
+		session.missedBytes -= len(session.missed[0])
+		session.missed = session.missed[1:]
+		session.dropped++
+	}
+	session.missed = append(session.missed, msg)
+	session.missedBytes += len(msg)
+	return nil
+}
@@ -2,17 +2,27 @@ package proxy
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/vibium/clicker/internal/bidi"
 	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/features"
+	"github.com/vibium/clicker/internal/policy"
 )
 
 // Default timeout for actionability checks
 const defaultTimeout = 30 * time.Second
 
+// internalCommandTimeout bounds how long the router waits for the browser
+// to answer its own commands.
+const internalCommandTimeout = 60 * time.Second
+
 // BrowserSession represents a browser session connected to a client.
 type BrowserSession struct {
 	LaunchResult *browser.LaunchResult
@@ -22,11 +32,43 @@ type BrowserSession struct {
 	mu           sync.Mutex
 	closed       bool
 	stopChan     chan struct{}
+	started      time.Time
+
+	// vibium: commands awaiting a response, for the error metrics
+	vibiumCmds map[int]string // id -> method
 
 	// Internal command tracking for vibium: extension commands
 	internalCmds   map[int]chan json.RawMessage // id -> response channel
 	internalCmdsMu sync.Mutex
 	nextInternalID int
+
+	// URL policy enforcement, see WithPolicy
+	policyIntercept string           // Intercept pausing requests for policy checks
+	policyEnforcer  *policy.Enforcer // Created on first use, see Router.enforcer
+	policyRequests  map[string]bool  // Paused requests the policy resolves
+	clientEvents    map[string]bool  // Events the client subscribed to
+
+	// Set if the session is a user context in a shared browser, see WithSharedBrowsers
+	shared      *sharedBrowser
+	userContext string
+
+	// Resuming after the client disconnects, see WithKeepAlive. Client is
+	// replaced when another client resumes the session, so use client()
+	// and send() rather than Client.
+	resumeToken string
+	clientMu    sync.Mutex
+	detached    bool        // The client disconnected
+	expired     bool        // Not resumed within the keep-alive
+	expiry      *time.Timer // Closes the session when detached
+	missed      []string    // Messages for the client while detached
+	missedBytes int         // Total size of missed
+	dropped     int         // Messages dropped from missed
+
+	lastActivity time.Time // Last client command, for the idle timeout of WithSessionLimits
+
+	recorder *recorder // Records the session's messages, see WithRecording (nil = off)
+
+	observers map[uint64]*observer // Read-only clients by client ID, guarded by clientMu
 }
 
 // BiDi command structure for parsing incoming messages
@@ -36,80 +78,211 @@ type bidiCommand struct {
 	Params map[string]interface{} `json:"params"`
 }
 
-// BiDi response structure for sending responses (follows WebDriver BiDi spec)
+// BiDi response structure for sending responses (follows WebDriver BiDi spec).
+// Errors are sent as bidiError.
 type bidiResponse struct {
-	ID      int         `json:"id"`
-	Type    string      `json:"type"` // "success" or "error"
-	Result  interface{} `json:"result,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Message string      `json:"message,omitempty"`
+	ID     int         `json:"id"`
+	Type   string      `json:"type"` // "success"
+	Result interface{} `json:"result,omitempty"`
 }
 
 // Router manages browser sessions for connected clients.
 type Router struct {
-	sessions sync.Map // map[uint64]*BrowserSession (client ID -> session)
-	headless bool
+	sessions     sync.Map // map[uint64]*BrowserSession (client ID -> session)
+	headless     bool
+	storageState string                 // Storage state file restored into each new session
+	initScripts  []string               // JavaScript sources run before page scripts in each session
+	emulation    *bidi.EmulationOptions // Emulation applied to each session (nil = none)
+	policy       *policy.Policy         // Restricts navigation (nil = allow all)
+	poolOpts     PoolOptions
+	pool         *browserPool
+	sharedCount  int // Shared browsers to run (0 = a browser per client)
+	sharedMu     sync.Mutex
+	shared       []*sharedBrowser
+	metrics      *metrics
+	keepAlive    time.Duration // How long to keep the session of a disconnected client
+	resumable    sync.Map      // map[string]*BrowserSession (resume token -> session)
+	limits       SessionLimits // Closes sessions that run too long or use too much
+	recordDir    string        // Directory for session recordings ("" = off)
+	version      string        // Clicker version, for vibium:capabilities
+	observed     sync.Map      // map[uint64]*BrowserSession (observer client ID -> session)
+}
+
+// RouterOption configures a Router.
+type RouterOption func(*Router)
+
+// WithStorageState restores the storage state file into every new browser
+// session before the client can navigate.
+func WithStorageState(path string) RouterOption {
+	return func(r *Router) {
+		r.storageState = path
+	}
+}
+
+// WithInitScripts adds JavaScript sources that run in every document of every
+// browser session before any page script.
+func WithInitScripts(sources []string) RouterOption {
+	return func(r *Router) {
+		r.initScripts = sources
+	}
 }
 
-// NewRouter creates a new router.
-func NewRouter(headless bool) *Router {
-	return &Router{
+// WithEmulation applies emulation overrides to every browser session, like
+// vibium:emulate without a context does.
+func WithEmulation(opts *bidi.EmulationOptions) RouterOption {
+	return func(r *Router) {
+		r.emulation = opts
+	}
+}
+
+// NewRouter creates a new router. With WithPool, it starts launching the
+// pool's browsers in the background.
+func NewRouter(headless bool, opts ...RouterOption) *Router {
+	r := &Router{
 		headless: headless,
+		metrics:  newMetrics(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+	r.pool = newBrowserPool(r.poolOpts, r.launchBrowser)
+
+	return r
 }
 
 // OnClientConnect is called when a new client connects.
-// It launches a browser and establishes a BiDi connection.
+// It takes a browser from the pool, launching one if none is ready. With
+// WithSharedBrowsers, it creates a user context in a shared browser instead.
+// A client with a resume token resumes that session, see WithKeepAlive.
 func (r *Router) OnClientConnect(client *ClientConn) {
-	fmt.Printf("[router] Launching browser for client %d...\n", client.ID)
-
-	// Launch browser
-	launchResult, err := browser.Launch(browser.LaunchOptions{
-		Headless: r.headless,
-	})
-	if err != nil {
-		fmt.Printf("[router] Failed to launch browser for client %d: %v\n", client.ID, err)
-		client.Send(fmt.Sprintf(`{"error":{"code":-32000,"message":"Failed to launch browser: %s"}}`, err.Error()))
-		client.Close()
+	if client.Observe != "" {
+		r.observeSession(client)
+		return
+	}
+	if client.ResumeToken != "" {
+		r.resumeSession(client)
 		return
 	}
 
-	fmt.Printf("[router] Browser launched for client %d, WebSocket: %s\n", client.ID, launchResult.WebSocketURL)
+	fmt.Printf("[router] Starting browser session for client %d...\n", client.ID)
 
-	// Connect to browser BiDi WebSocket
-	bidiConn, err := bidi.Connect(launchResult.WebSocketURL)
-	if err != nil {
-		fmt.Printf("[router] Failed to connect to browser BiDi for client %d: %v\n", client.ID, err)
-		launchResult.Close()
-		client.Send(fmt.Sprintf(`{"error":{"code":-32000,"message":"Failed to connect to browser: %s"}}`, err.Error()))
-		client.Close()
+	if r.sharedCount > 0 {
+		r.connectShared(client)
 		return
 	}
 
-	fmt.Printf("[router] BiDi connection established for client %d\n", client.ID)
+	b, err := r.pool.acquire()
+	if err != nil {
+		r.rejectClient(client, err)
+		return
+	}
 
-	// Create a BiDi client for handling custom commands
-	bidiClient := bidi.NewClient(bidiConn)
+	fmt.Printf("[router] Browser session started for client %d, WebSocket: %s\n", client.ID, b.launchResult.WebSocketURL)
 
 	session := &BrowserSession{
-		LaunchResult:   launchResult,
-		BidiConn:       bidiConn,
-		BidiClient:     bidiClient,
-		Client:         client,
-		stopChan:       make(chan struct{}),
-		internalCmds:   make(map[int]chan json.RawMessage),
-		nextInternalID: 1000000, // Start at high number to avoid collision with client IDs
+		LaunchResult:    b.launchResult,
+		BidiConn:        b.bidiConn,
+		BidiClient:      b.bidiClient,
+		Client:          client,
+		stopChan:        make(chan struct{}),
+		started:         time.Now(),
+		internalCmds:    make(map[int]chan json.RawMessage),
+		nextInternalID:  1000000, // Start at high number to avoid collision with client IDs
+		policyIntercept: b.policyIntercept,
 	}
 
-	r.sessions.Store(client.ID, session)
+	r.startSession(session)
 
 	// Start routing messages from browser to client
 	go r.routeBrowserToClient(session)
 }
 
+// rejectClient tells a client that its browser session couldn't start and
+// disconnects it.
+func (r *Router) rejectClient(client *ClientConn, err error) {
+	fmt.Printf("[router] Failed to start browser session for client %d: %v\n", client.ID, err)
+	r.metrics.sessionRejected()
+	resp := newBidiError(nil, err)
+	if resp.Error == "unknown error" {
+		resp.Error = "session not created"
+	}
+	resp.Message = "failed to start browser session: " + resp.Message
+	client.Send(resp.marshal())
+	client.Close()
+}
+
+// launchBrowser launches a browser, connects to it and prepares it for a
+// client: emulation, init scripts, storage state and URL policy are in
+// place before the client can send any command.
+func (r *Router) launchBrowser() (*warmBrowser, error) {
+	b, err := r.launchBrowserOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.bidiClient.ApplyEmulation(b.launchResult.Emulation); err != nil {
+		b.close()
+		return nil, fmt.Errorf("failed to apply emulation: %w", err)
+	}
+	for _, source := range r.initScripts {
+		if _, err := b.bidiClient.AddInitScript(source); err != nil {
+			b.close()
+			return nil, fmt.Errorf("failed to add init script: %w", err)
+		}
+	}
+	if b.launchResult.StorageState != nil {
+		if err := features.ApplyStorageState(b.bidiClient, b.launchResult.StorageState); err != nil {
+			b.close()
+			return nil, fmt.Errorf("failed to restore storage state: %w", err)
+		}
+	}
+	if r.policy != nil {
+		if b.policyIntercept, err = r.enforcePolicy(b.bidiClient); err != nil {
+			b.close()
+			return nil, fmt.Errorf("failed to enforce URL policy: %w", err)
+		}
+	}
+
+	return b, nil
+}
+
+// launchBrowserOnly launches a browser and connects to it.
+func (r *Router) launchBrowserOnly() (*warmBrowser, error) {
+	start := time.Now()
+	launchResult, err := browser.Launch(browser.LaunchOptions{
+		Headless:     r.headless,
+		Emulation:    r.emulation,
+		StorageState: r.storageState,
+	})
+	if err != nil {
+		r.metrics.launchFailed()
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+
+	// Connect to browser BiDi WebSocket
+	bidiConn, err := bidi.Connect(launchResult.WebSocketURL)
+	if err != nil {
+		launchResult.Close()
+		r.metrics.launchFailed()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	r.metrics.browserLaunched(time.Since(start))
+
+	return &warmBrowser{
+		launchResult: launchResult,
+		bidiConn:     bidiConn,
+		bidiClient:   bidi.NewClient(bidiConn),
+	}, nil
+}
+
 // OnClientMessage is called when a message is received from a client.
 // It handles custom vibium: extension commands or forwards to the browser.
 func (r *Router) OnClientMessage(client *ClientConn, msg string) {
+	if r.observerMessage(client, msg) {
+		return
+	}
+
 	sessionVal, ok := r.sessions.Load(client.ID)
 	if !ok {
 		fmt.Printf("[router] No session for client %d\n", client.ID)
@@ -117,24 +290,58 @@ func (r *Router) OnClientMessage(client *ClientConn, msg string) {
 	}
 
 	session := sessionVal.(*BrowserSession)
+	session.recorder.record(ClientToProxy, msg)
 
 	session.mu.Lock()
 	if session.closed {
 		session.mu.Unlock()
 		return
 	}
+	session.lastActivity = time.Now()
 	session.mu.Unlock()
 
 	// Parse the command to check for custom vibium: extension methods
 	var cmd bidiCommand
 	if err := json.Unmarshal([]byte(msg), &cmd); err != nil {
+		// Responses from a shared browser can't be routed without an ID
+		if session.shared != nil {
+			fmt.Printf("[router] Dropping unparseable message from client %d: %v\n", client.ID, err)
+			return
+		}
+
 		// Can't parse, forward as-is
-		if err := session.BidiConn.Send(msg); err != nil {
+		if err := session.sendToBrowser(msg); err != nil {
 			fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
 		}
 		return
 	}
 
+	r.metrics.command(cmd.Method)
+	if strings.HasPrefix(cmd.Method, "vibium:") {
+		session.trackVibiumCommand(cmd)
+	}
+
+	// The proxy owns the session, so it answers for it
+	switch cmd.Method {
+	case "session.new":
+		r.handleSessionNew(session, cmd)
+		return
+	case "session.status":
+		r.handleSessionStatus(session, cmd)
+		return
+	}
+
+	// Keep clients of a shared browser in their own user context
+	if session.shared != nil {
+		if cmd.Params == nil {
+			cmd.Params = map[string]interface{}{}
+		}
+		if err := session.shared.scope(session, cmd.Method, cmd.Params); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+	}
+
 	// Handle vibium: extension commands (per WebDriver BiDi spec for extensions)
 	switch cmd.Method {
 	case "vibium:click":
@@ -146,10 +353,68 @@ func (r *Router) OnClientMessage(client *ClientConn, msg string) {
 	case "vibium:find":
 		r.handleVibiumFind(session, cmd)
 		return
+	case "vibium:content":
+		r.handleVibiumContent(session, cmd)
+		return
+	case "vibium:emulate":
+		r.handleVibiumEmulate(session, cmd)
+		return
+	case "vibium:addInitScript":
+		r.handleVibiumAddInitScript(session, cmd)
+		return
+	case "vibium:cookies.get":
+		r.handleVibiumCookiesGet(session, cmd)
+		return
+	case "vibium:cookies.set":
+		r.handleVibiumCookiesSet(session, cmd)
+		return
+	case "vibium:cookies.delete":
+		r.handleVibiumCookiesDelete(session, cmd)
+		return
+	case "vibium:describe":
+		r.handleVibiumDescribe(session, cmd)
+		return
+	case "browsingContext.navigate":
+		if r.policy != nil && r.checkNavigate(session, cmd) {
+			return
+		}
+	case "session.subscribe":
+		session.trackSubscription(cmd)
+	case "session.unsubscribe":
+		// Other clients may still need the browser's subscription
+		if session.shared != nil {
+			session.untrackSubscription(cmd)
+			r.sendSuccess(session, cmd.ID, map[string]interface{}{})
+			return
+		}
+		if session.policyIntercept != "" && r.handleUnsubscribe(session, cmd) {
+			return
+		}
+		session.untrackSubscription(cmd)
+	default:
+		// Chrome doesn't know vibium: commands either
+		if strings.HasPrefix(cmd.Method, "vibium:") {
+			r.sendError(session, cmd.ID, &errs.UnknownCommandError{Method: cmd.Method})
+			return
+		}
+	}
+
+	if session.policyIntercept != "" {
+		if err := session.checkPolicyCommand(cmd); err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+	}
+
+	if session.shared != nil {
+		if err := session.shared.forward(session, cmd); err != nil {
+			fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
+		}
+		return
 	}
 
 	// Forward standard BiDi commands to browser
-	if err := session.BidiConn.Send(msg); err != nil {
+	if err := session.sendToBrowser(msg); err != nil {
 		fmt.Printf("[router] Failed to send to browser for client %d: %v\n", client.ID, err)
 	}
 }
@@ -160,6 +425,11 @@ func (r *Router) handleVibiumClick(session *BrowserSession, cmd bidiCommand) {
 	context, _ := cmd.Params["context"].(string)
 	timeoutMs, _ := cmd.Params["timeout"].(float64)
 
+	if selector == "" {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "selector is required"})
+		return
+	}
+
 	timeout := defaultTimeout
 	if timeoutMs > 0 {
 		timeout = time.Duration(timeoutMs) * time.Millisecond
@@ -176,7 +446,7 @@ func (r *Router) handleVibiumClick(session *BrowserSession, cmd bidiCommand) {
 	}
 
 	// Wait for element and get its position
-	info, err := r.waitForElement(session, context, selector, timeout)
+	info, err := r.waitForElement(session, context, selector, features.ClickChecks, timeout)
 	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
@@ -219,6 +489,11 @@ func (r *Router) handleVibiumType(session *BrowserSession, cmd bidiCommand) {
 	text, _ := cmd.Params["text"].(string)
 	timeoutMs, _ := cmd.Params["timeout"].(float64)
 
+	if selector == "" {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "selector is required"})
+		return
+	}
+
 	timeout := defaultTimeout
 	if timeoutMs > 0 {
 		timeout = time.Duration(timeoutMs) * time.Millisecond
@@ -235,7 +510,7 @@ func (r *Router) handleVibiumType(session *BrowserSession, cmd bidiCommand) {
 	}
 
 	// Wait for element and get its position
-	info, err := r.waitForElement(session, context, selector, timeout)
+	info, err := r.waitForElement(session, context, selector, features.TypeChecks, timeout)
 	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
@@ -302,6 +577,11 @@ func (r *Router) handleVibiumFind(session *BrowserSession, cmd bidiCommand) {
 	context, _ := cmd.Params["context"].(string)
 	timeoutMs, _ := cmd.Params["timeout"].(float64)
 
+	if selector == "" {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "selector is required"})
+		return
+	}
+
 	timeout := defaultTimeout
 	if timeoutMs > 0 {
 		timeout = time.Duration(timeoutMs) * time.Millisecond
@@ -318,7 +598,7 @@ func (r *Router) handleVibiumFind(session *BrowserSession, cmd bidiCommand) {
 	}
 
 	// Wait for element
-	info, err := r.waitForElement(session, context, selector, timeout)
+	info, err := r.waitForElement(session, context, selector, nil, timeout)
 	if err != nil {
 		r.sendError(session, cmd.ID, err)
 		return
@@ -336,6 +616,343 @@ func (r *Router) handleVibiumFind(session *BrowserSession, cmd bidiCommand) {
 	})
 }
 
+// handleVibiumContent handles the vibium:content command.
+// It returns the page (or the element matching "selector") as Markdown,
+// plain text or HTML.
+func (r *Router) handleVibiumContent(session *BrowserSession, cmd bidiCommand) {
+	context, _ := cmd.Params["context"].(string)
+	selector, _ := cmd.Params["selector"].(string)
+	formatName, _ := cmd.Params["format"].(string)
+	maxLength, _ := cmd.Params["maxLength"].(float64)
+
+	format, err := features.ParseContentFormat(formatName)
+	if err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: err.Error()})
+		return
+	}
+
+	if context == "" {
+		ctx, err := r.getContext(session)
+		if err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		context = ctx
+	}
+
+	params := map[string]interface{}{
+		"functionDeclaration": features.ContentScript,
+		"target":              map[string]interface{}{"context": context},
+		"arguments": []map[string]interface{}{
+			{"type": "string", "value": selector},
+			{"type": "string", "value": string(format)},
+		},
+		"awaitPromise":    false,
+		"resultOwnership": "none",
+	}
+
+	resp, err := r.sendInternalCommand(session, "script.callFunction", params)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	result, err := internalResult(resp)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	var callResult struct {
+		Type   string `json:"type"`
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+		ExceptionDetails struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &callResult); err != nil {
+		r.sendError(session, cmd.ID, fmt.Errorf("failed to parse script.callFunction result: %w", err))
+		return
+	}
+	if callResult.Type == "exception" {
+		r.sendError(session, cmd.ID, &errs.ScriptExceptionError{Message: callResult.ExceptionDetails.Text})
+		return
+	}
+
+	content, err := features.ParseContentResult(callResult.Result.Value, features.ContentOptions{
+		Format:    format,
+		Selector:  selector,
+		MaxLength: int(maxLength),
+	})
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, content)
+}
+
+// handleVibiumEmulate handles the vibium:emulate command.
+// Without a context, the overrides apply to every tab of the session.
+func (r *Router) handleVibiumEmulate(session *BrowserSession, cmd bidiCommand) {
+	context, _ := cmd.Params["context"].(string)
+
+	var opts bidi.EmulationOptions
+	data, _ := json.Marshal(cmd.Params)
+	if err := json.Unmarshal(data, &opts); err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid emulation params: %v", err)})
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: err.Error()})
+		return
+	}
+
+	// Preload scripts only run in new documents, so the current one needs
+	// the fallback too
+	current := context
+	if current == "" {
+		ctx, err := r.getContext(session)
+		if err != nil {
+			r.sendError(session, cmd.ID, err)
+			return
+		}
+		current = ctx
+	}
+
+	if err := r.emulate(session, &opts, context, current); err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"emulated": true})
+}
+
+// emulate applies emulation overrides to a browsing context of the session,
+// or with context "" to all of them. Overrides use BiDi emulation.* commands
+// where Chrome supports them and fall back to a preload script for the
+// rest, which also runs right away in the current browsing context unless
+// it's "".
+func (r *Router) emulate(session *BrowserSession, opts *bidi.EmulationOptions, context, current string) error {
+	var contexts, userContexts []string
+	if context != "" {
+		contexts = []string{context}
+	} else if session.userContext != "" {
+		userContexts = []string{session.userContext}
+	}
+
+	// Color scheme and reduced motion can only be emulated from script
+	fallback := &bidi.EmulationOptions{
+		ColorScheme:   opts.ColorScheme,
+		ReducedMotion: opts.ReducedMotion,
+	}
+	for _, ec := range bidi.EmulationCommands(opts, contexts, userContexts) {
+		resp, err := r.sendInternalCommand(session, ec.Method, ec.Params)
+		if err == nil {
+			err = responseError(resp)
+		}
+		if err == nil {
+			continue
+		}
+		if !bidi.IsUnknownCommand(err) {
+			return err
+		}
+		switch ec.Field {
+		case "geolocation":
+			fallback.Geolocation = opts.Geolocation
+		case "timezone":
+			fallback.Timezone = opts.Timezone
+		case "locale":
+			fallback.Locale = opts.Locale
+		}
+	}
+
+	script := bidi.EmulationPreloadScript(fallback)
+	if script == "" {
+		return nil
+	}
+	preloadParams := map[string]interface{}{
+		"functionDeclaration": script,
+	}
+	if len(contexts) > 0 {
+		preloadParams["contexts"] = contexts
+	}
+	resp, err := r.sendInternalCommand(session, "script.addPreloadScript", preloadParams)
+	if err == nil {
+		err = responseError(resp)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add emulation preload script: %w", err)
+	}
+
+	if current == "" {
+		return nil
+	}
+	resp, err = r.sendInternalCommand(session, "script.callFunction", map[string]interface{}{
+		"functionDeclaration": script,
+		"target":              map[string]interface{}{"context": current},
+		"awaitPromise":        false,
+	})
+	if err == nil {
+		err = responseError(resp)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply emulation: %w", err)
+	}
+	return nil
+}
+
+// handleVibiumAddInitScript handles the vibium:addInitScript command.
+// The script source runs in every new document before any page script.
+func (r *Router) handleVibiumAddInitScript(session *BrowserSession, cmd bidiCommand) {
+	source, _ := cmd.Params["script"].(string)
+	if source == "" {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "script is required"})
+		return
+	}
+
+	params := map[string]interface{}{
+		"functionDeclaration": bidi.InitScriptDeclaration(source),
+	}
+	if contexts, ok := cmd.Params["contexts"].([]interface{}); ok && len(contexts) > 0 {
+		params["contexts"] = contexts
+	}
+
+	resp, err := r.sendInternalCommand(session, "script.addPreloadScript", params)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	result, err := internalResult(resp)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	var added bidi.AddPreloadScriptResult
+	if err := json.Unmarshal(result, &added); err != nil {
+		r.sendError(session, cmd.ID, fmt.Errorf("failed to parse script.addPreloadScript result: %w", err))
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"script": added.Script})
+}
+
+// handleVibiumCookiesGet handles the vibium:cookies.get command.
+func (r *Router) handleVibiumCookiesGet(session *BrowserSession, cmd bidiCommand) {
+	resp, err := r.sendInternalCommand(session, "storage.getCookies", bidi.GetCookiesParams(cookieFilterFromParams(cmd.Params)))
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	result, err := internalResult(resp)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	cookies, err := bidi.ParseCookies(result)
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"cookies": cookies})
+}
+
+// handleVibiumCookiesSet handles the vibium:cookies.set command.
+// The cookie domain is taken from "domain", or from the host of "url".
+func (r *Router) handleVibiumCookiesSet(session *BrowserSession, cmd bidiCommand) {
+	var cookie bidi.Cookie
+	data, _ := json.Marshal(cmd.Params)
+	if err := json.Unmarshal(data, &cookie); err != nil {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid cookie params: %v", err)})
+		return
+	}
+
+	if cookie.Domain == "" {
+		if rawURL, _ := cmd.Params["url"].(string); rawURL != "" {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid url: %v", err)})
+				return
+			}
+			cookie.Domain = u.Hostname()
+		}
+	}
+	if cookie.Name == "" || cookie.Domain == "" {
+		r.sendError(session, cmd.ID, &errs.InvalidArgumentError{Message: "cookie name and domain (or url) are required"})
+		return
+	}
+
+	resp, err := r.sendInternalCommand(session, "storage.setCookie", bidi.SetCookieParams(cookie))
+	if err == nil {
+		err = responseError(resp)
+	}
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"set": true})
+}
+
+// handleVibiumCookiesDelete handles the vibium:cookies.delete command.
+// Without filter params, all cookies are deleted.
+func (r *Router) handleVibiumCookiesDelete(session *BrowserSession, cmd bidiCommand) {
+	resp, err := r.sendInternalCommand(session, "storage.deleteCookies", bidi.DeleteCookiesParams(cookieFilterFromParams(cmd.Params)))
+	if err == nil {
+		err = responseError(resp)
+	}
+	if err != nil {
+		r.sendError(session, cmd.ID, err)
+		return
+	}
+
+	r.sendSuccess(session, cmd.ID, map[string]interface{}{"deleted": true})
+}
+
+// cookieFilterFromParams builds a cookie filter from command params.
+func cookieFilterFromParams(params map[string]interface{}) bidi.CookieFilter {
+	name, _ := params["name"].(string)
+	domain, _ := params["domain"].(string)
+	path, _ := params["path"].(string)
+	return bidi.CookieFilter{Name: name, Domain: domain, Path: path}
+}
+
+// internalResult returns the result field of a BiDi response, or its error.
+func internalResult(resp json.RawMessage) (json.RawMessage, error) {
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return parsed.Result, nil
+}
+
+// responseError returns the error carried by a BiDi error response, if any.
+func responseError(resp json.RawMessage) error {
+	var result struct {
+		Error      string `json:"error"`
+		Message    string `json:"message"`
+		Stacktrace string `json:"stacktrace"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Error != "" {
+		return &errs.ProtocolError{Code: result.Error, Message: result.Message, Stacktrace: result.Stacktrace}
+	}
+	return nil
+}
+
 // elementInfo holds parsed element information.
 type elementInfo struct {
 	Tag  string  `json:"tag"`
@@ -373,64 +990,75 @@ func (r *Router) getContext(session *BrowserSession) (string, error) {
 	return result.Result.Contexts[0].Context, nil
 }
 
-// waitForElement polls until an element is found or timeout.
-func (r *Router) waitForElement(session *BrowserSession, context, selector string, timeout time.Duration) (*elementInfo, error) {
+// waitForElement polls until an element is found and passes the
+// actionability checks, or timeout. At the timeout, the error says whether
+// the element was missing or which check it failed.
+func (r *Router) waitForElement(session *BrowserSession, context, selector string, checks []features.Check, timeout time.Duration) (*elementInfo, error) {
 	deadline := time.Now().Add(timeout)
 	interval := 100 * time.Millisecond
 
-	findScript := `
-		(selector) => {
-			const el = document.querySelector(selector);
-			if (!el) return null;
-			const rect = el.getBoundingClientRect();
-			return JSON.stringify({
-				tag: el.tagName.toLowerCase(),
-				text: (el.textContent || '').trim().substring(0, 100),
-				box: {
-					x: rect.x,
-					y: rect.y,
-					width: rect.width,
-					height: rect.height
-				}
-			});
-		}
-	`
+	checkNames := make([]map[string]interface{}, len(checks))
+	for i, check := range checks {
+		checkNames[i] = map[string]interface{}{"type": "string", "value": check.String()}
+	}
 
+	var failed *errs.ElementNotInteractableError
+	var lastErr error
 	for {
 		params := map[string]interface{}{
-			"functionDeclaration": findScript,
+			"functionDeclaration": features.ActionableScript,
 			"target":              map[string]interface{}{"context": context},
 			"arguments": []map[string]interface{}{
 				{"type": "string", "value": selector},
+				{"type": "array", "value": checkNames},
 			},
-			"awaitPromise":    false,
+			"awaitPromise":    true,
 			"resultOwnership": "root",
 		}
 
+		failed = nil
 		resp, err := r.sendInternalCommand(session, "script.callFunction", params)
 		if err == nil {
-			// The BiDi response structure is nested:
-			// { "result": { "realm": "...", "result": { "type": "string", "value": "..." } } }
+			resp, err = internalResult(resp)
+		}
+		lastErr = err
+		if err == nil {
+			// { "realm": "...", "result": { "type": "object", "value": [...] } }
 			var result struct {
-				Result struct {
-					Result struct {
-						Type  string `json:"type"`
-						Value string `json:"value,omitempty"`
-					} `json:"result"`
-				} `json:"result"`
+				Result json.RawMessage `json:"result"`
 			}
-			if err := json.Unmarshal(resp, &result); err == nil {
-				if result.Result.Result.Type == "string" && result.Result.Result.Value != "" {
-					var info elementInfo
-					if err := json.Unmarshal([]byte(result.Result.Result.Value), &info); err == nil {
-						return &info, nil
-					}
+			var info *struct {
+				elementInfo
+				Invalid string `json:"invalid"`
+				Failed  string `json:"failed"`
+				Reason  string `json:"reason"`
+			}
+			if err := json.Unmarshal(resp, &result); err == nil && bidi.UnmarshalRemoteValue(result.Result, &info) == nil && info != nil {
+				if info.Invalid != "" {
+					return nil, &errs.InvalidArgumentError{Message: fmt.Sprintf("invalid selector '%s': %s", selector, info.Invalid)}
+				}
+				if info.Failed == "" {
+					return &info.elementInfo, nil
+				}
+				failed = &errs.ElementNotInteractableError{
+					Selector: selector,
+					Check:    info.Failed,
+					Reason:   info.Reason,
+					Timeout:  timeout,
 				}
 			}
 		}
 
 		if time.Now().After(deadline) {
-			return nil, fmt.Errorf("timeout after %s waiting for '%s': element not found", timeout, selector)
+			if failed != nil {
+				return nil, failed
+			}
+			// e.g. "no such frame" for a closed context
+			var protocolErr *errs.ProtocolError
+			if errors.As(lastErr, &protocolErr) {
+				return nil, lastErr
+			}
+			return nil, &errs.ElementNotFoundError{Selector: selector, Context: context, Timeout: timeout}
 		}
 
 		time.Sleep(interval)
@@ -439,32 +1067,60 @@ func (r *Router) waitForElement(session *BrowserSession, context, selector strin
 
 // sendSuccess sends a successful response to the client.
 func (r *Router) sendSuccess(session *BrowserSession, id int, result interface{}) {
+	session.untrackVibiumCommand(id)
 	resp := bidiResponse{ID: id, Type: "success", Result: result}
 	data, _ := json.Marshal(resp)
-	session.Client.Send(string(data))
+	session.send(string(data))
+}
+
+// trackVibiumCommand remembers a vibium: command until it is answered, so
+// errors are counted by method.
+func (session *BrowserSession) trackVibiumCommand(cmd bidiCommand) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.vibiumCmds == nil {
+		session.vibiumCmds = make(map[int]string)
+	}
+	session.vibiumCmds[cmd.ID] = cmd.Method
+}
+
+// untrackVibiumCommand forgets an answered command. Returns its method if
+// it was a vibium: command.
+func (session *BrowserSession) untrackVibiumCommand(id int) string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	method := session.vibiumCmds[id]
+	delete(session.vibiumCmds, id)
+	return method
 }
 
-// sendError sends an error response to the client (follows WebDriver BiDi spec).
+// sendError sends an error response to the client (follows WebDriver BiDi
+// spec), with the error code for err.
 func (r *Router) sendError(session *BrowserSession, id int, err error) {
-	resp := bidiResponse{
-		ID:      id,
-		Type:    "error",
-		Error:   "timeout",
-		Message: err.Error(),
+	if method := session.untrackVibiumCommand(id); method != "" {
+		r.metrics.vibiumError(method)
 	}
-	data, _ := json.Marshal(resp)
-	session.Client.Send(string(data))
+	session.send(newBidiError(&id, err).marshal())
 }
 
 // OnClientDisconnect is called when a client disconnects.
-// It closes the browser session.
+// It closes the browser session, or with WithKeepAlive keeps it for the
+// client to resume.
 func (r *Router) OnClientDisconnect(client *ClientConn) {
-	sessionVal, ok := r.sessions.LoadAndDelete(client.ID)
+	if r.observerDisconnected(client) {
+		return
+	}
+
+	sessionVal, ok := r.sessions.Load(client.ID)
 	if !ok {
 		return
 	}
 
 	session := sessionVal.(*BrowserSession)
+	if r.keepSession(session, client) {
+		return
+	}
+	r.sessions.CompareAndDelete(client.ID, session)
 	r.closeSession(session)
 }
 
@@ -484,12 +1140,13 @@ func (r *Router) routeBrowserToClient(session *BrowserSession) {
 			session.mu.Unlock()
 
 			if !closed {
-				fmt.Printf("[router] Browser connection closed for client %d: %v\n", session.Client.ID, err)
-				// Browser died, close the client
-				session.Client.Close()
+				fmt.Printf("[router] Browser connection closed for client %d: %v\n", session.client().ID, err)
+				// Browser died, close the session and the client
+				r.endSession(session)
 			}
 			return
 		}
+		session.recorder.record(BrowserToProxy, msg)
 
 		// Check if this is a response to an internal command
 		var resp struct {
@@ -507,9 +1164,17 @@ func (r *Router) routeBrowserToClient(session *BrowserSession) {
 			}
 		}
 
+		// Enforce the URL policy
+		if session.policyIntercept != "" && r.handlePolicyEvent(session, msg) {
+			continue
+		}
+		if session.sendObserverOnlyEvent(msg) {
+			continue
+		}
+
 		// Forward message to client
-		if err := session.Client.Send(msg); err != nil {
-			fmt.Printf("[router] Failed to send to client %d: %v\n", session.Client.ID, err)
+		if err := session.send(msg); err != nil {
+			fmt.Printf("[router] Failed to send to client %d: %v\n", session.client().ID, err)
 			return
 		}
 	}
@@ -517,6 +1182,10 @@ func (r *Router) routeBrowserToClient(session *BrowserSession) {
 
 // sendInternalCommand sends a BiDi command and waits for the response.
 func (r *Router) sendInternalCommand(session *BrowserSession, method string, params map[string]interface{}) (json.RawMessage, error) {
+	if session.shared != nil {
+		return session.shared.call(session, method, params)
+	}
+
 	session.internalCmdsMu.Lock()
 	id := session.nextInternalID
 	session.nextInternalID++
@@ -537,7 +1206,7 @@ func (r *Router) sendInternalCommand(session *BrowserSession, method string, par
 		"params": params,
 	}
 	cmdBytes, _ := json.Marshal(cmd)
-	if err := session.BidiConn.Send(string(cmdBytes)); err != nil {
+	if err := session.sendToBrowser(string(cmdBytes)); err != nil {
 		return nil, err
 	}
 
@@ -545,8 +1214,8 @@ func (r *Router) sendInternalCommand(session *BrowserSession, method string, par
 	select {
 	case resp := <-ch:
 		return resp, nil
-	case <-time.After(60 * time.Second):
-		return nil, fmt.Errorf("timeout waiting for response to %s", method)
+	case <-time.After(internalCommandTimeout):
+		return nil, &errs.TimeoutError{Timeout: internalCommandTimeout, Reason: "no response to " + method}
 	case <-session.stopChan:
 		return nil, fmt.Errorf("session closed")
 	}
@@ -562,10 +1231,30 @@ func (r *Router) closeSession(session *BrowserSession) {
 	session.closed = true
 	session.mu.Unlock()
 
-	fmt.Printf("[router] Closing browser session for client %d\n", session.Client.ID)
+	fmt.Printf("[router] Closing browser session for client %d\n", session.client().ID)
+	defer session.recorder.close()
 
 	// Signal the routing goroutine to stop
 	close(session.stopChan)
+	r.closeObservers(session)
+
+	// The session can't be resumed anymore
+	if session.resumeToken != "" {
+		r.resumable.Delete(session.resumeToken)
+		session.clientMu.Lock()
+		if session.expiry != nil {
+			session.expiry.Stop()
+		}
+		session.clientMu.Unlock()
+	}
+
+	if session.shared != nil {
+		// Close the session's tabs, but not the browser
+		session.shared.detach(session)
+		r.pool.release()
+		fmt.Printf("[router] Browser session closed for client %d\n", session.client().ID)
+		return
+	}
 
 	// Close BiDi connection
 	if session.BidiConn != nil {
@@ -577,15 +1266,21 @@ func (r *Router) closeSession(session *BrowserSession) {
 		session.LaunchResult.Close()
 	}
 
-	fmt.Printf("[router] Browser session closed for client %d\n", session.Client.ID)
+	// Free the session's slot and launch a replacement browser
+	r.pool.release()
+
+	fmt.Printf("[router] Browser session closed for client %d\n", session.client().ID)
 }
 
-// CloseAll closes all browser sessions.
+// CloseAll closes all browser sessions and the pool's idle browsers.
 func (r *Router) CloseAll() {
+	r.pool.close()
+
 	r.sessions.Range(func(key, value interface{}) bool {
 		session := value.(*BrowserSession)
 		r.closeSession(session)
 		r.sessions.Delete(key)
 		return true
 	})
+	r.closeShared()
 }
@@ -2,11 +2,15 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
@@ -15,37 +19,93 @@ import (
 // This accommodates large screenshots from high-resolution displays (e.g., retina, 4K).
 const maxMessageSize = 10 * 1024 * 1024
 
+// maxCloseReason is the longest reason that fits in a close frame: control
+// frames carry at most 125 bytes, 2 of them for the status code.
+const maxCloseReason = 123
+
 // Server is a WebSocket server that accepts client connections.
 type Server struct {
-	port       int
-	httpServer *http.Server
-	upgrader   websocket.Upgrader
-	clients    sync.Map // map[uint64]*ClientConn
-	nextID     atomic.Uint64
-	onConnect  func(*ClientConn)
-	onMessage  func(*ClientConn, string)
-	onClose    func(*ClientConn)
+	host           string
+	port           int
+	token          string
+	allowedOrigins []string
+	tlsCert        string
+	tlsKey         string
+	control        http.Handler
+	httpServer     *http.Server
+	upgrader       websocket.Upgrader
+	clients        sync.Map // map[uint64]*ClientConn
+	nextID         atomic.Uint64
+	onConnect      func(*ClientConn)
+	onMessage      func(*ClientConn, string)
+	onClose        func(*ClientConn)
 }
 
 // ClientConn represents a connected WebSocket client.
 type ClientConn struct {
-	ID     uint64
-	conn   *websocket.Conn
-	mu     sync.Mutex
-	closed bool
-	server *Server
+	ID          uint64
+	ResumeToken string // Session to resume, from the "resume" query parameter
+	Observe     string // Client whose session to observe, from the "observe" query parameter
+	conn        *websocket.Conn
+	mu          sync.Mutex
+	closed      bool
+	server      *Server
 }
 
 // ServerOption configures a Server.
 type ServerOption func(*Server)
 
-// WithPort sets the port for the server.
+// WithPort sets the port for the server. With 0, the server listens on a
+// free port, see Port.
 func WithPort(port int) ServerOption {
 	return func(s *Server) {
 		s.port = port
 	}
 }
 
+// WithHost sets the address the server listens on. Defaults to 127.0.0.1,
+// so only local programs can connect.
+func WithHost(host string) ServerOption {
+	return func(s *Server) {
+		s.host = host
+	}
+}
+
+// WithToken requires clients to authenticate with the token, as an
+// "Authorization: Bearer <token>" header or a "token" query parameter.
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithAllowedOrigins allows web pages from the origins (e.g.
+// "http://localhost:3000") to connect. Pages from other origins are
+// rejected; "*" allows all. Programs that send no Origin header are
+// always allowed.
+func WithAllowedOrigins(origins []string) ServerOption {
+	return func(s *Server) {
+		s.allowedOrigins = origins
+	}
+}
+
+// WithTLS serves wss:// with the certificate and key files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.tlsCert = certFile
+		s.tlsKey = keyFile
+	}
+}
+
+// WithControlHandler serves the handler's /healthz and /readyz endpoints
+// to anyone, and its /sessions and /metrics endpoints to clients with the
+// token, next to the WebSocket endpoint.
+func WithControlHandler(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.control = h
+	}
+}
+
 // WithOnConnect sets a callback for when a client connects.
 func WithOnConnect(fn func(*ClientConn)) ServerOption {
 	return func(s *Server) {
@@ -70,19 +130,18 @@ func WithOnClose(fn func(*ClientConn)) ServerOption {
 // NewServer creates a new WebSocket server.
 func NewServer(opts ...ServerOption) *Server {
 	s := &Server{
-		port: 9515, // default port
+		host: "127.0.0.1", // loopback only
+		port: 9515,        // default port
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  maxMessageSize,
 			WriteBufferSize: maxMessageSize,
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins
-			},
 		},
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
+	s.upgrader.CheckOrigin = s.checkOrigin
 
 	return s
 }
@@ -96,13 +155,36 @@ func (s *Server) Port() int {
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleWebSocket)
+	if s.control != nil {
+		// Probes don't send credentials, and learn nothing but up or down
+		mux.Handle("/healthz", s.control)
+		mux.Handle("/readyz", s.control)
+		mux.Handle("/sessions", s.requireToken(s.control))
+		mux.Handle("/sessions/", s.requireToken(s.control))
+		mux.Handle("/metrics", s.requireToken(s.control))
+	}
 
-	addr := fmt.Sprintf(":%d", s.port)
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+
+	// Load the certificate before binding, so a bad file fails fast
+	var tlsConfig *tls.Config
+	if s.tlsCert != "" || s.tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(s.tlsCert, s.tlsKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
 
 	// Try to bind to the port to check availability
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to listen on port %d: %w", s.port, err)
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	// With port 0, the system picked a free port
+	s.port = listener.Addr().(*net.TCPAddr).Port
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
 	}
 
 	s.httpServer = &http.Server{
@@ -132,7 +214,33 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
+// URL returns the URL clients connect to, without the token.
+func (s *Server) URL() string {
+	scheme := "ws"
+	if s.tlsCert != "" {
+		scheme = "wss"
+	}
+
+	host := s.host
+	switch host {
+	case "127.0.0.1", "::1", "":
+		host = "localhost"
+	case "0.0.0.0", "::":
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(s.port)))
+}
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		fmt.Printf("[proxy] Rejected unauthenticated client from %s\n", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Printf("WebSocket upgrade error: %v\n", err)
@@ -143,9 +251,11 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn.SetReadLimit(maxMessageSize)
 
 	client := &ClientConn{
-		ID:     s.nextID.Add(1),
-		conn:   conn,
-		server: s,
+		ID:          s.nextID.Add(1),
+		ResumeToken: r.URL.Query().Get("resume"),
+		Observe:     r.URL.Query().Get("observe"),
+		conn:        conn,
+		server:      s,
 	}
 
 	s.clients.Store(client.ID, client)
@@ -202,6 +312,25 @@ func (c *ClientConn) Send(msg string) error {
 
 // Close closes the client connection.
 func (c *ClientConn) Close() error {
+	return c.CloseWithReason(websocket.CloseNormalClosure, "")
+}
+
+// cutCloseReason cuts a close reason to fit a control frame, at a rune
+// boundary so it stays valid UTF-8.
+func cutCloseReason(reason string) string {
+	if len(reason) <= maxCloseReason {
+		return reason
+	}
+	n := maxCloseReason
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
+
+// CloseWithReason closes the client connection with a close frame carrying
+// the status code and reason. The reason is cut to fit a control frame.
+func (c *ClientConn) CloseWithReason(code int, reason string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -211,8 +340,10 @@ func (c *ClientConn) Close() error {
 
 	c.closed = true
 
+	reason = cutCloseReason(reason)
+
 	// Send close message
-	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
 
 	return c.conn.Close()
 }
@@ -0,0 +1,855 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibium/clicker/internal/bidi"
+	"github.com/vibium/clicker/internal/browser"
+	errs "github.com/vibium/clicker/internal/errors"
+	"github.com/vibium/clicker/internal/features"
+)
+
+// ownershipEvents are the events a shared browser subscribes to so it knows
+// which client each browsing context and realm belongs to.
+var ownershipEvents = []string{
+	"browsingContext.contextCreated",
+	"browsingContext.contextDestroyed",
+	"script.realmCreated",
+	"script.realmDestroyed",
+}
+
+// blockedSharedCommands would affect other clients of a shared browser.
+var blockedSharedCommands = map[string]bool{
+	"session.new":                  true,
+	"session.end":                  true,
+	"browser.close":                true,
+	"browser.createUserContext":    true,
+	"browser.removeUserContext":    true,
+	"browser.setClientWindowState": true, // Windows hold tabs of several clients
+}
+
+// endedRequestEvents are the network events after which a request can't be
+// used in commands anymore.
+var endedRequestEvents = map[string]bool{
+	"network.responseCompleted": true,
+	"network.fetchError":        true,
+}
+
+// WithSharedBrowsers runs up to n browsers shared by all clients instead of
+// one browser per client. Each client gets its own BiDi user context, so
+// cookies and storage are isolated, and only sees its own browsing contexts.
+func WithSharedBrowsers(n int) RouterOption {
+	return func(r *Router) {
+		r.sharedCount = n
+	}
+}
+
+// sharedBrowser is a browser whose BiDi connection is multiplexed between
+// clients. Command IDs are rewritten so clients can't collide, and events
+// and results are filtered by the client owning each browsing context.
+type sharedBrowser struct {
+	launchResult    *browser.LaunchResult
+	conn            *bidi.Connection
+	policyIntercept string
+	done            chan struct{} // Closed when the browser is gone
+
+	mu             sync.Mutex
+	nextID         int
+	pending        map[int]*sharedCommand
+	sessions       map[*BrowserSession]bool
+	userContexts   map[string]*BrowserSession
+	contexts       map[string]ownedContext
+	realms         map[string]*BrowserSession
+	requests       map[string]*BrowserSession // Requests seen in events of the session's contexts
+	intercepts     map[string]*BrowserSession
+	preloadScripts map[string]*BrowserSession
+	closed         bool
+}
+
+// sharedCommand is a command sent on a shared connection, waiting for its
+// response.
+type sharedCommand struct {
+	session  *BrowserSession // Nil for the router's own commands
+	method   string
+	clientID int                  // ID the client used, for forwarded commands
+	ch       chan json.RawMessage // Set for internal commands
+}
+
+// ownedContext is a browsing context of a client.
+type ownedContext struct {
+	session  *BrowserSession
+	topLevel bool
+}
+
+// commandError is a BiDi error returned to a client for a command the
+// proxy doesn't allow, e.g. in a shared browser or against the URL policy.
+type commandError struct {
+	code    string
+	message string
+}
+
+func (e *commandError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// connectShared starts a client's session in a user context of a shared
+// browser.
+func (r *Router) connectShared(client *ClientConn) {
+	if err := r.pool.reserve(); err != nil {
+		r.rejectClient(client, err)
+		return
+	}
+
+	sb, err := r.sharedBrowserFor()
+	if err != nil {
+		r.pool.release()
+		r.rejectClient(client, err)
+		return
+	}
+
+	session := &BrowserSession{
+		Client:       client,
+		stopChan:     make(chan struct{}),
+		started:      time.Now(),
+		internalCmds: make(map[int]chan json.RawMessage),
+	}
+	if err := r.attachShared(sb, session); err != nil {
+		sb.detach(session)
+		r.pool.release()
+		r.rejectClient(client, err)
+		return
+	}
+
+	fmt.Printf("[router] Browser session started for client %d in shared browser, user context %s\n", client.ID, session.userContext)
+
+	r.startSession(session)
+}
+
+// sharedBrowserFor returns the shared browser with the fewest clients,
+// launching another one if all are in use and fewer than sharedCount run.
+func (r *Router) sharedBrowserFor() (*sharedBrowser, error) {
+	r.sharedMu.Lock()
+	defer r.sharedMu.Unlock()
+
+	var least *sharedBrowser
+	leastCount := 0
+	for _, sb := range r.shared {
+		sb.mu.Lock()
+		count := len(sb.sessions)
+		sb.mu.Unlock()
+		if least == nil || count < leastCount {
+			least, leastCount = sb, count
+		}
+	}
+	if least != nil && (leastCount == 0 || len(r.shared) >= r.sharedCount) {
+		return least, nil
+	}
+
+	sb, err := r.launchSharedBrowser()
+	if err != nil {
+		return nil, err
+	}
+	r.shared = append(r.shared, sb)
+	return sb, nil
+}
+
+// launchSharedBrowser launches a browser and starts routing its messages.
+func (r *Router) launchSharedBrowser() (*sharedBrowser, error) {
+	fmt.Printf("[router] Launching shared browser %d...\n", len(r.shared)+1)
+
+	b, err := r.launchBrowserOnly()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.bidiClient.Subscribe(ownershipEvents, nil); err != nil {
+		b.close()
+		return nil, fmt.Errorf("failed to subscribe to browser events: %w", err)
+	}
+	if r.policy != nil {
+		if b.policyIntercept, err = r.enforcePolicy(b.bidiClient); err != nil {
+			b.close()
+			return nil, fmt.Errorf("failed to enforce URL policy: %w", err)
+		}
+	}
+
+	sb := &sharedBrowser{
+		launchResult:    b.launchResult,
+		conn:            b.bidiConn,
+		policyIntercept: b.policyIntercept,
+		done:            make(chan struct{}),
+		nextID:          1000000, // Above the IDs the bidi.Client used during setup
+		pending:         make(map[int]*sharedCommand),
+		sessions:        make(map[*BrowserSession]bool),
+		userContexts:    make(map[string]*BrowserSession),
+		contexts:        make(map[string]ownedContext),
+		realms:          make(map[string]*BrowserSession),
+		requests:        make(map[string]*BrowserSession),
+		intercepts:      make(map[string]*BrowserSession),
+		preloadScripts:  make(map[string]*BrowserSession),
+	}
+
+	fmt.Printf("[router] Shared browser launched, WebSocket: %s\n", sb.launchResult.WebSocketURL)
+
+	go r.routeSharedBrowser(sb)
+	return sb, nil
+}
+
+// attachShared gives a session its own user context in a shared browser,
+// with emulation, init scripts, storage state and a first tab.
+func (r *Router) attachShared(sb *sharedBrowser, session *BrowserSession) error {
+	sb.mu.Lock()
+	if sb.closed {
+		sb.mu.Unlock()
+		return fmt.Errorf("shared browser is closed")
+	}
+	sb.sessions[session] = true
+	sb.mu.Unlock()
+
+	session.shared = sb
+	session.BidiConn = sb.conn
+	session.LaunchResult = sb.launchResult
+	session.policyIntercept = sb.policyIntercept
+
+	result, err := sb.command(nil, "browser.createUserContext", map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("failed to create user context: %w", err)
+	}
+	var created struct {
+		UserContext string `json:"userContext"`
+	}
+	if err := json.Unmarshal(result, &created); err != nil || created.UserContext == "" {
+		return fmt.Errorf("failed to parse browser.createUserContext result")
+	}
+
+	sb.mu.Lock()
+	session.userContext = created.UserContext
+	sb.userContexts[created.UserContext] = session
+	sb.mu.Unlock()
+
+	// Emulation, init scripts and storage state before the first document loads
+	if !r.emulation.IsEmpty() {
+		if err := r.emulate(session, r.emulation, "", ""); err != nil {
+			return fmt.Errorf("failed to apply emulation: %w", err)
+		}
+	}
+	for _, source := range r.initScripts {
+		if _, err := sb.command(session, "script.addPreloadScript", map[string]interface{}{
+			"functionDeclaration": bidi.InitScriptDeclaration(source),
+		}); err != nil {
+			return fmt.Errorf("failed to add init script: %w", err)
+		}
+	}
+	if state := sb.launchResult.StorageState; state != nil {
+		if err := sb.applyStorageState(session, state); err != nil {
+			return fmt.Errorf("failed to restore storage state: %w", err)
+		}
+	}
+
+	if _, err := sb.command(session, "browsingContext.create", map[string]interface{}{
+		"type": "tab",
+	}); err != nil {
+		return fmt.Errorf("failed to create tab: %w", err)
+	}
+
+	return nil
+}
+
+// applyStorageState restores cookies and Web Storage into a session's user
+// context, like features.ApplyStorageState does for a whole browser.
+func (sb *sharedBrowser) applyStorageState(session *BrowserSession, state *features.StorageState) error {
+	for _, cookie := range state.Cookies {
+		if _, err := sb.command(session, "storage.setCookie", bidi.SetCookieParams(cookie)); err != nil {
+			return fmt.Errorf("failed to set cookie %s: %w", cookie.Name, err)
+		}
+	}
+
+	script, err := features.StorageStatePreloadScript(state)
+	if err != nil || script == "" {
+		return err
+	}
+	_, err = sb.command(session, "script.addPreloadScript", map[string]interface{}{
+		"functionDeclaration": script,
+	})
+	return err
+}
+
+// detach removes a session's user context, closing its tabs.
+func (sb *sharedBrowser) detach(session *BrowserSession) {
+	sb.mu.Lock()
+	delete(sb.sessions, session)
+	delete(sb.userContexts, session.userContext)
+	for id, owned := range sb.contexts {
+		if owned.session == session {
+			delete(sb.contexts, id)
+		}
+	}
+	for _, owners := range []map[string]*BrowserSession{sb.realms, sb.requests, sb.intercepts, sb.preloadScripts} {
+		for id, owner := range owners {
+			if owner == session {
+				delete(owners, id)
+			}
+		}
+	}
+	sb.mu.Unlock()
+
+	if session.userContext == "" {
+		return
+	}
+	if _, err := sb.command(nil, "browser.removeUserContext", map[string]interface{}{
+		"userContext": session.userContext,
+	}); err != nil {
+		fmt.Printf("[router] Failed to remove user context for client %d: %v\n", session.client().ID, err)
+	}
+}
+
+// close closes the browser.
+func (sb *sharedBrowser) close() {
+	sb.mu.Lock()
+	if sb.closed {
+		sb.mu.Unlock()
+		return
+	}
+	sb.closed = true
+	sb.mu.Unlock()
+
+	close(sb.done)
+	sb.conn.Close()
+	sb.launchResult.Close()
+}
+
+// forward sends a client's command to the shared browser. The response is
+// routed back with the client's command ID.
+func (sb *sharedBrowser) forward(session *BrowserSession, cmd bidiCommand) error {
+	_, err := sb.send(&sharedCommand{session: session, method: cmd.Method, clientID: cmd.ID}, cmd.Params)
+	return err
+}
+
+// command sends a command for a session (nil for the router's own) and
+// returns its result.
+func (sb *sharedBrowser) command(session *BrowserSession, method string, params map[string]interface{}) (json.RawMessage, error) {
+	resp, err := sb.call(session, method, params)
+	if err != nil {
+		return nil, err
+	}
+	return internalResult(resp)
+}
+
+// call sends a command for a session (nil for the router's own) and waits
+// for the response, like Router.sendInternalCommand.
+func (sb *sharedBrowser) call(session *BrowserSession, method string, params map[string]interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if session != nil {
+		if err := sb.scope(session, method, params); err != nil {
+			return nil, err
+		}
+	}
+
+	ch := make(chan json.RawMessage, 1)
+	id, err := sb.send(&sharedCommand{session: session, method: method, ch: ch}, params)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		sb.mu.Lock()
+		delete(sb.pending, id)
+		sb.mu.Unlock()
+	}()
+
+	var stop chan struct{}
+	if session != nil {
+		stop = session.stopChan
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-time.After(internalCommandTimeout):
+		return nil, &errs.TimeoutError{Timeout: internalCommandTimeout, Reason: "no response to " + method}
+	case <-stop:
+		return nil, fmt.Errorf("session closed")
+	case <-sb.done:
+		return nil, fmt.Errorf("browser closed")
+	}
+}
+
+// send sends a command with a new ID and registers it for the response.
+func (sb *sharedBrowser) send(cmd *sharedCommand, params map[string]interface{}) (int, error) {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	sb.mu.Lock()
+	sb.nextID++
+	id := sb.nextID
+	sb.pending[id] = cmd
+	sb.mu.Unlock()
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":     id,
+		"method": cmd.method,
+		"params": params,
+	})
+	if cmd.session != nil {
+		cmd.session.recorder.record(ProxyToBrowser, string(data))
+	}
+	if err := sb.conn.Send(string(data)); err != nil {
+		sb.mu.Lock()
+		delete(sb.pending, id)
+		sb.mu.Unlock()
+		return 0, err
+	}
+	return id, nil
+}
+
+// scope checks that a command only refers to the session's own browsing
+// contexts, realms, user context, requests, intercepts and preload scripts,
+// and limits commands that would apply to the whole browser to the
+// session's user context. params is modified.
+func (sb *sharedBrowser) scope(session *BrowserSession, method string, params map[string]interface{}) error {
+	if blockedSharedCommands[method] {
+		return &commandError{"unsupported operation", method + " is not available in a shared browser"}
+	}
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	for _, key := range []string{"context", "referenceContext", "root"} {
+		if id, ok := params[key].(string); ok && sb.contexts[id].session != session {
+			return &commandError{"no such frame", fmt.Sprintf("browsing context %s not found", id)}
+		}
+	}
+	if ids, ok := params["contexts"].([]interface{}); ok {
+		for _, v := range ids {
+			if id, _ := v.(string); sb.contexts[id].session != session {
+				return &commandError{"no such frame", fmt.Sprintf("browsing context %v not found", v)}
+			}
+		}
+	}
+	if target, ok := params["target"].(map[string]interface{}); ok {
+		if id, ok := target["context"].(string); ok && sb.contexts[id].session != session {
+			return &commandError{"no such frame", fmt.Sprintf("browsing context %s not found", id)}
+		}
+		if id, ok := target["realm"].(string); ok && sb.realms[id] != session {
+			return &commandError{"no such frame", fmt.Sprintf("realm %s not found", id)}
+		}
+	}
+
+	if err := sb.checkOwned(session, method, params); err != nil {
+		return err
+	}
+
+	if id, ok := params["userContext"].(string); ok && id != session.userContext {
+		return &commandError{"no such user context", fmt.Sprintf("user context %s not found", id)}
+	}
+	if ids, ok := params["userContexts"].([]interface{}); ok {
+		for _, v := range ids {
+			if id, _ := v.(string); id != session.userContext {
+				return &commandError{"no such user context", fmt.Sprintf("user context %v not found", v)}
+			}
+		}
+	}
+
+	// Storage commands default to the default user context, shared by all
+	if strings.HasPrefix(method, "storage.") {
+		partition, _ := params["partition"].(map[string]interface{})
+		switch {
+		case partition == nil:
+			params["partition"] = map[string]interface{}{"type": "storageKey", "userContext": session.userContext}
+		case partition["type"] == "context":
+			if id, _ := partition["context"].(string); sb.contexts[id].session != session {
+				return &commandError{"no such frame", fmt.Sprintf("browsing context %v not found", partition["context"])}
+			}
+		default:
+			if id, ok := partition["userContext"].(string); ok && id != session.userContext {
+				return &commandError{"no such user context", fmt.Sprintf("user context %s not found", id)}
+			}
+			partition["userContext"] = session.userContext
+		}
+	}
+
+	// Commands that apply to every browsing context without contexts given
+	_, hasContexts := params["contexts"]
+	_, hasUserContexts := params["userContexts"]
+	switch {
+	case method == "browsingContext.create":
+		params["userContext"] = session.userContext
+	case method == "script.addPreloadScript" || strings.HasPrefix(method, "emulation."):
+		if !hasContexts && !hasUserContexts {
+			params["userContexts"] = []interface{}{session.userContext}
+		}
+	case method == "network.addIntercept":
+		if !hasContexts {
+			var own []interface{}
+			for id, owned := range sb.contexts {
+				if owned.session == session && owned.topLevel {
+					own = append(own, id)
+				}
+			}
+			if len(own) == 0 {
+				return &commandError{"invalid argument", "network.addIntercept needs an open tab in a shared browser"}
+			}
+			params["contexts"] = own
+		}
+	}
+
+	return nil
+}
+
+// checkOwned checks that the network requests, intercepts and preload
+// scripts a command refers to are the session's, and forgets the ones the
+// command ends. Must be called with sb.mu held.
+func (sb *sharedBrowser) checkOwned(session *BrowserSession, method string, params map[string]interface{}) error {
+	if id, ok := params["request"].(string); ok && strings.HasPrefix(method, "network.") {
+		if sb.requests[id] != session {
+			return &commandError{"no such request", fmt.Sprintf("request %s not found", id)}
+		}
+		if method == "network.failRequest" || method == "network.provideResponse" {
+			delete(sb.requests, id)
+		}
+	}
+	if id, ok := params["intercept"].(string); ok && method == "network.removeIntercept" {
+		if sb.intercepts[id] != session {
+			return &commandError{"no such intercept", fmt.Sprintf("intercept %s not found", id)}
+		}
+		delete(sb.intercepts, id)
+	}
+	if id, ok := params["script"].(string); ok && method == "script.removePreloadScript" {
+		if sb.preloadScripts[id] != session {
+			return &commandError{"no such script", fmt.Sprintf("preload script %s not found", id)}
+		}
+		delete(sb.preloadScripts, id)
+	}
+	return nil
+}
+
+// routeSharedBrowser reads messages from a shared browser and routes them to
+// the clients they belong to.
+func (r *Router) routeSharedBrowser(sb *sharedBrowser) {
+	for {
+		msg, err := sb.conn.Receive()
+		if err != nil {
+			r.sharedBrowserLost(sb, err)
+			return
+		}
+
+		var envelope struct {
+			ID     *int            `json:"id"`
+			Type   string          `json:"type"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(msg), &envelope); err != nil {
+			continue
+		}
+
+		if envelope.ID != nil {
+			sb.routeResponse(msg, *envelope.ID, envelope.Result)
+		} else if envelope.Type == "event" {
+			r.routeSharedEvent(sb, msg, envelope.Method, envelope.Params)
+		}
+	}
+}
+
+// routeResponse delivers a command response with the client's command ID and
+// results filtered to the client's browsing contexts.
+func (sb *sharedBrowser) routeResponse(msg string, id int, result json.RawMessage) {
+	sb.mu.Lock()
+	cmd := sb.pending[id]
+	delete(sb.pending, id)
+	sb.mu.Unlock()
+	if cmd == nil {
+		return
+	}
+	if cmd.session != nil {
+		cmd.session.recorder.record(BrowserToProxy, msg)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(msg), &fields); err != nil {
+		return
+	}
+	if result != nil && cmd.session != nil {
+		fields["result"] = sb.filterResult(cmd.session, cmd.method, result)
+	}
+
+	if cmd.ch != nil {
+		data, _ := json.Marshal(fields)
+		cmd.ch <- json.RawMessage(data)
+		return
+	}
+
+	fields["id"], _ = json.Marshal(cmd.clientID)
+	data, _ := json.Marshal(fields)
+	if err := cmd.session.send(string(data)); err != nil {
+		fmt.Printf("[router] Failed to send to client %d: %v\n", cmd.session.client().ID, err)
+	}
+}
+
+// treeInfo is a browsing context in a browsingContext.getTree result.
+type treeInfo struct {
+	Context     string            `json:"context"`
+	UserContext string            `json:"userContext"`
+	Children    []json.RawMessage `json:"children"`
+}
+
+// filterResult removes other clients' browsing contexts, realms and user
+// contexts from a result, and records the session's new browsing contexts,
+// intercepts and preload scripts.
+func (sb *sharedBrowser) filterResult(session *BrowserSession, method string, result json.RawMessage) json.RawMessage {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	switch method {
+	case "browsingContext.create":
+		var created struct {
+			Context string `json:"context"`
+		}
+		if json.Unmarshal(result, &created) == nil && created.Context != "" {
+			sb.contexts[created.Context] = ownedContext{session: session, topLevel: true}
+		}
+
+	case "browsingContext.getTree":
+		var tree struct {
+			Contexts []json.RawMessage `json:"contexts"`
+		}
+		if json.Unmarshal(result, &tree) != nil {
+			return result
+		}
+		kept := []json.RawMessage{}
+		for _, raw := range tree.Contexts {
+			var info treeInfo
+			if json.Unmarshal(raw, &info) != nil {
+				continue
+			}
+			owned, known := sb.contexts[info.Context]
+			if known && owned.session != session || !known && info.UserContext != session.userContext {
+				continue
+			}
+			sb.ownTree(session, &info, !known || owned.topLevel)
+			kept = append(kept, raw)
+		}
+		data, _ := json.Marshal(map[string]interface{}{"contexts": kept})
+		return data
+
+	case "script.getRealms":
+		var realms struct {
+			Realms []json.RawMessage `json:"realms"`
+		}
+		if json.Unmarshal(result, &realms) != nil {
+			return result
+		}
+		kept := []json.RawMessage{}
+		for _, raw := range realms.Realms {
+			var info struct {
+				Realm   string `json:"realm"`
+				Context string `json:"context"`
+			}
+			if json.Unmarshal(raw, &info) != nil || sb.contexts[info.Context].session != session {
+				continue
+			}
+			sb.realms[info.Realm] = session
+			kept = append(kept, raw)
+		}
+		data, _ := json.Marshal(map[string]interface{}{"realms": kept})
+		return data
+
+	case "network.addIntercept":
+		var added struct {
+			Intercept string `json:"intercept"`
+		}
+		if json.Unmarshal(result, &added) == nil && added.Intercept != "" {
+			sb.intercepts[added.Intercept] = session
+		}
+
+	case "script.addPreloadScript":
+		var added struct {
+			Script string `json:"script"`
+		}
+		if json.Unmarshal(result, &added) == nil && added.Script != "" {
+			sb.preloadScripts[added.Script] = session
+		}
+
+	case "browser.getUserContexts":
+		data, _ := json.Marshal(map[string]interface{}{
+			"userContexts": []interface{}{map[string]string{"userContext": session.userContext}},
+		})
+		return data
+	}
+
+	return result
+}
+
+// ownTree records a browsing context and its children as the session's.
+// Must be called with sb.mu held.
+func (sb *sharedBrowser) ownTree(session *BrowserSession, info *treeInfo, topLevel bool) {
+	sb.contexts[info.Context] = ownedContext{session: session, topLevel: topLevel}
+	for _, raw := range info.Children {
+		var child treeInfo
+		if json.Unmarshal(raw, &child) == nil {
+			sb.ownTree(session, &child, false)
+		}
+	}
+}
+
+// routeSharedEvent forwards an event to the client owning its browsing
+// context, if the client subscribed to it.
+func (r *Router) routeSharedEvent(sb *sharedBrowser, msg, method string, params json.RawMessage) {
+	owner := sb.eventOwner(method, params)
+	if owner == nil {
+		// Don't leave requests of contexts without a client paused
+		if method == "network.beforeRequestSent" && sb.policyIntercept != "" {
+			var request bidi.BeforeRequestSentParams
+			if json.Unmarshal(params, &request) == nil && request.BlockedBy(sb.policyIntercept) {
+				go sb.call(nil, "network.continueRequest", map[string]interface{}{"request": request.Request.Request})
+			}
+		}
+		return
+	}
+	owner.recorder.record(BrowserToProxy, msg)
+
+	if owner.policyIntercept != "" && r.handlePolicyEvent(owner, msg) {
+		return
+	}
+	if owner.observerOnlyEvent(method) {
+		owner.sendToObservers(msg, method)
+		return
+	}
+	if !owner.subscribedByClient(method) {
+		return
+	}
+	if err := owner.send(msg); err != nil {
+		fmt.Printf("[router] Failed to send to client %d: %v\n", owner.client().ID, err)
+	}
+}
+
+// eventOwner returns the session an event belongs to, or nil, and tracks
+// browsing contexts and realms as they are created and destroyed, and the
+// network requests of each session.
+func (sb *sharedBrowser) eventOwner(method string, params json.RawMessage) *BrowserSession {
+	var p struct {
+		Context     string `json:"context"`
+		Parent      string `json:"parent"`
+		UserContext string `json:"userContext"`
+		Realm       string `json:"realm"`
+		Source      struct {
+			Context string `json:"context"`
+			Realm   string `json:"realm"`
+		} `json:"source"`
+		Request struct {
+			Request string `json:"request"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	switch method {
+	case "browsingContext.contextCreated":
+		owned := ownedContext{session: sb.userContexts[p.UserContext], topLevel: p.Parent == ""}
+		if p.Parent != "" {
+			owned.session = sb.contexts[p.Parent].session
+		}
+		if owned.session != nil {
+			sb.contexts[p.Context] = owned
+		}
+		return owned.session
+
+	case "browsingContext.contextDestroyed":
+		owner := sb.contexts[p.Context].session
+		delete(sb.contexts, p.Context)
+		return owner
+
+	case "script.realmCreated":
+		owner := sb.contexts[p.Context].session
+		if owner != nil {
+			sb.realms[p.Realm] = owner
+		}
+		return owner
+
+	case "script.realmDestroyed":
+		owner := sb.realms[p.Realm]
+		delete(sb.realms, p.Realm)
+		return owner
+	}
+
+	owner := sb.contextOwner(p.Context, p.Source.Context, p.Realm, p.Source.Realm)
+	if request := p.Request.Request; request != "" && strings.HasPrefix(method, "network.") {
+		switch {
+		case owner == nil || endedRequestEvents[method]:
+			delete(sb.requests, request)
+		default:
+			sb.requests[request] = owner
+		}
+	}
+	return owner
+}
+
+// contextOwner returns the session owning the first known browsing context
+// or realm, or nil. Must be called with sb.mu held.
+func (sb *sharedBrowser) contextOwner(context, sourceContext, realm, sourceRealm string) *BrowserSession {
+	for _, id := range []string{context, sourceContext} {
+		if owner := sb.contexts[id].session; id != "" && owner != nil {
+			return owner
+		}
+	}
+	for _, id := range []string{realm, sourceRealm} {
+		if owner := sb.realms[id]; id != "" && owner != nil {
+			return owner
+		}
+	}
+	return nil
+}
+
+// sharedBrowserLost closes the clients of a shared browser whose connection
+// was lost.
+func (r *Router) sharedBrowserLost(sb *sharedBrowser, err error) {
+	sb.mu.Lock()
+	closed := sb.closed
+	sessions := make([]*BrowserSession, 0, len(sb.sessions))
+	for session := range sb.sessions {
+		sessions = append(sessions, session)
+	}
+	sb.mu.Unlock()
+	if closed {
+		return
+	}
+
+	fmt.Printf("[router] Shared browser connection closed: %v\n", err)
+
+	r.sharedMu.Lock()
+	for i, other := range r.shared {
+		if other == sb {
+			r.shared = append(r.shared[:i], r.shared[i+1:]...)
+			break
+		}
+	}
+	r.sharedMu.Unlock()
+
+	sb.close()
+	for _, session := range sessions {
+		r.endSession(session)
+	}
+}
+
+// closeShared closes all shared browsers.
+func (r *Router) closeShared() {
+	r.sharedMu.Lock()
+	shared := r.shared
+	r.shared = nil
+	r.sharedMu.Unlock()
+
+	for _, sb := range shared {
+		sb.close()
+	}
+}
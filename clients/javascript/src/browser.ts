@@ -23,7 +23,7 @@ export const browser = {
     debug('clicker started', { port: process.port });
 
     // Connect to the proxy
-    const client = await BiDiClient.connect(`ws://localhost:${process.port}`);
+    const client = await BiDiClient.connect(`ws://localhost:${process.port}/?token=${process.token}`);
     info('browser launched', { port: process.port });
 
     return new Vibe(client, process);
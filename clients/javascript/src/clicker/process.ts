@@ -1,4 +1,5 @@
 import { spawn, ChildProcess } from 'child_process';
+import { randomBytes } from 'crypto';
 import { getClickerPath } from './binary';
 import { TimeoutError, BrowserCrashedError } from '../utils/errors';
 
@@ -11,17 +12,24 @@ export interface ClickerProcessOptions {
 export class ClickerProcess {
   private process: ChildProcess;
   private _port: number;
+  private _token: string;
   private _stopped: boolean = false;
 
-  private constructor(process: ChildProcess, port: number) {
+  private constructor(process: ChildProcess, port: number, token: string) {
     this.process = process;
     this._port = port;
+    this._token = token;
   }
 
   get port(): number {
     return this._port;
   }
 
+  /** Token the proxy requires from clients. */
+  get token(): string {
+    return this._token;
+  }
+
   static async start(options: ClickerProcessOptions = {}): Promise<ClickerProcess> {
     const binaryPath = options.executablePath || getClickerPath();
     const port = options.port || 0; // 0 means auto-select
@@ -34,8 +42,11 @@ export class ClickerProcess {
       args.push('--headless');
     }
 
+    // Pass the token through the environment, so it doesn't show up in ps
+    const token = randomBytes(24).toString('hex');
     const proc = spawn(binaryPath, args, {
       stdio: ['ignore', 'pipe', 'pipe'],
+      env: { ...process.env, CLICKER_TOKEN: token },
     });
 
     // Wait for the server to start and extract the port
@@ -82,7 +93,7 @@ export class ClickerProcess {
       });
     });
 
-    return new ClickerProcess(proc, actualPort);
+    return new ClickerProcess(proc, actualPort, token);
   }
 
   async stop(): Promise<void> {
@@ -38,6 +38,6 @@ class browser:
             executable_path=executable_path,
         )
 
-        client = await BiDiClient.connect(f"ws://localhost:{process.port}")
+        client = await BiDiClient.connect(f"ws://localhost:{process.port}/?token={process.token}")
 
         return Vibe(client, process)
@@ -4,6 +4,7 @@ import asyncio
 import importlib.util
 import os
 import platform
+import secrets
 import shutil
 import subprocess
 import sys
@@ -146,9 +147,10 @@ def ensure_browser_installed(clicker_path: str) -> None:
 class ClickerProcess:
     """Manages a clicker subprocess."""
 
-    def __init__(self, process: subprocess.Popen, port: int):
+    def __init__(self, process: subprocess.Popen, port: int, token: str):
         self._process = process
         self.port = port
+        self.token = token
 
     @classmethod
     async def start(
@@ -178,12 +180,15 @@ class ClickerProcess:
         if port:
             args.extend(["--port", str(port)])
 
-        # Start the process
+        # Start the process, passing the token the proxy requires from
+        # clients through the environment so it doesn't show up in ps
+        token = secrets.token_hex(24)
         process = subprocess.Popen(
             args,
             stdout=subprocess.PIPE,
             stderr=subprocess.PIPE,
             text=True,
+            env={**os.environ, "CLICKER_TOKEN": token},
         )
 
         # Read the port from stdout
@@ -207,7 +212,7 @@ class ClickerProcess:
             stderr = process.stderr.read() if process.stderr else ""
             raise RuntimeError(f"Clicker failed to start: {stderr}")
 
-        return cls(process, actual_port)
+        return cls(process, actual_port, token)
 
     async def stop(self) -> None:
         """Stop the clicker process."""
@@ -22,6 +22,45 @@ describe('CLI: Elements', () => {
     assert.match(result, /box=/i, 'Should show bounding box');
   });
 
+  test('snapshot command prints accessibility tree with refs', () => {
+    const result = execSync(`${CLICKER} snapshot https://example.com`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+    });
+    assert.match(result, /- heading "Example Domain" \[level=1\] \[ref=e\d+\]/, 'Should show heading');
+    assert.match(result, /- link "(More information|Learn more)[^"]*" \[ref=e\d+\]/i, 'Should show link');
+  });
+
+  test('snapshot --json prints parseable JSON', () => {
+    const result = execSync(`${CLICKER} snapshot https://example.com --json`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+      stdio: ['pipe', 'pipe', 'ignore'],
+    });
+    const snapshot = JSON.parse(result);
+    assert.strictEqual(snapshot.title, 'Example Domain');
+    assert.ok(snapshot.nodes.length > 0, 'Should have nodes');
+  });
+
+  test('content command prints page as markdown', () => {
+    const result = execSync(`${CLICKER} content https://example.com`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+      stdio: ['pipe', 'pipe', 'ignore'],
+    });
+    assert.match(result, /^# Example Domain$/m, 'Should render heading');
+    assert.match(result, /\[(More information|Learn more)[^\]]*\]\(https:\/\/[^)]+\)/i, 'Should render link with URL');
+  });
+
+  test('content command supports text format and max length', () => {
+    const result = execSync(`${CLICKER} content https://example.com --format text --max-length 14`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+      stdio: ['pipe', 'pipe', 'ignore'],
+    });
+    assert.strictEqual(result.trim(), 'Example Domain', 'Should print truncated plain text');
+  });
+
   test('click command navigates via link', () => {
     const result = execSync(`${CLICKER} click https://example.com "a"`, {
       encoding: 'utf-8',
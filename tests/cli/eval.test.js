@@ -0,0 +1,43 @@
+/**
+ * CLI Tests: Evaluate result deserialization
+ * Table-driven tests of how `clicker eval` decodes BiDi RemoteValues
+ */
+
+const { test, describe } = require('node:test');
+const assert = require('node:assert');
+const { execFileSync } = require('node:child_process');
+const path = require('node:path');
+
+const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
+
+// Each case evaluates an expression on example.com and checks the printed result
+const cases = [
+  { name: 'number', expression: '1 + 1', expected: /Result: 2\n/ },
+  { name: 'string', expression: 'document.title', expected: /Result: Example Domain\n/ },
+  { name: 'boolean', expression: '1 < 2', expected: /Result: true\n/ },
+  { name: 'null', expression: 'null', expected: /Result: <nil>\n/ },
+  { name: 'NaN', expression: 'NaN', expected: /Result: NaN\n/ },
+  { name: '-Infinity', expression: '-Infinity', expected: /Result: -Inf\n/ },
+  { name: 'bigint', expression: '10n ** 20n', expected: /Result: 100000000000000000000\n/ },
+  { name: 'array', expression: "[1, 'a', true]", expected: /Result: \[1 a true\]\n/ },
+  { name: 'object', expression: "({ a: 1, b: 'x' })", expected: /Result: map\[a:1 b:x\]\n/ },
+  { name: 'nested object', expression: '({ list: [1, { deep: 2 }] })', expected: /Result: map\[list:\[1 map\[deep:2\]\]\]\n/ },
+  { name: 'set', expression: 'new Set([1, 2])', expected: /Result: \[1 2\]\n/ },
+  { name: 'map', expression: "new Map([['k', 1], [2, 'v']])", expected: /Result: \[\{k 1\} \{2 v\}\]\n/ },
+  { name: 'date', expression: 'new Date(0)', expected: /Result: 1970-01-01 00:00:00 \+0000 UTC\n/ },
+  { name: 'regexp', expression: '/ab+c/gi', expected: /Result: \/ab\+c\/gi\n/ },
+  { name: 'node', expression: "document.querySelector('h1')", expected: /Result: &\{.* 1 h1 .*\}\n/ },
+  { name: 'function', expression: '() => 1', expected: /Result: &\{function / },
+];
+
+describe('CLI: Evaluate RemoteValue deserialization', () => {
+  for (const { name, expression, expected } of cases) {
+    test(`eval decodes ${name}`, () => {
+      const result = execFileSync(CLICKER, ['eval', 'https://example.com', expression], {
+        encoding: 'utf-8',
+        timeout: 30000,
+      });
+      assert.match(result, expected);
+    });
+  }
+});
@@ -53,4 +53,47 @@ describe('CLI: Navigation', () => {
     });
     assert.match(result, /Example Domain/i, 'Should return page title');
   });
+
+  test('--init-script runs before page scripts', () => {
+    const scriptFile = `/tmp/vibium-init-${Date.now()}.js`;
+    try {
+      fs.writeFileSync(scriptFile, 'window.__vibiumFlag = "from-init-script";');
+      const result = execSync(`${CLICKER} eval --init-script ${scriptFile} https://example.com "window.__vibiumFlag"`, {
+        encoding: 'utf-8',
+        timeout: 30000,
+      });
+      assert.match(result, /from-init-script/, 'Should see value set by init script');
+    } finally {
+      if (fs.existsSync(scriptFile)) {
+        fs.unlinkSync(scriptFile);
+      }
+    }
+  });
+});
+
+describe('CLI: Storage', () => {
+  test('cookies set stores a cookie for the page', () => {
+    const result = execSync(`${CLICKER} cookies set https://example.com vibium_test abc123`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+    });
+    assert.match(result, /vibium_test=abc123/, 'Should print the new cookie');
+  });
+
+  test('storage set stores a localStorage item', () => {
+    const result = execSync(`${CLICKER} storage set https://example.com theme dark`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+    });
+    assert.match(result, /localStorage \(1\):\s+theme=dark/, 'Should print the new item');
+    assert.match(result, /sessionStorage: No items/, 'Should leave sessionStorage empty');
+  });
+
+  test('storage set --session stores a sessionStorage item', () => {
+    const result = execSync(`${CLICKER} storage set https://example.com step 2 --session`, {
+      encoding: 'utf-8',
+      timeout: 30000,
+    });
+    assert.match(result, /sessionStorage \(1\):\s+step=2/, 'Should print the new item');
+  });
 });
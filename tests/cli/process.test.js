@@ -106,4 +106,44 @@ describe('CLI: Process Cleanup', () => {
       `Chrome processes should be cleaned up after SIGTERM. New PIDs remaining: ${newPids.join(', ')}`
     );
   });
+  test('serve command cleans up pooled browsers on SIGTERM', async () => {
+    const pidsBefore = getClickerChromePids();
+
+    const server = spawn(CLICKER, ['serve', '--headless', '--port', '9563', '--pool-size', '2'], {
+      stdio: ['pipe', 'pipe', 'pipe'],
+    });
+
+    // Wait for both pooled browsers to be ready
+    await new Promise((resolve, reject) => {
+      let output = '';
+      const timeout = setTimeout(() => reject(new Error(`Pool not ready:\n${output}`)), 30000);
+      server.stdout.on('data', (data) => {
+        output += data.toString();
+        if ((output.match(/\[pool\] Browser ready/g) || []).length >= 2) {
+          clearTimeout(timeout);
+          resolve();
+        }
+      });
+    });
+
+    const pidsWarm = getNewPids(pidsBefore, getClickerChromePids());
+    assert.ok(pidsWarm.length > 0, 'Pooled browsers should be running before any client connects');
+
+    server.kill('SIGTERM');
+    await new Promise((resolve) => {
+      const timeout = setTimeout(resolve, 5000);
+      server.on('exit', () => {
+        clearTimeout(timeout);
+        resolve();
+      });
+    });
+    await sleep(2000);
+
+    const newPids = getNewPids(pidsBefore, getClickerChromePids());
+    assert.strictEqual(
+      newPids.length,
+      0,
+      `Pooled Chrome processes should be cleaned up after SIGTERM. New PIDs remaining: ${newPids.join(', ')}`
+    );
+  });
 });
@@ -0,0 +1,552 @@
+/**
+ * CLI Tests: serve
+ * Tests the WebSocket proxy's authentication, control plane, session limits,
+ * shared browsers and session resuming
+ */
+
+const { test, describe, before, after } = require('node:test');
+const assert = require('node:assert');
+const { spawn, execSync } = require('node:child_process');
+const fs = require('node:fs');
+const os = require('node:os');
+const path = require('node:path');
+const WebSocket = require('ws');
+
+const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
+const TOKEN = 'test-token';
+
+/**
+ * Start clicker serve with the given args and wait until it listens.
+ * Resolves with the process and its output so far.
+ */
+async function startServer(args, env = process.env) {
+  const server = spawn(CLICKER, ['serve', '--headless', ...args], {
+    stdio: ['pipe', 'pipe', 'pipe'],
+    env,
+  });
+  server.output = '';
+  await new Promise((resolve, reject) => {
+    const timeout = setTimeout(() => reject(new Error('serve did not start')), 10000);
+    server.stdout.on('data', (data) => {
+      server.output += data.toString();
+      if (server.output.includes('Press Ctrl+C')) {
+        clearTimeout(timeout);
+        resolve();
+      }
+    });
+    server.on('exit', () => reject(new Error(`serve exited: ${server.output}`)));
+  });
+  return server;
+}
+
+/**
+ * Open a WebSocket and resolve with the handshake status: 101 if the
+ * connection was accepted, otherwise the HTTP status of the rejection
+ */
+function handshake(url, options = {}) {
+  return new Promise((resolve, reject) => {
+    const ws = new WebSocket(url, options);
+    ws.on('open', () => {
+      ws.close();
+      resolve(101);
+    });
+    ws.on('unexpected-response', (req, res) => {
+      req.destroy();
+      resolve(res.statusCode);
+    });
+    ws.on('error', reject);
+  });
+}
+
+/**
+ * Connect to the proxy and collect messages
+ */
+function connect(port, query = '') {
+  return new Promise((resolve, reject) => {
+    const ws = new WebSocket(`ws://localhost:${port}/?token=${TOKEN}${query}`);
+    const messages = [];
+    const waiters = [];
+    ws.on('message', (data) => {
+      const msg = JSON.parse(data.toString());
+      const waiter = waiters.shift();
+      if (waiter) {
+        waiter(msg);
+      } else {
+        messages.push(msg);
+      }
+    });
+    ws.nextMessage = () =>
+      messages.length > 0 ? Promise.resolve(messages.shift()) : new Promise((r) => waiters.push(r));
+    ws.on('open', () => resolve(ws));
+    ws.on('error', reject);
+  });
+}
+
+/**
+ * Send a command and wait for the next message, its response
+ */
+async function send(ws, id, method, params = {}) {
+  ws.send(JSON.stringify({ id, method, params }));
+  return ws.nextMessage();
+}
+
+/**
+ * Send session.status and wait for the response, proving the session has a browser
+ */
+async function sessionStatus(ws) {
+  return send(ws, 1, 'session.status');
+}
+
+describe('CLI: serve authentication', () => {
+  const port = 9566;
+  const url = `ws://localhost:${port}`;
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN, '--client-origin', 'http://localhost:3000']);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  test('listens on loopback by default', () => {
+    assert.ok(server.output.includes(`Server listening on ws://localhost:${port}`), server.output);
+    assert.ok(!server.output.includes(TOKEN), 'Should not print a token it was given');
+  });
+
+  test('rejects clients without the token', async () => {
+    assert.strictEqual(await handshake(url), 401);
+    assert.strictEqual(await handshake(`${url}/?token=wrong`), 401);
+    assert.strictEqual(await handshake(url, { headers: { Authorization: 'Bearer wrong' } }), 401);
+  });
+
+  test('accepts the token as a query parameter or bearer token', async () => {
+    assert.strictEqual(await handshake(`${url}/?token=${TOKEN}`), 101);
+    assert.strictEqual(await handshake(url, { headers: { Authorization: `Bearer ${TOKEN}` } }), 101);
+  });
+
+  test('rejects web pages from other origins', async () => {
+    const status = await handshake(`${url}/?token=${TOKEN}`, { origin: 'https://evil.example.com' });
+    assert.strictEqual(status, 403);
+  });
+
+  test('accepts web pages from allowed origins', async () => {
+    const status = await handshake(`${url}/?token=${TOKEN}`, { origin: 'http://localhost:3000' });
+    assert.strictEqual(status, 101);
+  });
+});
+
+describe('CLI: serve token generation', () => {
+  test('generates and prints a token if none is given', async () => {
+    const port = 9567;
+    const env = { ...process.env };
+    delete env.CLICKER_TOKEN;
+    const server = await startServer(['--port', String(port)], env);
+    try {
+      const match = server.output.match(/Token: (\w+)/);
+      assert.ok(match, `Should print the token: ${server.output}`);
+      assert.ok(server.output.includes(`ws://localhost:${port}/?token=${match[1]}`), 'Should print a URL with the token');
+      assert.strictEqual(await handshake(`ws://localhost:${port}`), 401);
+      assert.strictEqual(await handshake(`ws://localhost:${port}/?token=${match[1]}`), 101);
+    } finally {
+      server.kill('SIGTERM');
+    }
+  });
+
+  test('reads the token from CLICKER_TOKEN', async () => {
+    const port = 9568;
+    const server = await startServer(['--port', String(port)], { ...process.env, CLICKER_TOKEN: TOKEN });
+    try {
+      assert.ok(!server.output.includes('Token:'), 'Should not print a token it was given');
+      assert.strictEqual(await handshake(`ws://localhost:${port}/?token=${TOKEN}`), 101);
+    } finally {
+      server.kill('SIGTERM');
+    }
+  });
+});
+
+describe('CLI: serve --tls-cert', () => {
+  const port = 9569;
+  let dir;
+  let server;
+
+  before(async () => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'clicker-tls-'));
+    execSync(
+      `openssl req -x509 -newkey rsa:2048 -nodes -days 1 -subj /CN=localhost ` +
+        `-keyout ${path.join(dir, 'key.pem')} -out ${path.join(dir, 'cert.pem')}`,
+      { stdio: 'ignore' }
+    );
+    server = await startServer([
+      '--port', String(port), '--token', TOKEN,
+      '--tls-cert', path.join(dir, 'cert.pem'), '--tls-key', path.join(dir, 'key.pem'),
+    ]);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  test('serves wss://', async () => {
+    assert.ok(server.output.includes(`Server listening on wss://localhost:${port}`), server.output);
+    const status = await handshake(`wss://localhost:${port}/?token=${TOKEN}`, { rejectUnauthorized: false });
+    assert.strictEqual(status, 101);
+  });
+
+  test('fails to start with only a certificate', () => {
+    assert.throws(
+      () => execSync(`${CLICKER} serve --tls-cert ${path.join(dir, 'cert.pem')}`, { stdio: 'pipe' }),
+      /--tls-cert and --tls-key/
+    );
+  });
+});
+
+describe('CLI: serve control plane', () => {
+  const port = 9570;
+  const base = `http://localhost:${port}`;
+  const auth = { headers: { Authorization: `Bearer ${TOKEN}` } };
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN]);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  test('health and readiness need no token', async () => {
+    assert.strictEqual((await fetch(`${base}/healthz`)).status, 200);
+    assert.strictEqual((await fetch(`${base}/readyz`)).status, 200);
+  });
+
+  test('sessions and metrics need the token', async () => {
+    assert.strictEqual((await fetch(`${base}/sessions`)).status, 401);
+    assert.strictEqual((await fetch(`${base}/metrics`)).status, 401);
+    assert.strictEqual((await fetch(`${base}/sessions/1`, { method: 'DELETE' })).status, 401);
+  });
+
+  test('metrics are in the Prometheus format', async () => {
+    const res = await fetch(`${base}/metrics`, auth);
+    assert.strictEqual(res.status, 200);
+    const text = await res.text();
+    assert.match(text, /# TYPE clicker_sessions_active gauge/);
+    assert.match(text, /clicker_browser_launch_seconds_bucket\{le="\+Inf"\} \d+/);
+    assert.match(text, /# TYPE clicker_commands_total counter/);
+    assert.match(text, /# TYPE clicker_vibium_errors_total counter/);
+  });
+
+  test('deleting an unknown session is a 404', async () => {
+    assert.strictEqual((await fetch(`${base}/sessions/999`, { ...auth, method: 'DELETE' })).status, 404);
+    assert.strictEqual((await fetch(`${base}/sessions/abc`, { ...auth, method: 'DELETE' })).status, 400);
+  });
+
+  test('lists sessions and ends them on DELETE', async () => {
+    const ws = await connect(port);
+    const status = await sessionStatus(ws);
+    assert.strictEqual(status.type, 'success');
+
+    const sessions = await (await fetch(`${base}/sessions`, auth)).json();
+    assert.strictEqual(sessions.length, 1);
+    assert.ok(sessions[0].clientId > 0, 'Should have a client ID');
+    assert.ok(sessions[0].browserPid > 0, 'Should have a browser PID');
+    assert.ok(sessions[0].uptimeSeconds >= 0, 'Should have an uptime');
+    assert.strictEqual(sessions[0].url, 'about:blank');
+
+    const metrics = await (await fetch(`${base}/metrics`, auth)).text();
+    assert.match(metrics, /clicker_sessions_active 1/);
+    assert.match(metrics, /clicker_commands_total\{method="session.status"\} 1/);
+
+    const closed = new Promise((resolve) => ws.on('close', resolve));
+    const res = await fetch(`${base}/sessions/${sessions[0].clientId}`, { ...auth, method: 'DELETE' });
+    assert.strictEqual(res.status, 204);
+    await closed;
+  });
+});
+
+describe('CLI: serve --max-sessions', () => {
+  const port = 9564;
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN, '--max-sessions', '1', '--queue-timeout', '3s']);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  test('clients wait for a free session and time out at capacity', async () => {
+    const first = await connect(port);
+    const status = await sessionStatus(first);
+    assert.strictEqual(status.type, 'success', 'First client should get a browser');
+
+    // At capacity: the second client gives up after the queue timeout
+    const start = Date.now();
+    const second = await connect(port);
+    const error = await second.nextMessage();
+    assert.ok(error.error.message.includes('in use'), 'Should say all sessions are in use');
+    assert.ok(Date.now() - start >= 2500, 'Should wait for the queue timeout');
+
+    // A queued client gets a browser once the first client leaves
+    const third = await connect(port);
+    setTimeout(() => first.close(), 500);
+    const thirdStatus = await sessionStatus(third);
+    assert.strictEqual(thirdStatus.type, 'success', 'Queued client should get a browser');
+    third.close();
+  });
+});
+
+describe('CLI: serve --shared-browsers', () => {
+  const port = 9565;
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN, '--shared-browsers', '1']);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  test('clients only see their own browsing contexts', async () => {
+    const alice = await connect(port);
+    const bob = await connect(port);
+
+    const aliceTree = await send(alice, 1, 'browsingContext.getTree');
+    const bobTree = await send(bob, 1, 'browsingContext.getTree');
+    assert.strictEqual(aliceTree.id, 1, 'Response should keep the client command ID');
+    assert.strictEqual(aliceTree.result.contexts.length, 1, 'Alice should see only her tab');
+    assert.strictEqual(bobTree.result.contexts.length, 1, 'Bob should see only his tab');
+
+    const aliceContext = aliceTree.result.contexts[0].context;
+    assert.notStrictEqual(aliceContext, bobTree.result.contexts[0].context);
+
+    const navigate = await send(bob, 2, 'browsingContext.navigate', { context: aliceContext, url: 'about:blank' });
+    assert.strictEqual(navigate.type, 'error');
+    assert.strictEqual(navigate.error, 'no such frame', "Bob can't use Alice's tab");
+
+    alice.close();
+    bob.close();
+  });
+
+  test('clients have separate cookies', async () => {
+    const alice = await connect(port);
+    const bob = await connect(port);
+
+    const set = await send(alice, 1, 'storage.setCookie', {
+      cookie: { name: 'who', value: { type: 'string', value: 'alice' }, domain: 'example.com' },
+    });
+    assert.strictEqual(set.type, 'success');
+
+    const aliceCookies = await send(alice, 2, 'storage.getCookies');
+    const bobCookies = await send(bob, 2, 'storage.getCookies');
+    assert.ok(aliceCookies.result.cookies.some((c) => c.name === 'who'), 'Alice should see her cookie');
+    assert.ok(!bobCookies.result.cookies.some((c) => c.name === 'who'), "Bob shouldn't see Alice's cookie");
+
+    alice.close();
+    bob.close();
+  });
+
+  test("clients can't remove each other's intercepts and preload scripts", async () => {
+    const alice = await connect(port);
+    const bob = await connect(port);
+
+    const intercept = await send(alice, 1, 'network.addIntercept', { phases: ['beforeRequestSent'] });
+    assert.strictEqual(intercept.type, 'success', JSON.stringify(intercept));
+    const script = await send(alice, 2, 'script.addPreloadScript', { functionDeclaration: '() => {}' });
+    assert.strictEqual(script.type, 'success', JSON.stringify(script));
+
+    const removeIntercept = await send(bob, 1, 'network.removeIntercept', { intercept: intercept.result.intercept });
+    assert.strictEqual(removeIntercept.error, 'no such intercept');
+    const removeScript = await send(bob, 2, 'script.removePreloadScript', { script: script.result.script });
+    assert.strictEqual(removeScript.error, 'no such script');
+    const continueRequest = await send(bob, 3, 'network.continueRequest', { request: 'unknown' });
+    assert.strictEqual(continueRequest.error, 'no such request');
+    const windowState = await send(bob, 4, 'browser.setClientWindowState', { clientWindow: 'any', state: 'minimized' });
+    assert.strictEqual(windowState.error, 'unsupported operation');
+
+    const removed = await send(alice, 3, 'network.removeIntercept', { intercept: intercept.result.intercept });
+    assert.strictEqual(removed.type, 'success', 'Alice can remove her own intercept');
+
+    alice.close();
+    bob.close();
+  });
+});
+
+describe('CLI: serve observers', () => {
+  const port = 9572;
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN]);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  /**
+   * Wait for the next message matching the predicate, skipping others
+   */
+  async function nextMatching(ws, predicate) {
+    for (;;) {
+      const msg = await ws.nextMessage();
+      if (predicate(msg)) {
+        return msg;
+      }
+    }
+  }
+
+  /**
+   * Connect a controlling client and an observer of its session
+   */
+  async function observe() {
+    const controller = await connect(port);
+    await sessionStatus(controller);
+    const sessions = await (await fetch(`http://localhost:${port}/sessions`, {
+      headers: { Authorization: `Bearer ${TOKEN}` },
+    })).json();
+    const { clientId } = sessions[sessions.length - 1];
+
+    const observer = await connect(port, `&observe=${clientId}`);
+    const observing = await observer.nextMessage();
+    assert.strictEqual(observing.method, 'vibium:observing');
+    assert.strictEqual(observing.params.clientId, clientId);
+    return { controller, observer, clientId };
+  }
+
+  test('observers can only send read-only commands', async () => {
+    const { controller, observer } = await observe();
+
+    const tree = await send(observer, 1, 'browsingContext.getTree');
+    assert.strictEqual(tree.type, 'success');
+    const context = tree.result.contexts[0].context;
+
+    const navigate = await send(observer, 2, 'browsingContext.navigate', { context, url: 'about:blank' });
+    assert.strictEqual(navigate.type, 'error');
+    assert.strictEqual(navigate.error, 'unsupported operation');
+    const click = await send(observer, 3, 'vibium:click', { selector: 'body' });
+    assert.strictEqual(click.error, 'unsupported operation');
+
+    observer.close();
+    controller.close();
+  });
+
+  test("observers' command IDs don't collide with the controlling client's", async () => {
+    const { controller, observer } = await observe();
+
+    // Both use ID 7; each gets its own response
+    const observed = await send(observer, 7, 'browsingContext.getTree');
+    assert.strictEqual(observed.id, 7);
+    assert.strictEqual(observed.type, 'success');
+
+    const status = await send(controller, 7, 'session.status');
+    assert.strictEqual(status.id, 7);
+    assert.ok('ready' in status.result, 'Controller should get its own response');
+
+    // The observer sees the controller's response too
+    const mirrored = await nextMatching(observer, (msg) => msg.id === 7);
+    assert.ok('ready' in mirrored.result, "Observer should see the controller's response");
+
+    observer.close();
+    controller.close();
+  });
+
+  test('events are only sent to the observers that subscribed to them', async () => {
+    const { controller, observer, clientId } = await observe();
+    const other = await connect(port, `&observe=${clientId}`);
+    await other.nextMessage(); // vibium:observing
+
+    const subscribed = await send(observer, 1, 'session.subscribe', { events: ['log.entryAdded'] });
+    assert.strictEqual(subscribed.type, 'success');
+
+    const tree = await send(controller, 1, 'browsingContext.getTree');
+    const context = tree.result.contexts[0].context;
+    await send(controller, 2, 'script.evaluate', {
+      expression: 'console.log("observed")',
+      target: { context },
+      awaitPromise: false,
+    });
+
+    const entry = await nextMatching(observer, (msg) => msg.method === 'log.entryAdded');
+    assert.strictEqual(entry.params.text, 'observed');
+
+    // The controller and the other observer get the next response, no event
+    const status = await send(controller, 3, 'session.status');
+    assert.strictEqual(status.id, 3, 'Controller should not get the log event');
+    const seen = [];
+    await nextMatching(other, (msg) => {
+      seen.push(msg);
+      return msg.id === 3;
+    });
+    assert.ok(!seen.some((msg) => msg.method === 'log.entryAdded'), "Other observer shouldn't get the log event");
+
+    other.close();
+    observer.close();
+    controller.close();
+  });
+});
+
+describe('CLI: serve --keep-alive', () => {
+  const port = 9571;
+  let server;
+
+  before(async () => {
+    server = await startServer(['--port', String(port), '--token', TOKEN, '--keep-alive', '5s']);
+  });
+
+  after(() => {
+    server.kill('SIGTERM');
+  });
+
+  test('rejects unknown resume tokens', async () => {
+    const ws = await connect(port, '&resume=unknown');
+    const error = await ws.nextMessage();
+    assert.ok(error.error.message.includes('unknown or expired resume token'), JSON.stringify(error));
+  });
+
+  test('a client resumes its session after reconnecting', async () => {
+    const first = await connect(port);
+    const ready = await first.nextMessage();
+    assert.strictEqual(ready.method, 'vibium:sessionReady');
+    assert.strictEqual(ready.params.resumed, false);
+    const { resumeToken } = ready.params;
+    assert.ok(resumeToken, 'Should announce a resume token');
+
+    // The control plane says the session can be resumed, without the token
+    const sessions = await (await fetch(`http://localhost:${port}/sessions`, {
+      headers: { Authorization: `Bearer ${TOKEN}` },
+    })).json();
+    assert.strictEqual(sessions[0].resumable, true);
+    assert.ok(!JSON.stringify(sessions).includes(resumeToken), "Shouldn't expose the resume token");
+
+    const tree = await send(first, 1, 'browsingContext.getTree');
+    const context = tree.result.contexts[0].context;
+    await send(first, 2, 'browsingContext.navigate', { context, url: 'data:text/html,<title>kept</title>', wait: 'complete' });
+
+    // Drop the connection with a slow command in flight; its response is replayed
+    first.send(JSON.stringify({
+      id: 3,
+      method: 'script.evaluate',
+      params: {
+        expression: 'new Promise((r) => setTimeout(() => r(document.title), 1000))',
+        target: { context },
+        awaitPromise: true,
+      },
+    }));
+    first.terminate();
+
+    const second = await connect(port, `&resume=${resumeToken}`);
+    const resumed = await second.nextMessage();
+    assert.strictEqual(resumed.method, 'vibium:sessionReady');
+    assert.strictEqual(resumed.params.resumed, true);
+
+    const missed = await second.nextMessage();
+    assert.strictEqual(missed.id, 3, 'Should replay the missed response');
+    assert.strictEqual(missed.result.result.value, 'kept', 'Should keep the page');
+    second.close();
+  });
+});
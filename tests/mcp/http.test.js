@@ -0,0 +1,321 @@
+/**
+ * MCP Streamable HTTP Transport Tests
+ * Tests clicker mcp --http with a local HTTP client
+ */
+
+const { test, describe, before, after } = require('node:test');
+const assert = require('node:assert');
+const { spawn } = require('node:child_process');
+const net = require('node:net');
+const path = require('node:path');
+
+const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
+const TOKEN = 'test-token';
+
+/**
+ * Headers authenticating with the test token
+ */
+function auth(headers = {}) {
+  return { Authorization: `Bearer ${TOKEN}`, ...headers };
+}
+
+/**
+ * Find a free TCP port on localhost
+ */
+function freePort() {
+  return new Promise((resolve, reject) => {
+    const server = net.createServer();
+    server.listen(0, '127.0.0.1', () => {
+      const { port } = server.address();
+      server.close(() => resolve(port));
+    });
+    server.on('error', reject);
+  });
+}
+
+/**
+ * Start clicker mcp --http and wait until it is listening
+ */
+async function startServer(extraArgs = [], { host = '127.0.0.1' } = {}) {
+  const port = await freePort();
+  const proc = spawn(CLICKER, ['mcp', '--http', `${host}:${port}`, '--token', TOKEN, ...extraArgs], {
+    stdio: ['ignore', 'ignore', 'pipe'],
+  });
+
+  let output = '';
+  await new Promise((resolve, reject) => {
+    const timer = setTimeout(() => reject(new Error('Server did not start')), 10000);
+    proc.stderr.on('data', (data) => {
+      output += data.toString();
+      if (output.includes('listening on')) {
+        clearTimeout(timer);
+        resolve();
+      }
+    });
+    proc.on('error', reject);
+  });
+
+  return { proc, output, url: `http://127.0.0.1:${port}/mcp` };
+}
+
+/**
+ * POST a JSON-RPC message, parsing JSON or SSE responses
+ */
+async function post(url, message, { sessionId, accept = 'application/json, text/event-stream', headers: extra = {} } = {}) {
+  const headers = auth({ 'Content-Type': 'application/json', Accept: accept, ...extra });
+  if (sessionId) headers['Mcp-Session-Id'] = sessionId;
+
+  const res = await fetch(url, { method: 'POST', headers, body: JSON.stringify(message) });
+  const text = await res.text();
+
+  // For SSE, body is the last message; events has every message in order
+  let body = null;
+  let events = [];
+  if (res.headers.get('content-type')?.startsWith('text/event-stream')) {
+    events = text.split('\n')
+      .filter((line) => line.startsWith('data: '))
+      .map((line) => JSON.parse(line.slice('data: '.length)));
+    body = events[events.length - 1];
+  } else if (text) {
+    body = JSON.parse(text);
+  }
+
+  return { status: res.status, headers: res.headers, body, events };
+}
+
+/**
+ * Create an initialized MCP session and return its ID
+ */
+async function initialize(url) {
+  const res = await post(url, {
+    jsonrpc: '2.0',
+    id: 1,
+    method: 'initialize',
+    params: { protocolVersion: '2024-11-05', capabilities: {}, clientInfo: { name: 'test', version: '1.0' } },
+  });
+  const sessionId = res.headers.get('mcp-session-id');
+  await post(url, { jsonrpc: '2.0', method: 'notifications/initialized' }, { sessionId });
+  return { res, sessionId };
+}
+
+describe('MCP HTTP: Sessions', () => {
+  let server;
+
+  before(async () => {
+    server = await startServer();
+  });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('initialize returns a session ID', async () => {
+    const { res, sessionId } = await initialize(server.url);
+
+    assert.strictEqual(res.status, 200);
+    assert.ok(sessionId, 'Should have Mcp-Session-Id header');
+    assert.strictEqual(res.body.result.serverInfo.name, 'vibium');
+  });
+
+  test('requests with a session ID are answered as JSON or SSE', async () => {
+    const { sessionId } = await initialize(server.url);
+
+    const sse = await post(server.url, { jsonrpc: '2.0', id: 2, method: 'tools/list' }, { sessionId });
+    assert.strictEqual(sse.status, 200);
+    assert.match(sse.headers.get('content-type'), /text\/event-stream/);
+    assert.ok(sse.body.result.tools.length > 0, 'Should list tools');
+
+    const json = await post(server.url, { jsonrpc: '2.0', id: 3, method: 'tools/list' }, {
+      sessionId,
+      accept: 'application/json',
+    });
+    assert.strictEqual(json.status, 200);
+    assert.match(json.headers.get('content-type'), /application\/json/);
+    assert.strictEqual(json.body.id, 3);
+  });
+
+  test('notifications are accepted without a body', async () => {
+    const { sessionId } = await initialize(server.url);
+    const res = await post(server.url, { jsonrpc: '2.0', method: 'notifications/initialized' }, { sessionId });
+    assert.strictEqual(res.status, 202);
+  });
+
+  test('batches return one response per request', async () => {
+    const { sessionId } = await initialize(server.url);
+    const res = await post(server.url, [
+      { jsonrpc: '2.0', id: 10, method: 'tools/list' },
+      { jsonrpc: '2.0', method: 'notifications/initialized' },
+      { jsonrpc: '2.0', id: 11, method: 'unknown/method' },
+    ], { sessionId, accept: 'application/json' });
+
+    assert.strictEqual(res.body.length, 2);
+    assert.strictEqual(res.body[0].id, 10);
+    assert.strictEqual(res.body[1].error.code, -32601);
+  });
+
+  test('ping is answered', async () => {
+    const { sessionId } = await initialize(server.url);
+    const res = await post(server.url, { jsonrpc: '2.0', id: 5, method: 'ping' }, { sessionId });
+    assert.deepStrictEqual(res.body.result, {});
+  });
+
+  test('missing session ID returns 400', async () => {
+    const res = await post(server.url, { jsonrpc: '2.0', id: 2, method: 'tools/list' });
+    assert.strictEqual(res.status, 400);
+  });
+
+  test('unknown session ID returns 404', async () => {
+    const res = await post(server.url, { jsonrpc: '2.0', id: 2, method: 'tools/list' }, { sessionId: 'nope' });
+    assert.strictEqual(res.status, 404);
+  });
+
+  test('DELETE terminates the session', async () => {
+    const { sessionId } = await initialize(server.url);
+
+    const del = await fetch(server.url, { method: 'DELETE', headers: auth({ 'Mcp-Session-Id': sessionId }) });
+    assert.strictEqual(del.status, 200);
+
+    const res = await post(server.url, { jsonrpc: '2.0', id: 2, method: 'tools/list' }, { sessionId });
+    assert.strictEqual(res.status, 404);
+  });
+
+  test('GET opens a notification stream that ends with the session', async () => {
+    const { sessionId } = await initialize(server.url);
+
+    const notAcceptable = await fetch(server.url, {
+      headers: auth({ 'Mcp-Session-Id': sessionId, Accept: 'application/json' }),
+    });
+    assert.strictEqual(notAcceptable.status, 406);
+
+    const stream = await fetch(server.url, {
+      headers: auth({ 'Mcp-Session-Id': sessionId, Accept: 'text/event-stream' }),
+    });
+    assert.strictEqual(stream.status, 200);
+    assert.match(stream.headers.get('content-type'), /text\/event-stream/);
+
+    await fetch(server.url, { method: 'DELETE', headers: auth({ 'Mcp-Session-Id': sessionId }) });
+    assert.strictEqual(await stream.text(), '', 'Stream should end when the session is deleted');
+  });
+
+  test('each session has its own browser', async () => {
+    const a = await initialize(server.url);
+    const b = await initialize(server.url);
+
+    const launch = await post(server.url, {
+      jsonrpc: '2.0',
+      id: 2,
+      method: 'tools/call',
+      params: { name: 'browser_launch', arguments: { headless: true } },
+    }, { sessionId: a.sessionId });
+    assert.ok(!launch.body.result.isError, 'Session A should launch a browser');
+
+    const navigate = await post(server.url, {
+      jsonrpc: '2.0',
+      id: 3,
+      method: 'tools/call',
+      params: { name: 'browser_navigate', arguments: { url: 'https://example.com' } },
+    }, { sessionId: b.sessionId });
+    assert.ok(navigate.body.result.isError, 'Session B should have no browser');
+    assert.match(navigate.body.result.content[0].text, /no browser session/);
+
+    // Progress is streamed on the SSE response, and a cancelled call ends
+    // its stream without a response
+    const click = post(server.url, {
+      jsonrpc: '2.0',
+      id: 4,
+      method: 'tools/call',
+      params: {
+        name: 'browser_click',
+        arguments: { selector: '#never-appears' },
+        _meta: { progressToken: 7 },
+      },
+    }, { sessionId: a.sessionId });
+    await new Promise((resolve) => setTimeout(resolve, 1000));
+    await post(server.url, {
+      jsonrpc: '2.0',
+      method: 'notifications/cancelled',
+      params: { requestId: 4 },
+    }, { sessionId: a.sessionId });
+
+    const clicked = await click;
+    assert.ok(clicked.events.some((e) => e.method === 'notifications/progress' && e.params.progressToken === 7),
+      'Should stream progress');
+    assert.ok(!clicked.events.some((e) => e.id === 4), 'Should not respond to a cancelled call');
+
+    await fetch(server.url, { method: 'DELETE', headers: auth({ 'Mcp-Session-Id': a.sessionId }) });
+  });
+});
+
+describe('MCP HTTP: Idle sessions', () => {
+  let server;
+
+  before(async () => {
+    server = await startServer(['--idle-timeout', '1s']);
+  });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('idle sessions are closed', async () => {
+    const { sessionId } = await initialize(server.url);
+
+    await new Promise((resolve) => setTimeout(resolve, 2500));
+
+    const res = await post(server.url, { jsonrpc: '2.0', id: 2, method: 'tools/list' }, { sessionId });
+    assert.strictEqual(res.status, 404);
+  });
+});
+
+describe('MCP HTTP: Security', () => {
+  let server;
+
+  const initializeMessage = {
+    jsonrpc: '2.0',
+    id: 1,
+    method: 'initialize',
+    params: { protocolVersion: '2024-11-05', capabilities: {}, clientInfo: { name: 'test', version: '1.0' } },
+  };
+
+  before(async () => {
+    server = await startServer(['--client-origin', 'http://localhost:3000'], { host: '' });
+  });
+
+  after(() => {
+    server.proc.kill();
+  });
+
+  test('listens on 127.0.0.1 without a host', () => {
+    assert.match(server.output, /listening on http:\/\/127\.0\.0\.1:/);
+  });
+
+  test('requests without the token are rejected with 401', async () => {
+    const res = await fetch(server.url, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', Accept: 'application/json' },
+      body: JSON.stringify(initializeMessage),
+    });
+    assert.strictEqual(res.status, 401);
+    assert.strictEqual(res.headers.get('www-authenticate'), 'Bearer');
+
+    const wrong = await post(server.url, initializeMessage, { headers: { Authorization: 'Bearer wrong' } });
+    assert.strictEqual(wrong.status, 401);
+  });
+
+  test('requests from other origins are rejected with 403', async () => {
+    const res = await post(server.url, initializeMessage, { headers: { Origin: 'http://evil.example' } });
+    assert.strictEqual(res.status, 403);
+
+    const allowed = await post(server.url, initializeMessage, { headers: { Origin: 'http://localhost:3000' } });
+    assert.strictEqual(allowed.status, 200);
+  });
+
+  test('POST requires a JSON content type', async () => {
+    const res = await fetch(server.url, {
+      method: 'POST',
+      headers: auth({ 'Content-Type': 'text/plain', Accept: 'application/json' }),
+      body: JSON.stringify(initializeMessage),
+    });
+    assert.strictEqual(res.status, 415);
+  });
+});
@@ -5,25 +5,31 @@
 
 const { test, describe, before, after } = require('node:test');
 const assert = require('node:assert');
-const { spawn } = require('node:child_process');
+const { spawn, spawnSync } = require('node:child_process');
+const fs = require('node:fs');
 const path = require('node:path');
 
+const os = require('node:os');
+
 const CLICKER = path.join(__dirname, '../../clicker/bin/clicker');
 
 /**
  * Helper to run MCP server and send/receive JSON-RPC messages
  */
 class MCPClient {
-  constructor() {
+  constructor(args = []) {
+    this.args = args;
     this.proc = null;
     this.buffer = '';
     this.responses = [];
     this.resolvers = [];
+    this.notifications = [];
+    this.notificationWaiters = [];
   }
 
   start() {
     return new Promise((resolve, reject) => {
-      this.proc = spawn(CLICKER, ['mcp'], {
+      this.proc = spawn(CLICKER, ['mcp', ...this.args], {
         stdio: ['pipe', 'pipe', 'pipe'],
       });
 
@@ -36,6 +42,10 @@ class MCPClient {
           if (line.trim()) {
             try {
               const response = JSON.parse(line);
+              if (response.method) {
+                this.handleNotification(response);
+                continue;
+              }
               if (this.resolvers.length > 0) {
                 const resolver = this.resolvers.shift();
                 resolver(response);
@@ -86,6 +96,38 @@ class MCPClient {
     });
   }
 
+  handleNotification(notification) {
+    this.notifications.push(notification);
+    for (const waiter of [...this.notificationWaiters]) {
+      if (waiter.predicate(notification)) {
+        this.notificationWaiters.splice(this.notificationWaiters.indexOf(waiter), 1);
+        waiter.resolve(notification);
+      }
+    }
+  }
+
+  waitForNotification(predicate, timeout = 30000) {
+    const existing = this.notifications.find(predicate);
+    if (existing) return Promise.resolve(existing);
+
+    return new Promise((resolve, reject) => {
+      const timer = setTimeout(() => {
+        reject(new Error(`Timeout waiting for notification after ${timeout}ms`));
+      }, timeout);
+      this.notificationWaiters.push({
+        predicate,
+        resolve: (notification) => {
+          clearTimeout(timer);
+          resolve(notification);
+        },
+      });
+    });
+  }
+
+  notify(method, params = {}) {
+    this.proc.stdin.write(JSON.stringify({ jsonrpc: '2.0', method, params }) + '\n');
+  }
+
   async call(method, params = {}) {
     const id = this.send(method, params);
     const response = await this.receive();
@@ -125,14 +167,16 @@ describe('MCP Server: Protocol', () => {
     assert.strictEqual(response.result.protocolVersion, '2024-11-05');
     assert.strictEqual(response.result.serverInfo.name, 'vibium');
     assert.ok(response.result.capabilities.tools, 'Should have tools capability');
+    assert.ok(response.result.capabilities.resources.subscribe, 'Should support resource subscriptions');
+    assert.ok(response.result.capabilities.prompts, 'Should have prompts capability');
   });
 
-  test('tools/list returns all 7 browser tools', async () => {
+  test('tools/list returns all browser tools', async () => {
     const response = await client.call('tools/list', {});
 
     assert.ok(response.result, 'Should have result');
     assert.ok(response.result.tools, 'Should have tools array');
-    assert.strictEqual(response.result.tools.length, 7, 'Should have 7 tools');
+    assert.strictEqual(response.result.tools.length, 16, 'Should have 16 tools');
 
     const toolNames = response.result.tools.map(t => t.name);
     assert.ok(toolNames.includes('browser_launch'), 'Should have browser_launch');
@@ -141,9 +185,44 @@ describe('MCP Server: Protocol', () => {
     assert.ok(toolNames.includes('browser_type'), 'Should have browser_type');
     assert.ok(toolNames.includes('browser_screenshot'), 'Should have browser_screenshot');
     assert.ok(toolNames.includes('browser_find'), 'Should have browser_find');
+    assert.ok(toolNames.includes('browser_cookies_get'), 'Should have browser_cookies_get');
+    assert.ok(toolNames.includes('browser_cookies_set'), 'Should have browser_cookies_set');
+    assert.ok(toolNames.includes('browser_cookies_clear'), 'Should have browser_cookies_clear');
+    assert.ok(toolNames.includes('browser_save_state'), 'Should have browser_save_state');
+    assert.ok(toolNames.includes('browser_load_state'), 'Should have browser_load_state');
+    assert.ok(toolNames.includes('browser_evaluate'), 'Should have browser_evaluate');
+    assert.ok(toolNames.includes('browser_snapshot'), 'Should have browser_snapshot');
+    assert.ok(toolNames.includes('browser_get_content'), 'Should have browser_get_content');
+    assert.ok(toolNames.includes('browser_sessions_list'), 'Should have browser_sessions_list');
     assert.ok(toolNames.includes('browser_quit'), 'Should have browser_quit');
   });
 
+  test('initialize falls back to the latest protocol version', async () => {
+    const response = await client.call('initialize', {
+      protocolVersion: '1999-01-01',
+      capabilities: {},
+      clientInfo: { name: 'test', version: '1.0' },
+    });
+
+    assert.strictEqual(response.result.protocolVersion, '2025-06-18');
+  });
+
+  test('tools declare output schemas and annotations', async () => {
+    const response = await client.call('tools/list', {});
+    const tools = Object.fromEntries(response.result.tools.map((t) => [t.name, t]));
+
+    for (const tool of response.result.tools) {
+      assert.strictEqual(tool.outputSchema?.type, 'object', `${tool.name} should have an output schema`);
+      assert.ok(tool.outputSchema.required.includes('durationMs'), `${tool.name} should report durationMs`);
+      assert.strictEqual(typeof tool.annotations?.readOnlyHint, 'boolean', `${tool.name} should have annotations`);
+    }
+
+    assert.strictEqual(tools.browser_snapshot.annotations.readOnlyHint, true);
+    assert.strictEqual(tools.browser_click.annotations.destructiveHint, true);
+    assert.strictEqual(tools.browser_navigate.annotations.openWorldHint, true);
+    assert.strictEqual(tools.browser_find.annotations.openWorldHint, false);
+  });
+
   test('unknown method returns error', async () => {
     const response = await client.call('unknown/method', {});
 
@@ -151,6 +230,11 @@ describe('MCP Server: Protocol', () => {
     assert.strictEqual(response.error.code, -32601, 'Should be method not found error');
   });
 
+  test('ping returns empty result', async () => {
+    const response = await client.call('ping');
+    assert.deepStrictEqual(response.result, {});
+  });
+
   test('invalid JSON returns parse error', async () => {
     client.proc.stdin.write('not valid json\n');
     const response = await client.receive();
@@ -162,9 +246,11 @@ describe('MCP Server: Protocol', () => {
 
 describe('MCP Server: Browser Tools', () => {
   let client;
+  let stateDir;
 
   before(async () => {
-    client = new MCPClient();
+    stateDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-mcp-state-'));
+    client = new MCPClient(['--state-dir', stateDir]);
     await client.start();
 
     // Initialize first
@@ -173,6 +259,7 @@ describe('MCP Server: Browser Tools', () => {
 
   after(() => {
     client.stop();
+    fs.rmSync(stateDir, { recursive: true, force: true });
   });
 
   test('browser_navigate without launch returns error', async () => {
@@ -215,6 +302,11 @@ describe('MCP Server: Browser Tools', () => {
       response.result.content[0].text.includes('example.com'),
       'Should confirm navigation'
     );
+
+    const output = response.result.structuredContent;
+    assert.match(output.url, /example\.com/);
+    assert.strictEqual(output.title, 'Example Domain');
+    assert.strictEqual(typeof output.durationMs, 'number');
   });
 
   test('browser_find returns element info', async () => {
@@ -229,6 +321,128 @@ describe('MCP Server: Browser Tools', () => {
       response.result.content[0].text.includes('tag=h1'),
       'Should find h1 element'
     );
+
+    const output = response.result.structuredContent;
+    assert.strictEqual(output.tag, 'h1');
+    assert.strictEqual(output.matchCount, 1);
+    assert.ok(output.box.width > 0, 'Should have a bounding box');
+  });
+
+  test('browser_cookies_set and browser_cookies_get round-trip a cookie', async () => {
+    const setResponse = await client.call('tools/call', {
+      name: 'browser_cookies_set',
+      arguments: { name: 'vibium_test', value: 'abc123' },
+    });
+
+    assert.ok(setResponse.result, 'Should have result');
+    assert.ok(!setResponse.result.isError, 'Should not be an error');
+
+    const getResponse = await client.call('tools/call', {
+      name: 'browser_cookies_get',
+      arguments: { name: 'vibium_test' },
+    });
+
+    assert.ok(!getResponse.result.isError, 'Should not be an error');
+    const cookies = JSON.parse(getResponse.result.content[0].text);
+    assert.strictEqual(cookies.length, 1, 'Should find the cookie');
+    assert.strictEqual(cookies[0].value, 'abc123', 'Should have the cookie value');
+    assert.ok(cookies[0].domain.endsWith('example.com'), 'Should default to the page domain');
+  });
+
+  test('browser_save_state and browser_load_state round-trip cookies', async () => {
+    const saveResponse = await client.call('tools/call', {
+      name: 'browser_save_state',
+      arguments: { path: 'state.json' },
+    });
+
+    assert.ok(!saveResponse.result.isError, 'Should not be an error');
+    const state = JSON.parse(fs.readFileSync(path.join(stateDir, 'state.json'), 'utf-8'));
+    assert.ok(state.cookies.some(c => c.name === 'vibium_test'), 'Should save the cookie');
+
+    const loadResponse = await client.call('tools/call', {
+      name: 'browser_load_state',
+      arguments: { path: 'state.json' },
+    });
+
+    assert.ok(!loadResponse.result.isError, 'Should not be an error');
+    assert.ok(
+      loadResponse.result.content[0].text.includes('loaded'),
+      'Should confirm load'
+    );
+  });
+
+  test('browser_save_state rejects paths outside the state directory', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_save_state',
+      arguments: { path: '../state.json' },
+    });
+
+    assert.strictEqual(response.error.code, -32602, 'Should be invalid params');
+    assert.ok(!fs.existsSync(path.join(stateDir, '..', 'state.json')), 'Should not write the file');
+  });
+
+  test('browser_cookies_clear deletes cookies', async () => {
+    const clearResponse = await client.call('tools/call', {
+      name: 'browser_cookies_clear',
+      arguments: {},
+    });
+
+    assert.ok(!clearResponse.result.isError, 'Should not be an error');
+
+    const getResponse = await client.call('tools/call', {
+      name: 'browser_cookies_get',
+      arguments: {},
+    });
+
+    assert.deepStrictEqual(JSON.parse(getResponse.result.content[0].text), [], 'Should have no cookies');
+  });
+
+  test('browser_evaluate returns JSON result', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: '({ title: document.title, n: 1 / 0 })' },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    const result = JSON.parse(response.result.content[0].text);
+    assert.strictEqual(result.title, 'Example Domain');
+    assert.strictEqual(result.n, 'Infinity', 'Should encode special numbers as strings');
+  });
+
+  test('browser_evaluate calls function with args', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { function: 'async (a, b) => a + b', args: [2, 3] },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.strictEqual(JSON.parse(response.result.content[0].text), 5);
+  });
+
+  test('browser_evaluate truncates large results', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: "({ s: 'é'.repeat(100000) })" },
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    assert.ok(response.result.content[0].text.includes('truncated'), 'Should note truncation');
+    const output = response.result.structuredContent;
+    assert.strictEqual(output.truncated, true, 'Should mark the result as truncated');
+    assert.ok(output.result.s.endsWith('…'), 'Should keep a shortened string');
+    assert.ok(output.result.s.length < 100000, 'Should shorten the string');
+  });
+
+  test('browser_evaluate reports thrown exceptions with stack', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { function: "function boom() { throw new Error('kaboom'); }" },
+    });
+
+    assert.ok(response.result.isError, 'Should be an error');
+    const text = response.result.content[0].text;
+    assert.ok(text.includes('kaboom'), 'Should include the exception message');
+    assert.ok(text.includes('at boom'), 'Should include the JS stack');
   });
 
   test('browser_screenshot returns image', async () => {
@@ -246,6 +460,100 @@ describe('MCP Server: Browser Tools', () => {
     assert.ok(content.data.length > 100, 'Should have base64 data');
   });
 
+  test('browser_snapshot returns refs usable by browser_click', async () => {
+    const snapshotResponse = await client.call('tools/call', {
+      name: 'browser_snapshot',
+      arguments: {},
+    });
+
+    assert.ok(!snapshotResponse.result.isError, 'Should not be an error');
+    const text = snapshotResponse.result.content[0].text;
+    assert.match(text, /- heading "Example Domain" \[level=1\]/, 'Should include the heading');
+
+    const linkRef = text.match(/- link .*\[ref=(e\d+)\]/)[1];
+    const findResponse = await client.call('tools/call', {
+      name: 'browser_find',
+      arguments: { ref: linkRef },
+    });
+    assert.ok(findResponse.result.content[0].text.includes('tag=a'), 'Ref should point at the link');
+
+    const attributes = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: "document.querySelector('a').getAttributeNames()" },
+    });
+    assert.ok(
+      !attributes.result.structuredContent.result.some((name) => name.startsWith('data-vibium')),
+      'Snapshot should not add attributes'
+    );
+
+    const againResponse = await client.call('tools/call', {
+      name: 'browser_snapshot',
+      arguments: {},
+    });
+    assert.ok(
+      againResponse.result.content[0].text.includes(`[ref=${linkRef}]`),
+      'Refs should be stable across snapshots'
+    );
+  });
+
+  test('browser_get_content returns markdown', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_get_content',
+      arguments: {},
+    });
+
+    assert.ok(!response.result.isError, 'Should not be an error');
+    const text = response.result.content[0].text;
+    assert.match(text, /^# Example Domain$/m, 'Should render heading');
+    assert.match(text, /\]\(https:\/\//, 'Should include absolute link URLs');
+  });
+
+  test('browser_get_content reports missing selector', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_get_content',
+      arguments: { selector: '#does-not-exist' },
+    });
+
+    assert.ok(response.result.isError, 'Should be an error');
+    assert.ok(response.result.content[0].text.includes('not found'), 'Should say not found');
+  });
+
+  test('long tool calls report progress and can be cancelled', async () => {
+    const clickId = client.send('tools/call', {
+      name: 'browser_click',
+      arguments: { selector: '#never-appears' },
+      _meta: { progressToken: 'click-progress' },
+    });
+
+    const progress = await client.waitForNotification(
+      (n) => n.method === 'notifications/progress' && n.params.progressToken === 'click-progress'
+    );
+    assert.match(progress.params.message, /waiting for '#never-appears'/, 'Should describe the wait');
+
+    // Other requests are answered while the tool runs
+    const ping = await client.call('ping');
+    assert.deepStrictEqual(ping.result, {});
+
+    client.notify('notifications/cancelled', { requestId: clickId, reason: 'test' });
+
+    // The cancelled call gets no response; the next call is answered normally
+    const response = await client.call('tools/call', {
+      name: 'browser_find',
+      arguments: { selector: 'h1' },
+    });
+    assert.ok(!response.result.isError, 'Should not be an error');
+  });
+
+  test('browser_click rejects unknown ref', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_click',
+      arguments: { ref: 'e99999' },
+    });
+
+    assert.ok(response.result.isError, 'Should be an error');
+    assert.ok(response.result.content[0].text.includes('browser_snapshot'), 'Should suggest a new snapshot');
+  });
+
   test('browser_click clicks element', async () => {
     const response = await client.call('tools/call', {
       name: 'browser_click',
@@ -284,3 +592,367 @@ describe('MCP Server: Browser Tools', () => {
     assert.ok(!response.result.isError, 'Should not be an error');
   });
 });
+
+describe('MCP Server: Snapshot shadow DOM', () => {
+  let client;
+
+  const page = `<script>
+    customElements.define('x-card', class extends HTMLElement {
+      constructor() {
+        super();
+        this.attachShadow({ mode: 'open' }).innerHTML =
+          '<h2>Card</h2><slot></slot><button onclick="this.textContent = \\'Clicked\\'">Inner</button>';
+      }
+    });
+  </script>
+  <x-card><a href="https://example.com/slotted">Slotted link</a></x-card>`;
+
+  before(async () => {
+    client = new MCPClient();
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+    await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'data:text/html,' + encodeURIComponent(page) },
+    });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  test('snapshot includes shadow and slotted elements with usable refs', async () => {
+    const snapshot = await client.call('tools/call', { name: 'browser_snapshot', arguments: {} });
+    const text = snapshot.result.content[0].text;
+    assert.match(text, /- heading "Card"/, 'Should include the shadow heading');
+    assert.match(text, /- link "Slotted link"/, 'Should include the slotted link');
+
+    const buttonRef = text.match(/- button "Inner" \[ref=(e\d+)\]/)[1];
+    const click = await client.call('tools/call', { name: 'browser_click', arguments: { ref: buttonRef } });
+    assert.ok(!click.result.isError, 'Should click the shadow button by ref');
+
+    const again = await client.call('tools/call', { name: 'browser_snapshot', arguments: {} });
+    assert.match(again.result.content[0].text, /- button "Clicked"/, 'Click should reach the shadow button');
+  });
+});
+
+describe('MCP Server: Named sessions', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient();
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  const callTool = async (name, args = {}) => {
+    const response = await client.call('tools/call', { name, arguments: args });
+    return response.result;
+  };
+
+  test('sessions are isolated browsers', async () => {
+    const admin = await callTool('browser_launch', { headless: true, session: 'admin' });
+    assert.ok(!admin.isError, 'Should launch admin');
+    assert.strictEqual(admin.structuredContent.session, 'admin');
+
+    const customer = await callTool('browser_launch', { headless: true, session: 'customer' });
+    assert.strictEqual(customer.structuredContent.session, 'customer');
+
+    await callTool('browser_navigate', { url: 'https://example.com', session: 'admin' });
+    await callTool('browser_navigate', { url: 'https://example.com', session: 'customer' });
+    await callTool('browser_cookies_set', { name: 'role', value: 'admin', session: 'admin' });
+
+    const adminCookies = await callTool('browser_cookies_get', { name: 'role', session: 'admin' });
+    assert.strictEqual(adminCookies.structuredContent.cookies.length, 1);
+    const customerCookies = await callTool('browser_cookies_get', { name: 'role', session: 'customer' });
+    assert.strictEqual(customerCookies.structuredContent.cookies.length, 0, 'Cookies should not leak between sessions');
+  });
+
+  test('browser_sessions_list shows open sessions and the current one', async () => {
+    const result = await callTool('browser_sessions_list');
+    const sessions = result.structuredContent.sessions;
+    assert.deepStrictEqual(sessions.map((s) => s.session), ['admin', 'customer']);
+    assert.strictEqual(sessions.find((s) => s.current).session, 'customer', 'Last launched should be current');
+  });
+
+  test('unknown session returns error', async () => {
+    const result = await callTool('browser_navigate', { url: 'https://example.com', session: 'nope' });
+    assert.strictEqual(result.isError, true);
+    assert.match(result.content[0].text, /no browser session "nope"/);
+  });
+
+  test('browser_quit closes one session', async () => {
+    const result = await callTool('browser_quit', { session: 'customer' });
+    assert.strictEqual(result.structuredContent.closed, true);
+
+    const list = await callTool('browser_sessions_list');
+    assert.deepStrictEqual(list.structuredContent.sessions.map((s) => s.session), ['admin']);
+    assert.strictEqual(list.structuredContent.sessions[0].current, true, 'Remaining session should become current');
+
+    await callTool('browser_quit');
+  });
+});
+
+describe('MCP Server: --disable-evaluate', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient(['--disable-evaluate']);
+    await client.start();
+  });
+
+  after(() => {
+    client.stop();
+  });
+
+  test('browser_evaluate is hidden and rejected', async () => {
+    const listResponse = await client.call('tools/list', {});
+    const toolNames = listResponse.result.tools.map((t) => t.name);
+    assert.ok(!toolNames.includes('browser_evaluate'), 'Should not list browser_evaluate');
+
+    const callResponse = await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: '1' },
+    });
+    assert.strictEqual(callResponse.error.code, -32602, 'Should be invalid params');
+    assert.ok(callResponse.error.message.includes('disabled'), 'Should say it is disabled');
+  });
+});
+
+describe('MCP Server: Resources', () => {
+  let client;
+  let screenshotDir;
+
+  before(async () => {
+    screenshotDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-mcp-resources-'));
+    client = new MCPClient(['--screenshot-dir', screenshotDir]);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+    fs.rmSync(screenshotDir, { recursive: true, force: true });
+  });
+
+  test('resources/list returns page resources', async () => {
+    const response = await client.call('resources/list', {});
+    const uris = response.result.resources.map((r) => r.uri);
+    assert.deepStrictEqual(uris, ['vibium://page', 'vibium://page/snapshot', 'vibium://console']);
+  });
+
+  test('resources/read returns error for unknown resource', async () => {
+    const response = await client.call('resources/read', { uri: 'vibium://nope' });
+    assert.ok(response.error, 'Should have error');
+    assert.strictEqual(response.error.code, -32002, 'Should be resource not found error');
+  });
+
+  test('subscribed resources are updated on navigation and console messages', async () => {
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+    await client.call('resources/subscribe', { uri: 'vibium://page' });
+    await client.call('resources/subscribe', { uri: 'vibium://console' });
+
+    await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://example.com' },
+    });
+    await client.waitForNotification(
+      (n) => n.method === 'notifications/resources/updated' && n.params.uri === 'vibium://page'
+    );
+
+    const page = await client.call('resources/read', { uri: 'vibium://page' });
+    const info = JSON.parse(page.result.contents[0].text);
+    assert.match(info.url, /example\.com/);
+    assert.strictEqual(info.title, 'Example Domain');
+
+    await client.call('tools/call', {
+      name: 'browser_evaluate',
+      arguments: { expression: "console.warn('hello from the page')" },
+    });
+    await client.waitForNotification(
+      (n) => n.method === 'notifications/resources/updated' && n.params.uri === 'vibium://console'
+    );
+
+    const consoleLog = await client.call('resources/read', { uri: 'vibium://console' });
+    const entries = JSON.parse(consoleLog.result.contents[0].text);
+    const entry = entries.find((e) => e.text === 'hello from the page');
+    assert.ok(entry, 'Should contain the console message');
+    assert.strictEqual(entry.level, 'warn');
+  });
+
+  test('saved screenshots are listed and readable', async () => {
+    await client.call('tools/call', {
+      name: 'browser_screenshot',
+      arguments: { filename: 'page.png' },
+    });
+    await client.waitForNotification((n) => n.method === 'notifications/resources/list_changed');
+
+    const list = await client.call('resources/list', {});
+    const uris = list.result.resources.map((r) => r.uri);
+    assert.ok(uris.includes('vibium://screenshots/page.png'), 'Should list the screenshot');
+
+    const response = await client.call('resources/read', { uri: 'vibium://screenshots/page.png' });
+    const contents = response.result.contents[0];
+    assert.strictEqual(contents.mimeType, 'image/png');
+    assert.ok(contents.blob.length > 100, 'Should have base64 data');
+  });
+});
+
+describe('MCP Server: URL policy', () => {
+  let client;
+
+  before(async () => {
+    client = new MCPClient(['--allow-origin', 'example.com', '--block-origin', 'example.com:8080']);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(async () => {
+    await client.call('tools/call', { name: 'browser_quit', arguments: {} });
+    client.stop();
+  });
+
+  test('browser_navigate to a disallowed origin is a policy violation', async () => {
+    const response = await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://www.iana.org/' },
+    });
+    assert.ok(response.result.isError, 'Should be an error');
+    assert.ok(response.result.content[0].text.includes('policy violation'), 'Should be a policy violation');
+  });
+
+  test('browser_navigate to blocked origins and file:// URLs is a policy violation', async () => {
+    for (const url of ['http://example.com:8080/', 'file:///etc/passwd', 'chrome://settings']) {
+      const response = await client.call('tools/call', {
+        name: 'browser_navigate',
+        arguments: { url },
+      });
+      assert.ok(response.result.isError, `${url} should be an error`);
+      assert.ok(response.result.content[0].text.includes('policy violation'), `${url} should be a policy violation`);
+    }
+  });
+
+  test('links to disallowed origins are blocked', async () => {
+    await client.call('tools/call', { name: 'browser_launch', arguments: { headless: true } });
+
+    const navigate = await client.call('tools/call', {
+      name: 'browser_navigate',
+      arguments: { url: 'https://example.com' },
+    });
+    assert.ok(!navigate.result.isError, 'Allowed origin should load');
+
+    // example.com links to iana.org. The navigation starts after the click,
+    // so the violation may be reported by the next call.
+    let response = await client.call('tools/call', {
+      name: 'browser_click',
+      arguments: { selector: 'a' },
+    });
+    for (let i = 0; i < 10 && !response.result.isError; i++) {
+      await new Promise((resolve) => setTimeout(resolve, 200));
+      response = await client.call('tools/call', {
+        name: 'browser_find',
+        arguments: { selector: 'body' },
+      });
+    }
+    assert.ok(response.result.isError, 'Should report the blocked navigation');
+    assert.ok(response.result.content[0].text.includes('policy violation'), 'Should be a policy violation');
+    assert.ok(response.result.content[0].text.includes('www.iana.org'), 'Should name the blocked URL');
+  });
+
+  test('invalid patterns are rejected at startup', () => {
+    const result = spawnSync(CLICKER, ['mcp', '--allow-origin', 'example.com/path'], {
+      input: '',
+      timeout: 10000,
+    });
+    assert.notStrictEqual(result.status, 0, 'Should exit with an error');
+    assert.ok(result.stderr.toString().includes('invalid URL pattern'), 'Should explain the invalid pattern');
+  });
+});
+
+describe('MCP Server: Prompts', () => {
+  let client;
+  let promptDir;
+
+  before(async () => {
+    promptDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-mcp-prompts-'));
+    fs.writeFileSync(path.join(promptDir, 'checkout_smoke.json'), JSON.stringify({
+      description: 'Smoke test the checkout flow',
+      arguments: [
+        { name: 'url', description: 'Store URL', required: true },
+        { name: 'product', description: 'Product to buy' },
+      ],
+      template: ['Open {{.url}} and add {{if .product}}{{.product}}{{else}}any product{{end}} to the cart.', 'Then check out.'],
+    }));
+
+    client = new MCPClient(['--prompt-dir', promptDir]);
+    await client.start();
+    await client.call('initialize', { capabilities: {} });
+  });
+
+  after(() => {
+    client.stop();
+    fs.rmSync(promptDir, { recursive: true, force: true });
+  });
+
+  test('prompts/list returns built-in and user prompts', async () => {
+    const response = await client.call('prompts/list', {});
+    const names = response.result.prompts.map((p) => p.name);
+    assert.deepStrictEqual(names, ['checkout_smoke', 'explore_site', 'fill_form', 'reproduce_bug']);
+
+    const explore = response.result.prompts.find((p) => p.name === 'explore_site');
+    const url = explore.arguments.find((a) => a.name === 'url');
+    assert.strictEqual(url.required, true, 'url should be required');
+  });
+
+  test('prompts/get fills in built-in prompts', async () => {
+    const response = await client.call('prompts/get', {
+      name: 'reproduce_bug',
+      arguments: { url: 'https://example.com', goal: 'The heading is missing' },
+    });
+    const message = response.result.messages[0];
+    assert.strictEqual(message.role, 'user');
+    assert.strictEqual(message.content.type, 'text');
+    assert.ok(message.content.text.includes('https://example.com'), 'Should contain the URL');
+    assert.ok(message.content.text.includes('The heading is missing'), 'Should contain the goal');
+    assert.ok(message.content.text.includes('browser_screenshot'), 'Should ask for screenshots');
+  });
+
+  test('prompts/get fills in user prompts', async () => {
+    const response = await client.call('prompts/get', {
+      name: 'checkout_smoke',
+      arguments: { url: 'https://shop.example.com' },
+    });
+    assert.strictEqual(
+      response.result.messages[0].content.text,
+      'Open https://shop.example.com and add any product to the cart.\nThen check out.'
+    );
+  });
+
+  test('prompts/get rejects missing arguments and unknown prompts', async () => {
+    const missing = await client.call('prompts/get', { name: 'fill_form', arguments: { url: 'https://example.com' } });
+    assert.strictEqual(missing.error.code, -32602, 'Should be invalid params');
+    assert.ok(missing.error.message.includes('goal'), 'Should name the missing argument');
+
+    const unknown = await client.call('prompts/get', { name: 'nope' });
+    assert.strictEqual(unknown.error.code, -32602, 'Should be invalid params');
+  });
+
+  test('invalid prompt files are rejected at startup', () => {
+    const badDir = fs.mkdtempSync(path.join(os.tmpdir(), 'vibium-mcp-prompts-bad-'));
+    fs.writeFileSync(path.join(badDir, 'bad.json'), JSON.stringify({ template: 'Open {{.url}}' }));
+    try {
+      const result = spawnSync(CLICKER, ['mcp', '--prompt-dir', badDir], { input: '', timeout: 10000 });
+      assert.notStrictEqual(result.status, 0, 'Should exit with an error');
+      assert.ok(result.stderr.toString().includes('bad.json'), 'Should name the invalid file');
+    } finally {
+      fs.rmSync(badDir, { recursive: true, force: true });
+    }
+  });
+});